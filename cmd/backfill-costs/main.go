@@ -0,0 +1,80 @@
+package main
+
+import (
+	"flag"
+	"log"
+
+	"github.com/google/uuid"
+	"github.com/joho/godotenv"
+	"github.com/yuditriaji/warungin-backend/pkg/database"
+	"github.com/yuditriaji/warungin-backend/pkg/recipe"
+	"gorm.io/gorm"
+)
+
+// One-off backfill of transaction_items.unit_cost for sales recorded before
+// costs were captured at sale time. Historical prices are not known, so the
+// current product/material cost is used. Safe to run more than once: only
+// rows with a NULL unit_cost are touched.
+//
+// Usage: go run ./cmd/backfill-costs [-dry-run] [-batch 500]
+func main() {
+	dryRun := flag.Bool("dry-run", false, "Report what would be updated without writing")
+	batchSize := flag.Int("batch", 500, "Rows per batch")
+	flag.Parse()
+
+	if err := godotenv.Load(); err != nil {
+		log.Println("No .env file found, using system environment variables")
+	}
+
+	db, err := database.Connect()
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+
+	// Make sure the unit_cost column exists
+	if err := database.Migrate(db); err != nil {
+		log.Fatalf("Failed to run migrations: %v", err)
+	}
+
+	calc := recipe.NewCalculator(db)
+	productCosts := make(map[uuid.UUID]float64)
+	var updated, missing int
+
+	var items []database.TransactionItem
+	result := db.Where("unit_cost IS NULL").
+		FindInBatches(&items, *batchSize, func(tx *gorm.DB, batch int) error {
+			for _, item := range items {
+				cost, ok := productCosts[item.ProductID]
+				if !ok {
+					var product database.Product
+					// Include soft-deleted products, their sales still need a cost
+					if err := db.Unscoped().Where("id = ?", item.ProductID).First(&product).Error; err != nil {
+						missing++
+						continue
+					}
+					cost = calc.ProductUnitCost(product)
+					productCosts[item.ProductID] = cost
+				}
+
+				if !*dryRun {
+					if err := db.Model(&database.TransactionItem{}).
+						Where("id = ?", item.ID).
+						Update("unit_cost", cost).Error; err != nil {
+						return err
+					}
+				}
+				updated++
+			}
+			log.Printf("Batch %d: %d items processed", batch, len(items))
+			return nil
+		})
+	if result.Error != nil {
+		log.Fatalf("Backfill failed: %v", result.Error)
+	}
+
+	if *dryRun {
+		log.Printf("Dry run: %d items would be updated, %d skipped (product not found)", updated, missing)
+		return
+	}
+	log.Printf("Backfill completed: %d items updated, %d skipped (product not found)", updated, missing)
+}
@@ -7,22 +7,27 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
 	"github.com/yuditriaji/warungin-backend/internal/auth"
+	"github.com/yuditriaji/warungin-backend/internal/category"
 	"github.com/yuditriaji/warungin-backend/internal/customer"
+	"github.com/yuditriaji/warungin-backend/internal/expense"
 	"github.com/yuditriaji/warungin-backend/internal/dashboard"
 	"github.com/yuditriaji/warungin-backend/internal/inventory"
 	"github.com/yuditriaji/warungin-backend/internal/material"
 	"github.com/yuditriaji/warungin-backend/internal/outlet"
 	"github.com/yuditriaji/warungin-backend/internal/payment"
 	"github.com/yuditriaji/warungin-backend/internal/portal"
+	"github.com/yuditriaji/warungin-backend/internal/pricelist"
 	"github.com/yuditriaji/warungin-backend/internal/product"
 	"github.com/yuditriaji/warungin-backend/internal/region"
 	"github.com/yuditriaji/warungin-backend/internal/reports"
 	"github.com/yuditriaji/warungin-backend/internal/subscription"
 	"github.com/yuditriaji/warungin-backend/internal/tenant"
 	"github.com/yuditriaji/warungin-backend/internal/transaction"
+	"github.com/yuditriaji/warungin-backend/internal/unit"
 	"github.com/yuditriaji/warungin-backend/internal/user"
 	"github.com/yuditriaji/warungin-backend/pkg/database"
 	"github.com/yuditriaji/warungin-backend/pkg/middleware"
+	"github.com/yuditriaji/warungin-backend/pkg/storage"
 )
 
 func main() {
@@ -42,9 +47,20 @@ func main() {
 		log.Fatalf("Failed to run migrations: %v", err)
 	}
 
+	// Initialize file storage for uploaded images
+	store, err := storage.FromEnv()
+	if err != nil {
+		log.Fatalf("Failed to configure storage: %v", err)
+	}
+
 	// Setup Gin router
 	r := gin.Default()
 
+	// Serve uploads kept on local disk
+	if local, ok := store.(*storage.Local); ok {
+		r.Static(storage.LocalMountPath, local.Dir())
+	}
+
 	// Middleware
 	r.Use(middleware.CORS())
 
@@ -89,7 +105,7 @@ func main() {
 			limitChecker := middleware.NewLimitChecker(db)
 			
 			// Product routes (with limit check)
-			productHandler := product.NewHandler(db)
+			productHandler := product.NewHandler(db, store)
 			protected.GET("/products", productHandler.List)
 			protected.POST("/products", limitChecker.CheckProductLimit(), productHandler.Create)
 			protected.GET("/products/:id", productHandler.Get)
@@ -98,6 +114,48 @@ func main() {
 			protected.PATCH("/products/:id/toggle", productHandler.ToggleActive)
 			protected.GET("/products/:id/available-stock", productHandler.GetAvailableStock)
 
+			// Product variant routes
+			protected.PUT("/products/:id/variant-axes", productHandler.SetVariantAxes)
+			protected.GET("/products/:id/variants", productHandler.ListVariants)
+			protected.POST("/products/:id/variants", productHandler.CreateVariant)
+			protected.POST("/products/:id/variants/generate", productHandler.GenerateVariants)
+			protected.PUT("/products/:id/variants/:variant_id", productHandler.UpdateVariant)
+			protected.DELETE("/products/:id/variants/:variant_id", productHandler.DeleteVariant)
+
+			// Barcode routes
+			protected.GET("/products/lookup", productHandler.Lookup)
+			protected.POST("/products/labels", productHandler.PrintLabels)
+			protected.POST("/products/barcodes/generate", productHandler.GenerateMissingBarcodes)
+			protected.GET("/products/:id/barcodes", productHandler.ListBarcodes)
+			protected.POST("/products/:id/barcodes", productHandler.AddBarcode)
+			protected.POST("/products/:id/barcodes/generate", productHandler.GenerateBarcode)
+			protected.PUT("/products/:id/barcodes/:barcode_id/primary", productHandler.SetPrimaryBarcode)
+			protected.DELETE("/products/:id/barcodes/:barcode_id", productHandler.DeleteBarcode)
+
+			// Product bundle routes
+			protected.GET("/products/:id/bundle", productHandler.GetBundle)
+			protected.PUT("/products/:id/bundle", productHandler.SetBundle)
+			protected.DELETE("/products/:id/bundle", productHandler.DeleteBundle)
+
+			// Product image routes
+			protected.POST("/products/:id/image", productHandler.UploadImage)
+			protected.DELETE("/products/:id/image", productHandler.DeleteImage)
+
+			// Product availability routes
+			protected.GET("/products/sold-out", productHandler.ListSoldOut)
+			protected.PUT("/products/:id/availability", productHandler.SetAvailability)
+			protected.POST("/products/:id/sold-out", productHandler.MarkSoldOut)
+			protected.DELETE("/products/:id/sold-out", productHandler.ClearSoldOut)
+
+			// Category routes
+			categoryHandler := category.NewHandler(db)
+			protected.GET("/categories", categoryHandler.List)
+			protected.POST("/categories", categoryHandler.Create)
+			protected.PUT("/categories/reorder", categoryHandler.Reorder)
+			protected.GET("/categories/:id", categoryHandler.Get)
+			protected.PUT("/categories/:id", categoryHandler.Update)
+			protected.DELETE("/categories/:id", categoryHandler.Delete)
+
 			// Transaction routes (with limit check)
 			transactionHandler := transaction.NewHandler(db)
 			protected.GET("/transactions", transactionHandler.List)
@@ -105,11 +163,40 @@ func main() {
 		protected.GET("/transactions/:id", transactionHandler.Get)
 			protected.POST("/transactions/:id/void", transactionHandler.Void)
 			protected.GET("/audit-logs", transactionHandler.ListAuditLogs)
+			protected.GET("/cash-counts", transactionHandler.ListCashCounts)
+			protected.POST("/cash-counts", transactionHandler.CreateCashCount)
 
 			// Reports routes
 			reportsHandler := reports.NewHandler(db)
 			protected.GET("/reports/sales", reportsHandler.GetSalesReport)
 			protected.GET("/reports/products", reportsHandler.GetProductSalesReport)
+			protected.GET("/reports/categories", reportsHandler.GetCategorySalesReport)
+			protected.GET("/reports/bundle-components", reportsHandler.GetBundleComponentReport)
+			protected.GET("/reports/profit-loss", reportsHandler.GetProfitLossReport)
+			protected.GET("/reports/tax", reportsHandler.GetTaxReport)
+			protected.GET("/reports/customers", reportsHandler.GetCustomerReport)
+			protected.GET("/reports/hourly", reportsHandler.GetHourlyReport)
+			protected.GET("/reports/staff", reportsHandler.GetStaffReport)
+			protected.GET("/reports/outlets", reportsHandler.GetOutletReport)
+			protected.GET("/reports/menu-engineering", reportsHandler.GetMenuEngineeringReport)
+			protected.GET("/reports/forecast", reportsHandler.GetDemandForecast)
+			protected.GET("/reports/subscriptions", reportsHandler.ListSubscriptions)
+			protected.POST("/reports/subscriptions", reportsHandler.CreateSubscription)
+			protected.PUT("/reports/subscriptions/:id", reportsHandler.UpdateSubscription)
+			protected.DELETE("/reports/subscriptions/:id", reportsHandler.DeleteSubscription)
+			protected.POST("/reports/subscriptions/:id/send", reportsHandler.SendSubscription)
+
+			// Operating expense routes (owners and managers)
+			expenseHandler := expense.NewHandler(db)
+			protected.GET("/expenses", expenseHandler.List)
+			protected.POST("/expenses", expenseHandler.Create)
+			protected.GET("/expenses/categories", expenseHandler.Categories)
+			protected.PUT("/expenses/:id", expenseHandler.Update)
+			protected.DELETE("/expenses/:id", expenseHandler.Delete)
+			protected.GET("/expenses/recurring", expenseHandler.ListRecurring)
+			protected.POST("/expenses/recurring", expenseHandler.CreateRecurring)
+			protected.PUT("/expenses/recurring/:id", expenseHandler.UpdateRecurring)
+			protected.DELETE("/expenses/recurring/:id", expenseHandler.DeleteRecurring)
 
 			// Customer routes
 			customerHandler := customer.NewHandler(db)
@@ -120,17 +207,47 @@ func main() {
 			protected.DELETE("/customers/:id", customerHandler.Delete)
 			protected.GET("/customers/:id/stats", customerHandler.GetStats)
 
+			// Price list routes
+			priceListHandler := pricelist.NewHandler(db)
+			protected.GET("/price-lists", priceListHandler.List)
+			protected.POST("/price-lists", priceListHandler.Create)
+			protected.GET("/price-lists/:id", priceListHandler.Get)
+			protected.PUT("/price-lists/:id", priceListHandler.Update)
+			protected.DELETE("/price-lists/:id", priceListHandler.Delete)
+
 			// Inventory routes
 			inventoryHandler := inventory.NewHandler(db)
 			protected.GET("/inventory", inventoryHandler.GetInventory)
 			protected.GET("/inventory/summary", inventoryHandler.GetSummary)
 			protected.GET("/inventory/alerts", inventoryHandler.GetAlerts)
 			protected.PUT("/inventory/:id/stock", inventoryHandler.UpdateStock)
+			protected.GET("/inventory/receipts", inventoryHandler.ListReceipts)
+			protected.POST("/inventory/receipts", inventoryHandler.CreateReceipt)
+			protected.GET("/inventory/waste", inventoryHandler.ListWaste)
+			protected.POST("/inventory/waste", inventoryHandler.CreateWaste)
 			
-			// Inventory import routes
+			// Import/export routes (upload previews, commit applies in one transaction)
 			importHandler := inventory.NewImportHandler(db)
-			protected.POST("/inventory/import", importHandler.ImportExcel)
-			protected.GET("/inventory/import/template", importHandler.DownloadTemplate)
+			protected.POST("/inventory/import", importHandler.Preview(inventory.EntityProduct))
+			protected.POST("/inventory/import/:id/commit", importHandler.Commit(inventory.EntityProduct))
+			protected.GET("/inventory/import/template", importHandler.Template(inventory.EntityProduct))
+			protected.GET("/inventory/export", importHandler.Export(inventory.EntityProduct))
+			protected.POST("/materials/import", importHandler.Preview(inventory.EntityMaterial))
+			protected.POST("/materials/import/:id/commit", importHandler.Commit(inventory.EntityMaterial))
+			protected.GET("/materials/import/template", importHandler.Template(inventory.EntityMaterial))
+			protected.GET("/materials/export", importHandler.Export(inventory.EntityMaterial))
+			protected.POST("/product-materials/import", importHandler.Preview(inventory.EntityRecipe))
+			protected.POST("/product-materials/import/:id/commit", importHandler.Commit(inventory.EntityRecipe))
+			protected.GET("/product-materials/import/template", importHandler.Template(inventory.EntityRecipe))
+			protected.GET("/product-materials/export", importHandler.Export(inventory.EntityRecipe))
+			protected.POST("/customers/import", importHandler.Preview(inventory.EntityCustomer))
+			protected.POST("/customers/import/:id/commit", importHandler.Commit(inventory.EntityCustomer))
+			protected.GET("/customers/import/template", importHandler.Template(inventory.EntityCustomer))
+			protected.GET("/customers/export", importHandler.Export(inventory.EntityCustomer))
+			protected.POST("/categories/import", importHandler.Preview(inventory.EntityCategory))
+			protected.POST("/categories/import/:id/commit", importHandler.Commit(inventory.EntityCategory))
+			protected.GET("/categories/import/template", importHandler.Template(inventory.EntityCategory))
+			protected.GET("/categories/export", importHandler.Export(inventory.EntityCategory))
 
 			// Subscription routes
 			subscriptionHandler := subscription.NewHandler(db)
@@ -156,10 +273,12 @@ func main() {
 			protected.POST("/subscription/reactivate", subscriptionHandler.ReactivateSubscription)
 
 			// Tenant settings routes
-			tenantHandler := tenant.NewHandler(db)
+			tenantHandler := tenant.NewHandler(db, store)
 			protected.GET("/tenant/settings", tenantHandler.GetSettings)
 			protected.PUT("/tenant/settings", tenantHandler.UpdateSettings)
 			protected.POST("/tenant/qris-upload", tenantHandler.UploadQRIS)
+			protected.POST("/tenant/logo", tenantHandler.UploadLogo)
+			protected.DELETE("/tenant/logo", tenantHandler.DeleteLogo)
 			protected.PUT("/tenant/profile", tenantHandler.UpdateProfile)
 
 			// Material routes
@@ -172,12 +291,26 @@ func main() {
 			protected.PUT("/materials/:id/stock", materialHandler.UpdateStock)
 			protected.GET("/materials/alerts", materialHandler.GetAlerts)
 
+			// Prepared material (sub-recipe) routes
+			protected.GET("/materials/:id/recipe", materialHandler.GetRecipe)
+			protected.POST("/materials/:id/recipe", materialHandler.AddComponent)
+			protected.DELETE("/materials/:id/recipe/:component_id", materialHandler.RemoveComponent)
+			protected.POST("/materials/:id/produce", materialHandler.Produce)
+			protected.GET("/materials/:id/productions", materialHandler.ListProductionRuns)
+
 			// Product-Material linkage (using separate path to avoid conflict with /products/:id)
 			protected.GET("/product-materials/:product_id", materialHandler.GetProductMaterials)
 			protected.POST("/product-materials", materialHandler.LinkMaterial)
 			protected.DELETE("/product-materials/:product_id/:material_id", materialHandler.UnlinkMaterial)
 			protected.GET("/product-materials/:product_id/cost", materialHandler.CalculateProductCost)
 
+			// Unit of measure routes
+			unitHandler := unit.NewHandler(db)
+			protected.GET("/units", unitHandler.List)
+			protected.POST("/units", unitHandler.Create)
+			protected.DELETE("/units/:id", unitHandler.Delete)
+			protected.GET("/units/convert", unitHandler.Convert)
+
 			// Outlet routes
 			outletHandler := outlet.NewHandler(db)
 			protected.GET("/outlets", outletHandler.List)
@@ -266,6 +399,14 @@ func main() {
 	subScheduler := subscription.NewScheduler(db)
 	subScheduler.Start()
 
+	// Start recurring expense scheduler
+	expenseScheduler := expense.NewScheduler(db)
+	expenseScheduler.Start()
+
+	// Start scheduled report email scheduler
+	reportScheduler := reports.NewScheduler(db)
+	reportScheduler.Start()
+
 	// Start server
 	port := os.Getenv("PORT")
 	if port == "" {
@@ -0,0 +1,339 @@
+package category
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/yuditriaji/warungin-backend/pkg/activitylog"
+	"github.com/yuditriaji/warungin-backend/pkg/categories"
+	"github.com/yuditriaji/warungin-backend/pkg/database"
+	"gorm.io/gorm"
+)
+
+// Colors are #RGB or #RRGGBB
+var colorPattern = regexp.MustCompile(`^#([0-9a-fA-F]{3}|[0-9a-fA-F]{6})$`)
+
+type Handler struct {
+	db     *gorm.DB
+	logger *activitylog.Logger
+}
+
+func NewHandler(db *gorm.DB) *Handler {
+	return &Handler{
+		db:     db,
+		logger: activitylog.NewLogger(db),
+	}
+}
+
+type CategoryRequest struct {
+	Name      string     `json:"name" binding:"required,max=100"`
+	ParentID  *uuid.UUID `json:"parent_id"`
+	Color     string     `json:"color"`
+	Icon      string     `json:"icon" binding:"max=50"`
+	SortOrder int        `json:"sort_order"`
+}
+
+type ReorderRequest struct {
+	Items []struct {
+		ID        uuid.UUID `json:"id" binding:"required"`
+		SortOrder int       `json:"sort_order"`
+	} `json:"items" binding:"required,dive"`
+}
+
+// CategoryResponse is a category with its product count and, in tree mode, its children
+type CategoryResponse struct {
+	database.Category
+	ProductCount int                 `json:"product_count"`
+	Children     []*CategoryResponse `json:"children,omitempty"`
+}
+
+// List returns the tenant's categories ordered for display.
+// With ?tree=true subcategories are nested under their parents.
+func (h *Handler) List(c *gin.Context) {
+	tenantID := c.GetString("tenant_id")
+
+	var cats []database.Category
+	if err := h.db.Where("tenant_id = ?", tenantID).
+		Order("sort_order ASC, name ASC").
+		Find(&cats).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch categories"})
+		return
+	}
+
+	// Product count per category
+	var counts []struct {
+		CategoryID uuid.UUID
+		Count      int
+	}
+	h.db.Model(&database.Product{}).
+		Select("category_id, COUNT(*) as count").
+		Where("tenant_id = ? AND category_id IS NOT NULL", tenantID).
+		Group("category_id").
+		Scan(&counts)
+	countMap := make(map[uuid.UUID]int, len(counts))
+	for _, row := range counts {
+		countMap[row.CategoryID] = row.Count
+	}
+
+	response := make([]*CategoryResponse, 0, len(cats))
+	byID := make(map[uuid.UUID]*CategoryResponse, len(cats))
+	for _, cat := range cats {
+		cr := &CategoryResponse{Category: cat, ProductCount: countMap[cat.ID]}
+		response = append(response, cr)
+		byID[cat.ID] = cr
+	}
+
+	if c.Query("tree") != "true" {
+		c.JSON(http.StatusOK, gin.H{"data": response})
+		return
+	}
+
+	roots := make([]*CategoryResponse, 0)
+	for _, cr := range response {
+		if cr.ParentID != nil {
+			if parent, ok := byID[*cr.ParentID]; ok {
+				parent.Children = append(parent.Children, cr)
+				continue
+			}
+		}
+		roots = append(roots, cr)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": roots})
+}
+
+// Get returns a single category
+func (h *Handler) Get(c *gin.Context) {
+	tenantID := c.GetString("tenant_id")
+
+	var cat database.Category
+	if err := h.db.Where("id = ? AND tenant_id = ?", c.Param("id"), tenantID).First(&cat).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Category not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": cat})
+}
+
+// validate normalizes and checks a request; id is uuid.Nil for new categories
+func (h *Handler) validate(tenantID string, id uuid.UUID, req *CategoryRequest) string {
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		return "Name is required"
+	}
+	if req.Color != "" && !colorPattern.MatchString(req.Color) {
+		return "Color must be a hex color like #FF7043"
+	}
+
+	// Names must be unique among siblings
+	dupQuery := h.db.Model(&database.Category{}).
+		Where("tenant_id = ? AND LOWER(name) = ? AND id <> ?", tenantID, strings.ToLower(req.Name), id)
+	if req.ParentID != nil {
+		dupQuery = dupQuery.Where("parent_id = ?", *req.ParentID)
+	} else {
+		dupQuery = dupQuery.Where("parent_id IS NULL")
+	}
+	var dupCount int64
+	dupQuery.Count(&dupCount)
+	if dupCount > 0 {
+		return "Category " + req.Name + " already exists"
+	}
+
+	if req.ParentID != nil {
+		tree := categories.Load(h.db, tenantID)
+		if err := tree.CheckParent(id, *req.ParentID); err != nil {
+			return err.Error()
+		}
+	}
+	return ""
+}
+
+// Create adds a new category
+func (h *Handler) Create(c *gin.Context) {
+	tenantID := c.GetString("tenant_id")
+	tenantUUID, _ := uuid.Parse(tenantID)
+
+	var req CategoryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if msg := h.validate(tenantID, uuid.Nil, &req); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	cat := database.Category{
+		TenantID:  tenantUUID,
+		ParentID:  req.ParentID,
+		Name:      req.Name,
+		Color:     req.Color,
+		Icon:      req.Icon,
+		SortOrder: req.SortOrder,
+	}
+
+	if err := h.db.Create(&cat).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create category"})
+		return
+	}
+
+	// Log activity
+	h.logger.LogCreate(c, "category", cat.ID, map[string]interface{}{
+		"name":      cat.Name,
+		"parent_id": cat.ParentID,
+	})
+
+	c.JSON(http.StatusCreated, gin.H{"data": cat})
+}
+
+// Update modifies a category
+func (h *Handler) Update(c *gin.Context) {
+	tenantID := c.GetString("tenant_id")
+
+	var cat database.Category
+	if err := h.db.Where("id = ? AND tenant_id = ?", c.Param("id"), tenantID).First(&cat).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Category not found"})
+		return
+	}
+
+	var req CategoryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if msg := h.validate(tenantID, cat.ID, &req); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	oldValues := map[string]interface{}{
+		"name":      cat.Name,
+		"parent_id": cat.ParentID,
+	}
+
+	cat.Name = req.Name
+	cat.ParentID = req.ParentID
+	cat.Color = req.Color
+	cat.Icon = req.Icon
+	cat.SortOrder = req.SortOrder
+
+	if err := h.db.Save(&cat).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update category"})
+		return
+	}
+
+	// Log activity with old and new values
+	h.logger.LogUpdate(c, "category", cat.ID, oldValues, map[string]interface{}{
+		"name":      cat.Name,
+		"parent_id": cat.ParentID,
+	})
+
+	c.JSON(http.StatusOK, gin.H{"data": cat})
+}
+
+// Reorder sets the display order of several categories at once
+func (h *Handler) Reorder(c *gin.Context) {
+	tenantID := c.GetString("tenant_id")
+
+	var req ReorderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tx := h.db.Begin()
+	for _, item := range req.Items {
+		result := tx.Model(&database.Category{}).
+			Where("id = ? AND tenant_id = ?", item.ID, tenantID).
+			Update("sort_order", item.SortOrder)
+		if result.Error != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reorder categories"})
+			return
+		}
+		if result.RowsAffected == 0 {
+			tx.Rollback()
+			c.JSON(http.StatusNotFound, gin.H{"error": "Category not found: " + item.ID.String()})
+			return
+		}
+	}
+	tx.Commit()
+
+	c.JSON(http.StatusOK, gin.H{"message": "Categories reordered"})
+}
+
+// Delete soft-deletes a category. Its products move to ?reassign_to=<category id>,
+// or to the deleted category's parent when not given (uncategorized for top-level
+// categories). Subcategories move up to the deleted category's parent.
+func (h *Handler) Delete(c *gin.Context) {
+	tenantID := c.GetString("tenant_id")
+
+	var cat database.Category
+	if err := h.db.Where("id = ? AND tenant_id = ?", c.Param("id"), tenantID).First(&cat).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Category not found"})
+		return
+	}
+
+	target := cat.ParentID
+	if reassignTo := c.Query("reassign_to"); reassignTo != "" {
+		targetID, err := uuid.Parse(reassignTo)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid reassign_to"})
+			return
+		}
+		tree := categories.Load(h.db, tenantID)
+		if !tree.Exists(targetID) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Category to reassign products to not found"})
+			return
+		}
+		for _, id := range tree.WithDescendants(cat.ID) {
+			if id == targetID {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Products cannot be reassigned to the category being deleted"})
+				return
+			}
+		}
+		target = &targetID
+	}
+
+	tx := h.db.Begin()
+
+	// Unscoped so soft-deleted products don't keep pointing at a deleted category
+	moved := tx.Unscoped().Model(&database.Product{}).
+		Where("tenant_id = ? AND category_id = ?", tenantID, cat.ID).
+		Update("category_id", target)
+	if moved.Error != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reassign products"})
+		return
+	}
+
+	if err := tx.Model(&database.Category{}).
+		Where("tenant_id = ? AND parent_id = ?", tenantID, cat.ID).
+		Update("parent_id", cat.ParentID).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to move subcategories"})
+		return
+	}
+
+	if err := tx.Delete(&cat).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete category"})
+		return
+	}
+	tx.Commit()
+
+	// Log activity
+	h.logger.LogDelete(c, "category", cat.ID, map[string]interface{}{
+		"name":              cat.Name,
+		"products_moved":    moved.RowsAffected,
+		"products_moved_to": target,
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":        "Category deleted",
+		"products_moved": moved.RowsAffected,
+	})
+}
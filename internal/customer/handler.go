@@ -2,6 +2,7 @@ package customer
 
 import (
 	"net/http"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -27,6 +28,7 @@ type CreateCustomerRequest struct {
 	Phone   string `json:"phone"`
 	Email   string `json:"email"`
 	Address string `json:"address"`
+	Group   string `json:"group"` // Pricing group used by price lists
 }
 
 // List returns all customers for the tenant
@@ -66,6 +68,7 @@ func (h *Handler) Create(c *gin.Context) {
 		Phone:    req.Phone,
 		Email:    req.Email,
 		Address:  req.Address,
+		Group:    strings.TrimSpace(req.Group),
 	}
 
 	if err := h.db.Create(&customer).Error; err != nil {
@@ -126,6 +129,7 @@ func (h *Handler) Update(c *gin.Context) {
 	customer.Phone = req.Phone
 	customer.Email = req.Email
 	customer.Address = req.Address
+	customer.Group = strings.TrimSpace(req.Group)
 
 	if err := h.db.Save(&customer).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update customer"})
@@ -6,6 +6,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/yuditriaji/warungin-backend/pkg/database"
+	"github.com/yuditriaji/warungin-backend/pkg/stock"
 	"gorm.io/gorm"
 )
 
@@ -95,15 +96,16 @@ func (h *Handler) GetStats(c *gin.Context) {
 	// Product counts
 	var totalProducts int64
 	h.db.Model(&database.Product{}).
-		Where("tenant_id = ? AND is_active = ?", tenantID, true).
+		Where("tenant_id = ? AND is_active = ? AND parent_id IS NULL", tenantID, true).
 		Count(&totalProducts)
 	stats.TotalProducts = int(totalProducts)
 
-	var lowStockProducts int64
-	h.db.Model(&database.Product{}).
-		Where("tenant_id = ? AND is_active = ? AND stock_qty < ?", tenantID, true, 10).
-		Count(&lowStockProducts)
-	stats.LowStockProducts = int(lowStockProducts)
+	// Low and out of stock, counted as the inventory summary does
+	var products []database.Product
+	h.db.Where("tenant_id = ? AND is_active = ? AND has_variants = ? AND is_bundle = ?", tenantID, true, false, false).
+		Find(&products)
+	low, out := stock.Levels(h.db, tenantID, products)
+	stats.LowStockProducts = low + out
 
 	c.JSON(http.StatusOK, gin.H{"data": stats})
 }
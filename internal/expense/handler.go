@@ -0,0 +1,311 @@
+package expense
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/yuditriaji/warungin-backend/pkg/activitylog"
+	"github.com/yuditriaji/warungin-backend/pkg/database"
+	"github.com/yuditriaji/warungin-backend/pkg/expenses"
+	"github.com/yuditriaji/warungin-backend/pkg/middleware"
+	"github.com/yuditriaji/warungin-backend/pkg/pagination"
+	"gorm.io/gorm"
+)
+
+type Handler struct {
+	db     *gorm.DB
+	logger *activitylog.Logger
+}
+
+func NewHandler(db *gorm.DB) *Handler {
+	return &Handler{
+		db:     db,
+		logger: activitylog.NewLogger(db),
+	}
+}
+
+type ExpenseRequest struct {
+	OutletID    *uuid.UUID `json:"outlet_id"` // Empty for costs of the whole business
+	Category    string     `json:"category" binding:"required"`
+	Description string     `json:"description"`
+	Amount      float64    `json:"amount" binding:"gt=0"`
+	Date        string     `json:"date" binding:"required"` // YYYY-MM-DD
+}
+
+type RecurringExpenseRequest struct {
+	OutletID    *uuid.UUID `json:"outlet_id"`
+	Category    string     `json:"category" binding:"required"`
+	Description string     `json:"description"`
+	Amount      float64    `json:"amount" binding:"gt=0"`
+	Frequency   string     `json:"frequency" binding:"required"` // weekly, monthly
+	StartDate   string     `json:"start_date" binding:"required"`
+	EndDate     string     `json:"end_date"`
+	IsActive    *bool      `json:"is_active"`
+}
+
+// canManage reports whether the user may see and record expenses, which
+// include salaries
+func canManage(c *gin.Context) bool {
+	if !middleware.IsManager(c.GetString("role")) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Hanya owner dan manager yang dapat mengelola biaya operasional"})
+		return false
+	}
+	return true
+}
+
+// validOutlet checks that an outlet belongs to the tenant
+func (h *Handler) validOutlet(tenantID string, outletID *uuid.UUID) bool {
+	if outletID == nil {
+		return true
+	}
+	var count int64
+	h.db.Model(&database.Outlet{}).Where("id = ? AND tenant_id = ?", *outletID, tenantID).Count(&count)
+	return count > 0
+}
+
+// validDate checks a YYYY-MM-DD date
+func validDate(name, value string) error {
+	if _, err := time.Parse(expenses.DateFormat, value); err != nil {
+		return fmt.Errorf("%s must be YYYY-MM-DD", name)
+	}
+	return nil
+}
+
+// generate records recurring expenses that are due, so lists are current
+// even if the scheduler hasn't run yet today
+func (h *Handler) generate(tenantID string) {
+	if _, err := expenses.Generate(h.db, tenantID, expenses.Today(h.db, tenantID)); err != nil {
+		log.Printf("Failed to record recurring expenses for tenant %s: %v", tenantID, err)
+	}
+}
+
+// List returns expenses, newest first, with their total. Query parameters:
+//
+//	start_date, end_date  YYYY-MM-DD, inclusive
+//	outlet_id      an outlet, or "none" for whole-business expenses
+//	category
+//	page, per_page default 1 and 50
+func (h *Handler) List(c *gin.Context) {
+	if !canManage(c) {
+		return
+	}
+	tenantID := c.GetString("tenant_id")
+	h.generate(tenantID)
+
+	query := h.db.Model(&database.Expense{}).Where("tenant_id = ?", tenantID)
+	for _, bound := range []struct{ param, op string }{{"start_date", ">="}, {"end_date", "<="}} {
+		if value := c.Query(bound.param); value != "" {
+			if err := validDate(bound.param, value); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			query = query.Where("date "+bound.op+" ?", value)
+		}
+	}
+	switch outletID := c.Query("outlet_id"); outletID {
+	case "":
+	case "none":
+		query = query.Where("outlet_id IS NULL")
+	default:
+		query = query.Where("outlet_id = ?", outletID)
+	}
+	if category := c.Query("category"); category != "" {
+		query = query.Where("category = ?", category)
+	}
+	query = query.Session(&gorm.Session{})
+
+	var summary struct {
+		Count  int64
+		Amount float64
+	}
+	if err := query.Select("COUNT(*) AS count, COALESCE(SUM(amount), 0) AS amount").Scan(&summary).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch expenses"})
+		return
+	}
+
+	page, _ := pagination.FromQuery(c)
+	var list []database.Expense
+	if err := page.Apply(query).
+		Preload("Outlet").
+		Order("date DESC, created_at DESC").
+		Find(&list).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch expenses"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":         list,
+		"total_amount": summary.Amount,
+		"pagination":   page.Meta(summary.Count),
+	})
+}
+
+// validate normalizes and checks an expense, returning an error message
+func (h *Handler) validate(tenantID string, req *ExpenseRequest) string {
+	req.Category = strings.TrimSpace(req.Category)
+	if req.Category == "" {
+		return "Category is required"
+	}
+	if err := validDate("date", req.Date); err != nil {
+		return err.Error()
+	}
+	if !h.validOutlet(tenantID, req.OutletID) {
+		return "Outlet not found"
+	}
+	return ""
+}
+
+// Create records an expense
+func (h *Handler) Create(c *gin.Context) {
+	if !canManage(c) {
+		return
+	}
+	tenantID := c.GetString("tenant_id")
+	tenantUUID, _ := uuid.Parse(tenantID)
+
+	var req ExpenseRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if msg := h.validate(tenantID, &req); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	expense := database.Expense{
+		TenantID:    tenantUUID,
+		OutletID:    req.OutletID,
+		Category:    req.Category,
+		Description: req.Description,
+		Amount:      req.Amount,
+		Date:        req.Date,
+	}
+	if userID, err := uuid.Parse(c.GetString("user_id")); err == nil {
+		expense.UserID = &userID
+	}
+	if err := h.db.Create(&expense).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create expense"})
+		return
+	}
+
+	h.logger.LogCreate(c, "expense", expense.ID, map[string]interface{}{
+		"category": expense.Category,
+		"amount":   expense.Amount,
+		"date":     expense.Date,
+	})
+
+	c.JSON(http.StatusCreated, gin.H{"data": expense})
+}
+
+// Update changes an expense
+func (h *Handler) Update(c *gin.Context) {
+	if !canManage(c) {
+		return
+	}
+	tenantID := c.GetString("tenant_id")
+
+	var expense database.Expense
+	if err := h.db.Where("id = ? AND tenant_id = ?", c.Param("id"), tenantID).First(&expense).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Expense not found"})
+		return
+	}
+
+	var req ExpenseRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if msg := h.validate(tenantID, &req); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	oldValues := map[string]interface{}{
+		"category": expense.Category,
+		"amount":   expense.Amount,
+		"date":     expense.Date,
+	}
+
+	expense.OutletID = req.OutletID
+	expense.Category = req.Category
+	expense.Description = req.Description
+	expense.Amount = req.Amount
+	expense.Date = req.Date
+	if err := h.db.Save(&expense).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update expense"})
+		return
+	}
+
+	h.logger.LogUpdate(c, "expense", expense.ID, oldValues, map[string]interface{}{
+		"category": expense.Category,
+		"amount":   expense.Amount,
+		"date":     expense.Date,
+	})
+
+	c.JSON(http.StatusOK, gin.H{"data": expense})
+}
+
+// Delete removes an expense. A deleted recurring entry is not recorded again.
+func (h *Handler) Delete(c *gin.Context) {
+	if !canManage(c) {
+		return
+	}
+	tenantID := c.GetString("tenant_id")
+
+	var expense database.Expense
+	if err := h.db.Where("id = ? AND tenant_id = ?", c.Param("id"), tenantID).First(&expense).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Expense not found"})
+		return
+	}
+
+	if err := h.db.Delete(&expense).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete expense"})
+		return
+	}
+
+	h.logger.LogDelete(c, "expense", expense.ID, map[string]interface{}{
+		"category": expense.Category,
+		"amount":   expense.Amount,
+		"date":     expense.Date,
+	})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Expense deleted"})
+}
+
+// Categories returns the default categories followed by the other ones the
+// tenant has used
+func (h *Handler) Categories(c *gin.Context) {
+	if !canManage(c) {
+		return
+	}
+	tenantID := c.GetString("tenant_id")
+
+	var used []string
+	h.db.Model(&database.Expense{}).Where("tenant_id = ?", tenantID).Distinct("category").Pluck("category", &used)
+	var recurring []string
+	h.db.Model(&database.RecurringExpense{}).Where("tenant_id = ?", tenantID).Distinct("category").Pluck("category", &recurring)
+
+	seen := make(map[string]bool)
+	categories := []string{}
+	for _, name := range expenses.DefaultCategories {
+		seen[strings.ToLower(name)] = true
+		categories = append(categories, name)
+	}
+	var custom []string
+	for _, name := range append(used, recurring...) {
+		if !seen[strings.ToLower(name)] {
+			seen[strings.ToLower(name)] = true
+			custom = append(custom, name)
+		}
+	}
+	sort.Strings(custom)
+
+	c.JSON(http.StatusOK, gin.H{"data": append(categories, custom...)})
+}
@@ -0,0 +1,203 @@
+package expense
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/yuditriaji/warungin-backend/pkg/database"
+	"github.com/yuditriaji/warungin-backend/pkg/expenses"
+)
+
+// validateRecurring normalizes and checks a recurring expense, returning an
+// error message
+func (h *Handler) validateRecurring(tenantID string, req *RecurringExpenseRequest) string {
+	req.Category = strings.TrimSpace(req.Category)
+	if req.Category == "" {
+		return "Category is required"
+	}
+	if !expenses.ValidFrequency(req.Frequency) {
+		return "frequency must be weekly or monthly"
+	}
+	if err := validDate("start_date", req.StartDate); err != nil {
+		return err.Error()
+	}
+	if req.EndDate != "" {
+		if err := validDate("end_date", req.EndDate); err != nil {
+			return err.Error()
+		}
+		if req.EndDate < req.StartDate {
+			return "end_date must not be before start_date"
+		}
+	}
+	if !h.validOutlet(tenantID, req.OutletID) {
+		return "Outlet not found"
+	}
+	return ""
+}
+
+// ListRecurring returns the tenant's recurring expenses
+func (h *Handler) ListRecurring(c *gin.Context) {
+	if !canManage(c) {
+		return
+	}
+	tenantID := c.GetString("tenant_id")
+
+	var list []database.RecurringExpense
+	if err := h.db.Where("tenant_id = ?", tenantID).
+		Preload("Outlet").
+		Order("is_active DESC, category ASC, created_at ASC").
+		Find(&list).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch recurring expenses"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": list})
+}
+
+// CreateRecurring adds a recurring expense. Entries due up to today,
+// including a start date in the past, are recorded straight away.
+func (h *Handler) CreateRecurring(c *gin.Context) {
+	if !canManage(c) {
+		return
+	}
+	tenantID := c.GetString("tenant_id")
+	tenantUUID, _ := uuid.Parse(tenantID)
+
+	var req RecurringExpenseRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if msg := h.validateRecurring(tenantID, &req); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	recurring := database.RecurringExpense{
+		TenantID:    tenantUUID,
+		OutletID:    req.OutletID,
+		Category:    req.Category,
+		Description: req.Description,
+		Amount:      req.Amount,
+		Frequency:   req.Frequency,
+		StartDate:   req.StartDate,
+		EndDate:     req.EndDate,
+		NextDate:    req.StartDate,
+		IsActive:    true,
+	}
+	if req.IsActive != nil {
+		recurring.IsActive = *req.IsActive
+	}
+	if err := h.db.Create(&recurring).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create recurring expense"})
+		return
+	}
+
+	h.logger.LogCreate(c, "recurring_expense", recurring.ID, map[string]interface{}{
+		"category":  recurring.Category,
+		"amount":    recurring.Amount,
+		"frequency": recurring.Frequency,
+	})
+
+	h.generate(tenantID)
+	h.db.First(&recurring, recurring.ID)
+	c.JSON(http.StatusCreated, gin.H{"data": recurring})
+}
+
+// UpdateRecurring changes a recurring expense. Entries already recorded are
+// kept; new ones follow the new schedule from the day after the last one.
+func (h *Handler) UpdateRecurring(c *gin.Context) {
+	if !canManage(c) {
+		return
+	}
+	tenantID := c.GetString("tenant_id")
+
+	var recurring database.RecurringExpense
+	if err := h.db.Where("id = ? AND tenant_id = ?", c.Param("id"), tenantID).First(&recurring).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Recurring expense not found"})
+		return
+	}
+
+	var req RecurringExpenseRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if msg := h.validateRecurring(tenantID, &req); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	oldValues := map[string]interface{}{
+		"category":  recurring.Category,
+		"amount":    recurring.Amount,
+		"frequency": recurring.Frequency,
+		"is_active": recurring.IsActive,
+	}
+
+	// Continue after the last recorded entry rather than repeating it
+	var last database.Expense
+	from, _ := time.Parse(expenses.DateFormat, req.StartDate)
+	if err := h.db.Unscoped().Where("recurring_expense_id = ?", recurring.ID).Order("date DESC").First(&last).Error; err == nil {
+		if after, err := time.Parse(expenses.DateFormat, last.Date); err == nil && !after.Before(from) {
+			from = after.AddDate(0, 0, 1)
+		}
+	}
+	start, _ := time.Parse(expenses.DateFormat, req.StartDate)
+
+	recurring.OutletID = req.OutletID
+	recurring.Category = req.Category
+	recurring.Description = req.Description
+	recurring.Amount = req.Amount
+	recurring.Frequency = req.Frequency
+	recurring.StartDate = req.StartDate
+	recurring.EndDate = req.EndDate
+	recurring.NextDate = expenses.FirstOnOrAfter(req.Frequency, start, from).Format(expenses.DateFormat)
+	if req.IsActive != nil {
+		recurring.IsActive = *req.IsActive
+	}
+	if err := h.db.Save(&recurring).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update recurring expense"})
+		return
+	}
+
+	h.logger.LogUpdate(c, "recurring_expense", recurring.ID, oldValues, map[string]interface{}{
+		"category":  recurring.Category,
+		"amount":    recurring.Amount,
+		"frequency": recurring.Frequency,
+		"is_active": recurring.IsActive,
+	})
+
+	h.generate(tenantID)
+	h.db.First(&recurring, recurring.ID)
+	c.JSON(http.StatusOK, gin.H{"data": recurring})
+}
+
+// DeleteRecurring stops a recurring expense; entries already recorded are kept
+func (h *Handler) DeleteRecurring(c *gin.Context) {
+	if !canManage(c) {
+		return
+	}
+	tenantID := c.GetString("tenant_id")
+
+	var recurring database.RecurringExpense
+	if err := h.db.Where("id = ? AND tenant_id = ?", c.Param("id"), tenantID).First(&recurring).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Recurring expense not found"})
+		return
+	}
+
+	if err := h.db.Delete(&recurring).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete recurring expense"})
+		return
+	}
+
+	h.logger.LogDelete(c, "recurring_expense", recurring.ID, map[string]interface{}{
+		"category": recurring.Category,
+		"amount":   recurring.Amount,
+	})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Recurring expense deleted"})
+}
@@ -0,0 +1,45 @@
+package expense
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/yuditriaji/warungin-backend/pkg/expenses"
+	"gorm.io/gorm"
+)
+
+// Scheduler records recurring expenses as they fall due
+type Scheduler struct {
+	db *gorm.DB
+}
+
+// NewScheduler creates a new recurring expense scheduler
+func NewScheduler(db *gorm.DB) *Scheduler {
+	return &Scheduler{db: db}
+}
+
+// Start begins the scheduler loop (runs every hour, so each tenant's
+// entries are recorded soon after midnight in its time zone)
+func (s *Scheduler) Start() {
+	ticker := time.NewTicker(1 * time.Hour)
+	go func() {
+		// Run immediately on startup
+		s.Run()
+
+		for range ticker.C {
+			s.Run()
+		}
+	}()
+	fmt.Println("Expense scheduler started (runs every 1 hour)")
+}
+
+// Run records all due recurring expenses
+func (s *Scheduler) Run() {
+	recorded, err := expenses.GenerateAll(s.db)
+	if err != nil {
+		fmt.Printf("Expense scheduler: %v\n", err)
+	}
+	if recorded > 0 {
+		fmt.Printf("Expense scheduler: recorded %d recurring expenses\n", recorded)
+	}
+}
@@ -1,12 +1,17 @@
 package inventory
 
 import (
-	"math"
 	"net/http"
+	"sort"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/yuditriaji/warungin-backend/pkg/costing"
 	"github.com/yuditriaji/warungin-backend/pkg/database"
+	"github.com/yuditriaji/warungin-backend/pkg/export"
+	"github.com/yuditriaji/warungin-backend/pkg/recipe"
+	"github.com/yuditriaji/warungin-backend/pkg/stock"
 	"gorm.io/gorm"
 )
 
@@ -27,7 +32,19 @@ type InventoryItem struct {
 	Price            float64   `json:"price"`
 	Cost             float64   `json:"cost"`
 	StockValue       float64   `json:"stock_value"`
-	Status           string    `json:"status"` // ok, low, out
+	MinStockLevel    int       `json:"min_stock_level"`
+	ReorderPoint     int       `json:"reorder_point"`
+	ReorderQty       int       `json:"reorder_qty"`
+	SuggestedQty     int       `json:"suggested_qty"` // Quantity to order now (0 when stock is fine)
+	Status           string    `json:"status"`        // ok, low, out
+}
+
+// AlertItem is a product that needs restocking, with its reorder details
+type AlertItem struct {
+	database.Product
+	ReorderPoint  int     `json:"reorder_point"`
+	AvgDailySales float64 `json:"avg_daily_sales"`
+	SuggestedQty  int     `json:"suggested_qty"`
 }
 
 type InventorySummary struct {
@@ -35,6 +52,10 @@ type InventorySummary struct {
 	TotalStockValue float64 `json:"total_stock_value"`
 	LowStockCount   int     `json:"low_stock_count"`
 	OutOfStockCount int     `json:"out_of_stock_count"`
+	CostingMethod   string  `json:"costing_method"`  // manual, average, fifo
+	ProductValue    float64 `json:"product_value"`   // Product stock at cost using the costing method
+	MaterialValue   float64 `json:"material_value"`  // Raw material stock at cost using the costing method
+	InventoryValue  float64 `json:"inventory_value"` // product_value + material_value
 }
 
 // GetInventory returns inventory status for all products
@@ -42,8 +63,13 @@ func (h *Handler) GetInventory(c *gin.Context) {
 	tenantID := c.GetString("tenant_id")
 	filter := c.Query("filter") // all, low, out
 	outletID := c.Query("outlet_id")
+	format, ok := export.Requested(c)
+	if !ok {
+		return
+	}
 
-	query := h.db.Where("tenant_id = ? AND is_active = ?", tenantID, true)
+	// Products with variants and bundles hold no stock themselves; variants are listed instead
+	query := h.db.Where("tenant_id = ? AND is_active = ? AND has_variants = ? AND is_bundle = ?", tenantID, true, false, false)
 	
 	// Filter by outlet if specified
 	if outletID != "" {
@@ -53,21 +79,20 @@ func (h *Handler) GetInventory(c *gin.Context) {
 	var products []database.Product
 	query.Order("name ASC").Find(&products)
 
+	reorderInfo := stock.ReorderPoints(h.db, tenantID, products)
+	calc := recipe.NewCalculator(h.db)
+
 	var items []InventoryItem
 	for _, p := range products {
 		stockQty := p.StockQty
 
 		// Calculate stock from materials if UseMaterialStock is true
 		if p.UseMaterialStock {
-			stockQty = h.calculateMaterialStock(p.ID)
+			stockQty = stock.MaterialStock(h.db, p.ID)
 		}
 
-		status := "ok"
-		if stockQty <= 0 {
-			status = "out"
-		} else if stockQty < 10 {
-			status = "low"
-		}
+		info := reorderInfo[p.ID]
+		status := stock.Status(stockQty, info.ReorderPoint)
 
 		// Apply filter
 		if filter == "low" && status != "low" {
@@ -80,7 +105,7 @@ func (h *Handler) GetInventory(c *gin.Context) {
 		// Calculate cost for material-driven products
 		cost := p.Cost
 		if p.UseMaterialStock && cost <= 0 {
-			cost = h.calculateMaterialCost(p.ID)
+			cost = calc.ProductMaterialCost(p.ID)
 		}
 
 		// Calculate stock value using cost, or fallback to price
@@ -98,62 +123,35 @@ func (h *Handler) GetInventory(c *gin.Context) {
 			Price:            p.Price,
 			Cost:             cost,
 			StockValue:       float64(stockQty) * stockValueCost,
+			MinStockLevel:    p.MinStockLevel,
+			ReorderPoint:     info.ReorderPoint,
+			ReorderQty:       p.ReorderQty,
+			SuggestedQty:     stock.SuggestedOrderQty(p, stockQty, info),
 			Status:           status,
 		})
 	}
 
-	c.JSON(http.StatusOK, gin.H{"data": items})
-}
-
-// calculateMaterialStock returns the max units that can be made from available materials
-func (h *Handler) calculateMaterialStock(productID uuid.UUID) int {
-	var productMaterials []database.ProductMaterial
-	h.db.Where("product_id = ?", productID).Preload("Material").Find(&productMaterials)
-
-	if len(productMaterials) == 0 {
-		return 0
-	}
-
-	availableStock := math.MaxFloat64
-	for _, pm := range productMaterials {
-		if pm.QuantityUsed <= 0 {
-			continue
-		}
-		
-		// Account for conversion rate (recipe_qty × conversion = actual material usage)
-		convRate := pm.ConversionRate
-		if convRate <= 0 {
-			convRate = 1
+	if format != "" {
+		w, err := export.Start(c, format, "stok_"+time.Now().Format("20060102"), "Laporan Stok "+time.Now().Format("2006-01-02"), []export.Column{
+			{Header: "Produk", Width: 2.2}, {Header: "SKU"}, {Header: "Stok", Width: 0.6}, {Header: "Stok Min", Width: 0.6},
+			{Header: "Titik Pesan", Width: 0.7}, {Header: "Saran Pesan", Width: 0.7}, {Header: "Harga"}, {Header: "HPP"},
+			{Header: "Nilai Stok"}, {Header: "Status", Width: 0.6},
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate file"})
+			return
 		}
-		actualUsage := pm.QuantityUsed * convRate
-		
-		canMake := pm.Material.StockQty / actualUsage
-		if canMake < availableStock {
-			availableStock = canMake
+		for _, item := range items {
+			if err := w.Row(item.ProductName, item.SKU, item.StockQty, item.MinStockLevel, item.ReorderPoint,
+				item.SuggestedQty, item.Price, item.Cost, item.StockValue, item.Status); err != nil {
+				break
+			}
 		}
+		w.Close()
+		return
 	}
 
-	if availableStock == math.MaxFloat64 {
-		return 0
-	}
-	return int(math.Floor(availableStock))
-}
-
-// calculateMaterialCost returns the total cost of materials for one product unit
-func (h *Handler) calculateMaterialCost(productID uuid.UUID) float64 {
-	var productMaterials []database.ProductMaterial
-	h.db.Where("product_id = ?", productID).Preload("Material").Find(&productMaterials)
-
-	var totalCost float64
-	for _, pm := range productMaterials {
-		convRate := pm.ConversionRate
-		if convRate <= 0 {
-			convRate = 1
-		}
-		// Cost = quantity × conversion × unit_price
-		totalCost += pm.QuantityUsed * convRate * pm.Material.UnitPrice
-	}
-	return totalCost
+	c.JSON(http.StatusOK, gin.H{"data": items})
 }
 
 // GetSummary returns inventory summary stats
@@ -164,8 +162,8 @@ func (h *Handler) GetSummary(c *gin.Context) {
 	var summary InventorySummary
 
 	// Build base query conditions
-	baseCondition := "tenant_id = ? AND is_active = ?"
-	baseArgs := []interface{}{tenantID, true}
+	baseCondition := "tenant_id = ? AND is_active = ? AND has_variants = ? AND is_bundle = ?"
+	baseArgs := []interface{}{tenantID, true, false, false}
 	
 	if outletID != "" {
 		baseCondition += " AND outlet_id = ?"
@@ -189,19 +187,16 @@ func (h *Handler) GetSummary(c *gin.Context) {
 		Scan(&stockValue)
 	summary.TotalStockValue = stockValue.Total
 
-	// Low stock count
-	var lowStock int64
-	h.db.Model(&database.Product{}).
-		Where(baseCondition+" AND stock_qty > 0 AND stock_qty < 10", baseArgs...).
-		Count(&lowStock)
-	summary.LowStockCount = int(lowStock)
+	// Inventory valuation at cost using the tenant's costing method
+	summary.CostingMethod = costing.LoadMethod(h.db, tenantID)
+	summary.ProductValue = costing.Valuation(h.db, tenantID, summary.CostingMethod, costing.ItemProduct, outletID)
+	summary.MaterialValue = costing.Valuation(h.db, tenantID, summary.CostingMethod, costing.ItemMaterial, outletID)
+	summary.InventoryValue = summary.ProductValue + summary.MaterialValue
 
-	// Out of stock count
-	var outOfStock int64
-	h.db.Model(&database.Product{}).
-		Where(baseCondition+" AND stock_qty <= 0", baseArgs...).
-		Count(&outOfStock)
-	summary.OutOfStockCount = int(outOfStock)
+	// Low / out of stock counts using each product's reorder point
+	var products []database.Product
+	h.db.Where(baseCondition, baseArgs...).Find(&products)
+	summary.LowStockCount, summary.OutOfStockCount = stock.Levels(h.db, tenantID, products)
 
 	c.JSON(http.StatusOK, gin.H{"data": summary})
 }
@@ -234,27 +229,69 @@ func (h *Handler) UpdateStock(c *gin.Context) {
 		return
 	}
 
-	product.StockQty = newQty
-	h.db.Save(&product)
+	// Go through the costing ledger so fifo layers stay in sync with stock
+	tx := h.db.Begin()
+	ledger := costing.NewLedger(tx, product.TenantID, costing.LoadMethod(h.db, tenantID))
+	if err := ledger.Adjust(costing.ItemProduct, product.ID, float64(req.Quantity)); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update stock"})
+		return
+	}
+	tx.Commit()
+
+	h.db.Where("id = ?", product.ID).First(&product)
 
 	c.JSON(http.StatusOK, gin.H{"data": product})
 }
 
-// GetAlerts returns products that need attention
+// GetAlerts returns products that need attention, based on each product's reorder point
 func (h *Handler) GetAlerts(c *gin.Context) {
 	tenantID := c.GetString("tenant_id")
 
-	var lowStock []database.Product
-	h.db.Where("tenant_id = ? AND is_active = ? AND stock_qty > 0 AND stock_qty < 10", tenantID, true).
-		Order("stock_qty ASC").
-		Limit(10).
-		Find(&lowStock)
-
-	var outOfStock []database.Product
-	h.db.Where("tenant_id = ? AND is_active = ? AND stock_qty <= 0", tenantID, true).
+	var products []database.Product
+	h.db.Where("tenant_id = ? AND is_active = ? AND has_variants = ? AND is_bundle = ?", tenantID, true, false, false).
 		Order("name ASC").
-		Limit(10).
-		Find(&outOfStock)
+		Find(&products)
+
+	reorderInfo := stock.ReorderPoints(h.db, tenantID, products)
+
+	lowStock := []AlertItem{}
+	outOfStock := []AlertItem{}
+	for _, p := range products {
+		stockQty := p.StockQty
+		if p.UseMaterialStock {
+			stockQty = stock.MaterialStock(h.db, p.ID)
+			p.StockQty = stockQty
+		}
+
+		info := reorderInfo[p.ID]
+		item := AlertItem{
+			Product:       p,
+			ReorderPoint:  info.ReorderPoint,
+			AvgDailySales: info.AvgDailySales,
+			SuggestedQty:  stock.SuggestedOrderQty(p, stockQty, info),
+		}
+
+		switch stock.Status(stockQty, info.ReorderPoint) {
+		case "low":
+			lowStock = append(lowStock, item)
+		case "out":
+			outOfStock = append(outOfStock, item)
+		}
+	}
+
+	// Most urgent first: lowest stock relative to its reorder point
+	sort.SliceStable(lowStock, func(i, j int) bool {
+		return float64(lowStock[i].StockQty)/float64(lowStock[i].ReorderPoint) <
+			float64(lowStock[j].StockQty)/float64(lowStock[j].ReorderPoint)
+	})
+
+	if len(lowStock) > 10 {
+		lowStock = lowStock[:10]
+	}
+	if len(outOfStock) > 10 {
+		outOfStock = outOfStock[:10]
+	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"data": gin.H{
@@ -2,371 +2,599 @@ package inventory
 
 import (
 	"encoding/csv"
+	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/xuri/excelize/v2"
+	"github.com/yuditriaji/warungin-backend/pkg/costing"
 	"github.com/yuditriaji/warungin-backend/pkg/database"
+	"github.com/yuditriaji/warungin-backend/pkg/export"
+	"github.com/yuditriaji/warungin-backend/pkg/units"
 	"gorm.io/gorm"
 )
 
+// How long an uploaded file can be committed after preview
+const importBatchTTL = time.Hour
+
+// Importable entities
+const (
+	EntityProduct  = "product"
+	EntityMaterial = "material"
+	EntityRecipe   = "recipe"
+	EntityCustomer = "customer"
+	EntityCategory = "category"
+)
+
+// Stock modes (products and materials)
+const (
+	ModeSet = "set" // Stock column replaces current stock
+	ModeAdd = "add" // Stock column is added to current stock
+)
+
+// Row actions in a preview
+const (
+	ActionCreate = "create"
+	ActionUpdate = "update"
+	ActionSkip   = "skip"
+	ActionError  = "error"
+)
+
+// Field is a column an entity can import and export
+type Field struct {
+	Key      string   `json:"key"`
+	Header   string   `json:"header"` // Header used in templates and exports
+	Aliases  []string `json:"-"`      // Other headers recognised without a mapping
+	Required bool     `json:"required"`
+}
+
+// importEntity is implemented by each importable entity
+type importEntity interface {
+	fields() []Field
+	// plan validates a row against current data and decides what commit would do
+	plan(ctx *planContext, row *PreviewRow)
+	// apply writes a planned create/update row
+	apply(ctx *applyContext, row PreviewRow) error
+	// export returns the entity's rows in field order
+	export(db *gorm.DB, tenantID, outletID string) [][]interface{}
+	// samples returns example rows for the template
+	samples() [][]interface{}
+}
+
 type ImportHandler struct {
-	db *gorm.DB
+	db       *gorm.DB
+	entities map[string]importEntity
 }
 
 func NewImportHandler(db *gorm.DB) *ImportHandler {
-	return &ImportHandler{db: db}
+	return &ImportHandler{
+		db: db,
+		entities: map[string]importEntity{
+			EntityProduct:  productImport{},
+			EntityMaterial: materialImport{},
+			EntityRecipe:   recipeImport{},
+			EntityCustomer: customerImport{},
+			EntityCategory: categoryImport{},
+		},
+	}
 }
 
-type ImportResult struct {
-	TotalRows    int      `json:"total_rows"`
-	SuccessCount int      `json:"success_count"`
-	FailedCount  int      `json:"failed_count"`
-	Errors       []string `json:"errors"`
+// Record is one spreadsheet row, keyed by field
+type Record struct {
+	Line   int               `json:"line"` // Row number in the spreadsheet
+	Values map[string]string `json:"values"`
 }
 
-type ImportRow struct {
-	ProductName string
-	SKU         string
-	StockQty    int
-	Price       float64
-	Cost        float64
+// PreviewRow is a row with what commit would do with it
+type PreviewRow struct {
+	Record
+	Action  string     `json:"action"` // create, update, skip, error
+	Message string     `json:"message,omitempty"`
+	MatchID *uuid.UUID `json:"match_id,omitempty"` // Existing record that would be updated
+	data    interface{}
 }
 
-// ImportExcel handles Excel/CSV file upload for bulk inventory import
-func (h *ImportHandler) ImportExcel(c *gin.Context) {
-	tenantID := c.GetString("tenant_id")
-	tenantUUID, _ := uuid.Parse(tenantID)
-	
-	// Get outlet_id if provided
-	outletIDStr := c.PostForm("outlet_id")
-	var outletID *uuid.UUID
-	if outletIDStr != "" {
-		parsed, err := uuid.Parse(outletIDStr)
-		if err == nil {
-			outletID = &parsed
-		}
-	}
+type ImportPreview struct {
+	BatchID     uuid.UUID         `json:"batch_id"`
+	Entity      string            `json:"entity"`
+	Mode        string            `json:"mode"`
+	Fields      []Field           `json:"fields"`
+	Headers     []string          `json:"headers"`
+	Mapping     map[string]string `json:"mapping"` // field -> column header used
+	TotalRows   int               `json:"total_rows"`
+	CreateCount int               `json:"create_count"`
+	UpdateCount int               `json:"update_count"`
+	SkipCount   int               `json:"skip_count"`
+	ErrorCount  int               `json:"error_count"`
+	Rows        []PreviewRow      `json:"rows"`
+	ExpiresAt   time.Time         `json:"expires_at"`
+}
 
-	// Get uploaded file
-	file, header, err := c.Request.FormFile("file")
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "No file uploaded"})
-		return
-	}
-	defer file.Close()
-
-	// Parse file based on extension
-	var rows []ImportRow
-	fileName := strings.ToLower(header.Filename)
-	
-	if strings.HasSuffix(fileName, ".xlsx") || strings.HasSuffix(fileName, ".xls") {
-		rows, err = h.parseExcel(file)
-	} else if strings.HasSuffix(fileName, ".csv") {
-		rows, err = h.parseCSV(file)
-	} else {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Unsupported file format. Please upload .xlsx or .csv"})
-		return
-	}
+// planContext is shared by all rows of one plan
+type planContext struct {
+	db       *gorm.DB
+	tenantID string
+	mode     string
+	method   string
+	units    *units.Catalog
+	seen     map[string]int // duplicate key -> first line
+}
 
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Failed to parse file: %v", err)})
-		return
+// duplicate records keys for a row and returns the line that used one first
+func (p *planContext) duplicate(line int, keys ...string) (int, bool) {
+	for _, key := range keys {
+		if first, ok := p.seen[key]; ok {
+			return first, true
+		}
 	}
-
-	// Process rows
-	result := ImportResult{
-		TotalRows: len(rows),
-		Errors:    []string{},
+	for _, key := range keys {
+		p.seen[key] = line
 	}
+	return 0, false
+}
 
-	for i, row := range rows {
-		if row.ProductName == "" {
-			result.Errors = append(result.Errors, fmt.Sprintf("Row %d: Product name is required", i+2))
-			result.FailedCount++
-			continue
+// applyContext is shared by all rows of one commit
+type applyContext struct {
+	tx     *gorm.DB
+	batch  database.ImportBatch
+	ledger *costing.Ledger
+}
+
+// Preview parses an uploaded Excel/CSV file and returns a preview of the
+// import without writing anything. The preview is applied with Commit.
+//
+// Form fields: file, outlet_id (optional), mode (set|add, default set) and
+// mapping (optional JSON object of field -> column header, e.g.
+// {"name":"Nama Barang","stock":"Qty"}; unmapped fields use the default headers).
+func (h *ImportHandler) Preview(entity string) gin.HandlerFunc {
+	e := h.entities[entity]
+
+	return func(c *gin.Context) {
+		tenantID := c.GetString("tenant_id")
+		tenantUUID, _ := uuid.Parse(tenantID)
+		userUUID, _ := uuid.Parse(c.GetString("user_id"))
+
+		// Get outlet_id if provided
+		outletIDStr := c.PostForm("outlet_id")
+		var outletID *uuid.UUID
+		if outletIDStr != "" {
+			parsed, err := uuid.Parse(outletIDStr)
+			if err == nil {
+				outletID = &parsed
+			}
 		}
 
-		// Check if product exists by SKU or name
-		var existingProduct database.Product
-		var found bool
+		mode := c.DefaultPostForm("mode", ModeSet)
+		if mode != ModeSet && mode != ModeAdd {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "mode must be set or add"})
+			return
+		}
 
-		if row.SKU != "" {
-			if err := h.db.Where("tenant_id = ? AND sku = ?", tenantID, row.SKU).First(&existingProduct).Error; err == nil {
-				found = true
+		mapping := map[string]string{}
+		if raw := c.PostForm("mapping"); raw != "" {
+			if err := json.Unmarshal([]byte(raw), &mapping); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid mapping"})
+				return
 			}
 		}
 
-		if !found {
-			if err := h.db.Where("tenant_id = ? AND name = ?", tenantID, row.ProductName).First(&existingProduct).Error; err == nil {
-				found = true
-			}
+		// Get uploaded file
+		file, header, err := c.Request.FormFile("file")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "No file uploaded"})
+			return
 		}
+		defer file.Close()
 
-		if found {
-			// Update existing product stock
-			updates := map[string]interface{}{
-				"stock_qty": row.StockQty,
-			}
-			if row.Price > 0 {
-				updates["price"] = row.Price
-			}
-			if row.Cost > 0 {
-				updates["cost"] = row.Cost
-			}
+		// Read file based on extension
+		var records [][]string
+		fileName := strings.ToLower(header.Filename)
 
-			if err := h.db.Model(&existingProduct).Updates(updates).Error; err != nil {
-				result.Errors = append(result.Errors, fmt.Sprintf("Row %d: Failed to update %s - %v", i+2, row.ProductName, err))
-				result.FailedCount++
-				continue
-			}
-			result.SuccessCount++
+		if strings.HasSuffix(fileName, ".xlsx") || strings.HasSuffix(fileName, ".xls") {
+			records, err = readExcel(file)
+		} else if strings.HasSuffix(fileName, ".csv") {
+			records, err = readCSV(file)
 		} else {
-			// Create new product
-			newProduct := database.Product{
-				TenantID: tenantUUID,
-				OutletID: outletID,
-				Name:     row.ProductName,
-				SKU:      row.SKU,
-				StockQty: row.StockQty,
-				Price:    row.Price,
-				Cost:     row.Cost,
-				IsActive: true,
-			}
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unsupported file format. Please upload .xlsx or .csv"})
+			return
+		}
 
-			if err := h.db.Create(&newProduct).Error; err != nil {
-				result.Errors = append(result.Errors, fmt.Sprintf("Row %d: Failed to create %s - %v", i+2, row.ProductName, err))
-				result.FailedCount++
-				continue
-			}
-			result.SuccessCount++
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Failed to parse file: %v", err)})
+			return
 		}
-	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"data":    result,
-		"message": fmt.Sprintf("Import completed: %d success, %d failed", result.SuccessCount, result.FailedCount),
-	})
-}
+		columns, used, err := resolveColumns(e.fields(), records[0], mapping)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 
-// parseExcel parses .xlsx files
-func (h *ImportHandler) parseExcel(file io.Reader) ([]ImportRow, error) {
-	f, err := excelize.OpenReader(file)
-	if err != nil {
-		return nil, err
-	}
-	defer f.Close()
+		rows := parseRows(records, columns)
+		rowsJSON, _ := json.Marshal(rows)
+		mappingJSON, _ := json.Marshal(used)
+
+		// Drop this tenant's expired batches while we're here
+		h.db.Unscoped().Where("tenant_id = ? AND expires_at < ?", tenantID, time.Now()).Delete(&database.ImportBatch{})
+
+		batch := database.ImportBatch{
+			TenantID:  tenantUUID,
+			UserID:    userUUID,
+			OutletID:  outletID,
+			Entity:    entity,
+			FileName:  header.Filename,
+			Mode:      mode,
+			Mapping:   string(mappingJSON),
+			Rows:      string(rowsJSON),
+			Status:    "pending",
+			ExpiresAt: time.Now().Add(importBatchTTL),
+		}
+		if err := h.db.Create(&batch).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save import preview"})
+			return
+		}
 
-	// Get first sheet
-	sheets := f.GetSheetList()
-	if len(sheets) == 0 {
-		return nil, fmt.Errorf("no sheets found in file")
-	}
+		preview := h.plan(h.db, e, batch, rows)
+		preview.Headers = records[0]
 
-	rows, err := f.GetRows(sheets[0])
-	if err != nil {
-		return nil, err
+		c.JSON(http.StatusOK, gin.H{
+			"data": preview,
+			"message": fmt.Sprintf("Preview: %d create, %d update, %d skip, %d error",
+				preview.CreateCount, preview.UpdateCount, preview.SkipCount, preview.ErrorCount),
+		})
 	}
+}
 
-	if len(rows) < 2 {
-		return nil, fmt.Errorf("file must have header row and at least one data row")
-	}
+// Commit applies a previewed import in a single database transaction.
+// Rows are re-checked against current data; if any row fails nothing is written.
+func (h *ImportHandler) Commit(entity string) gin.HandlerFunc {
+	e := h.entities[entity]
 
-	// Find column indices from header
-	header := rows[0]
-	colMap := make(map[string]int)
-	for i, cell := range header {
-		normalized := strings.ToLower(strings.TrimSpace(cell))
-		colMap[normalized] = i
-	}
+	return func(c *gin.Context) {
+		tenantID := c.GetString("tenant_id")
+		batchID := c.Param("id")
 
-	// Parse data rows
-	var result []ImportRow
-	for _, row := range rows[1:] {
-		if len(row) == 0 {
-			continue
+		var batch database.ImportBatch
+		if err := h.db.Where("id = ? AND tenant_id = ? AND entity = ?", batchID, tenantID, entity).First(&batch).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Import not found"})
+			return
+		}
+		if batch.Status != "pending" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Import has already been committed"})
+			return
+		}
+		if time.Now().After(batch.ExpiresAt) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Import preview has expired, please upload the file again"})
+			return
 		}
 
-		importRow := ImportRow{}
+		var rows []Record
+		json.Unmarshal([]byte(batch.Rows), &rows)
 
-		// Product Name (required) - check common column names
-		for _, col := range []string{"nama produk", "product name", "nama", "name", "produk"} {
-			if idx, ok := colMap[col]; ok && idx < len(row) {
-				importRow.ProductName = strings.TrimSpace(row[idx])
-				break
-			}
+		tx := h.db.Begin()
+
+		// Claim the batch first, so a second commit of it finds nothing pending
+		claim := tx.Model(&database.ImportBatch{}).Where("id = ? AND status = ?", batch.ID, "pending").Update("status", "committed")
+		if claim.Error != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit import"})
+			return
+		}
+		if claim.RowsAffected != 1 {
+			tx.Rollback()
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Import has already been committed"})
+			return
 		}
 
-		// SKU
-		for _, col := range []string{"sku", "kode", "code", "kode produk"} {
-			if idx, ok := colMap[col]; ok && idx < len(row) {
-				importRow.SKU = strings.TrimSpace(row[idx])
-				break
-			}
+		preview := h.plan(tx, e, batch, rows)
+
+		if preview.ErrorCount > 0 {
+			tx.Rollback()
+			c.JSON(http.StatusUnprocessableEntity, gin.H{
+				"error": fmt.Sprintf("Import rejected: %d rows have errors", preview.ErrorCount),
+				"data":  preview,
+			})
+			return
 		}
 
-		// Stock Qty
-		for _, col := range []string{"stok", "stock", "qty", "jumlah", "stock qty"} {
-			if idx, ok := colMap[col]; ok && idx < len(row) {
-				if val, err := strconv.Atoi(strings.TrimSpace(row[idx])); err == nil {
-					importRow.StockQty = val
-				}
-				break
+		ctx := &applyContext{
+			tx:     tx,
+			batch:  batch,
+			ledger: costing.NewLedger(tx, batch.TenantID, costing.LoadMethod(h.db, tenantID)),
+		}
+		for _, row := range preview.Rows {
+			if row.Action != ActionCreate && row.Action != ActionUpdate {
+				continue
+			}
+			if err := e.apply(ctx, row); err != nil {
+				tx.Rollback()
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"error": fmt.Sprintf("Row %d: failed to save - %v. No changes were made.", row.Line, err),
+				})
+				return
 			}
 		}
 
-		// Price
-		for _, col := range []string{"harga", "price", "harga jual"} {
-			if idx, ok := colMap[col]; ok && idx < len(row) {
-				if val, err := strconv.ParseFloat(strings.TrimSpace(row[idx]), 64); err == nil {
-					importRow.Price = val
-				}
-				break
-			}
+		if err := tx.Commit().Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit import"})
+			return
 		}
 
-		// Cost
-		for _, col := range []string{"modal", "cost", "harga modal", "cogs"} {
-			if idx, ok := colMap[col]; ok && idx < len(row) {
-				if val, err := strconv.ParseFloat(strings.TrimSpace(row[idx]), 64); err == nil {
-					importRow.Cost = val
-				}
-				break
-			}
+		c.JSON(http.StatusOK, gin.H{
+			"data": preview,
+			"message": fmt.Sprintf("Import completed: %d created, %d updated, %d skipped",
+				preview.CreateCount, preview.UpdateCount, preview.SkipCount),
+		})
+	}
+}
+
+// Template downloads an empty import file with example rows (?format=xlsx|csv)
+func (h *ImportHandler) Template(entity string) gin.HandlerFunc {
+	e := h.entities[entity]
+
+	return func(c *gin.Context) {
+		filename := "template_import_" + entity
+		if entity == EntityProduct {
+			filename = "template_import_stok"
+		}
+		writeSheet(c, filename, c.DefaultQuery("format", "xlsx"), e.fields(), e.samples())
+	}
+}
+
+// Export downloads all of the tenant's records in the import layout
+// (?format=xlsx|csv, optional outlet_id), so the file can be edited and re-imported
+func (h *ImportHandler) Export(entity string) gin.HandlerFunc {
+	e := h.entities[entity]
+
+	return func(c *gin.Context) {
+		tenantID := c.GetString("tenant_id")
+		rows := e.export(h.db, tenantID, c.Query("outlet_id"))
+		filename := fmt.Sprintf("%s_%s", entity, time.Now().Format("20060102"))
+		writeSheet(c, filename, c.DefaultQuery("format", "xlsx"), e.fields(), rows)
+	}
+}
+
+// plan runs an entity's plan over every row and counts the actions
+func (h *ImportHandler) plan(db *gorm.DB, e importEntity, batch database.ImportBatch, rows []Record) ImportPreview {
+	preview := ImportPreview{
+		BatchID:   batch.ID,
+		Entity:    batch.Entity,
+		Mode:      batch.Mode,
+		Fields:    e.fields(),
+		TotalRows: len(rows),
+		Rows:      make([]PreviewRow, 0, len(rows)),
+		ExpiresAt: batch.ExpiresAt,
+	}
+	json.Unmarshal([]byte(batch.Mapping), &preview.Mapping)
+
+	ctx := &planContext{
+		db:       db,
+		tenantID: batch.TenantID.String(),
+		mode:     batch.Mode,
+		method:   costing.LoadMethod(db, batch.TenantID.String()),
+		units:    units.LoadCatalog(db, batch.TenantID.String()),
+		seen:     make(map[string]int),
+	}
+
+	for _, record := range rows {
+		row := PreviewRow{Record: record}
+		if missing := missingRequired(e.fields(), record); missing != "" {
+			row.Action, row.Message = ActionError, missing+" is required"
+		} else {
+			e.plan(ctx, &row)
 		}
 
-		if importRow.ProductName != "" {
-			result = append(result, importRow)
+		switch row.Action {
+		case ActionCreate:
+			preview.CreateCount++
+		case ActionUpdate:
+			preview.UpdateCount++
+		case ActionSkip:
+			preview.SkipCount++
+		default:
+			row.Action = ActionError
+			preview.ErrorCount++
 		}
+		preview.Rows = append(preview.Rows, row)
 	}
+	return preview
+}
 
-	return result, nil
+// missingRequired returns the header of the first empty required field
+func missingRequired(fields []Field, record Record) string {
+	for _, f := range fields {
+		if f.Required && record.Values[f.Key] == "" {
+			return f.Header
+		}
+	}
+	return ""
 }
 
-// parseCSV parses .csv files
-func (h *ImportHandler) parseCSV(file io.Reader) ([]ImportRow, error) {
-	reader := csv.NewReader(file)
-	records, err := reader.ReadAll()
+// fail marks a row as an error
+func (r *PreviewRow) fail(format string, args ...interface{}) {
+	r.Action = ActionError
+	r.Message = fmt.Sprintf(format, args...)
+}
+
+// text returns a trimmed cell value
+func (r *PreviewRow) text(key string) string {
+	return r.Values[key]
+}
+
+// number parses a numeric cell; nil when the cell is empty
+func (r *PreviewRow) number(key, label string) (*float64, error) {
+	val := r.Values[key]
+	if val == "" {
+		return nil, nil
+	}
+	n, err := strconv.ParseFloat(val, 64)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("Invalid value for %s: %s", label, val)
 	}
-
-	if len(records) < 2 {
-		return nil, fmt.Errorf("file must have header row and at least one data row")
+	if n < 0 {
+		return nil, fmt.Errorf("%s cannot be negative", label)
 	}
+	return &n, nil
+}
 
-	// Find column indices from header
-	header := records[0]
-	colMap := make(map[string]int)
-	for i, cell := range header {
-		normalized := strings.ToLower(strings.TrimSpace(cell))
-		colMap[normalized] = i
+// integer parses a whole-number cell; nil when the cell is empty
+func (r *PreviewRow) integer(key, label string) (*int, error) {
+	val := r.Values[key]
+	if val == "" {
+		return nil, nil
+	}
+	n, err := strconv.Atoi(val)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid value for %s: %s", label, val)
 	}
+	return &n, nil
+}
 
-	// Parse data rows (same logic as Excel)
-	var result []ImportRow
-	for _, row := range records[1:] {
-		if len(row) == 0 {
-			continue
+// resolveColumns maps each field to a column index, using the explicit mapping
+// where given and the field's header and aliases otherwise. Returns the header
+// used for each field.
+func resolveColumns(fields []Field, header []string, mapping map[string]string) (map[string]int, map[string]string, error) {
+	known := make(map[string]bool)
+	for _, f := range fields {
+		known[f.Key] = true
+	}
+	for key := range mapping {
+		if !known[key] {
+			return nil, nil, fmt.Errorf("Unknown mapping field: %s", key)
 		}
+	}
 
-		importRow := ImportRow{}
-
-		// Product Name
-		for _, col := range []string{"nama produk", "product name", "nama", "name", "produk"} {
-			if idx, ok := colMap[col]; ok && idx < len(row) {
-				importRow.ProductName = strings.TrimSpace(row[idx])
-				break
-			}
+	index := make(map[string]int)
+	for i, cell := range header {
+		normalized := strings.ToLower(strings.TrimSpace(cell))
+		if _, exists := index[normalized]; !exists {
+			index[normalized] = i
 		}
+	}
 
-		// SKU
-		for _, col := range []string{"sku", "kode", "code", "kode produk"} {
-			if idx, ok := colMap[col]; ok && idx < len(row) {
-				importRow.SKU = strings.TrimSpace(row[idx])
-				break
+	columns := make(map[string]int)
+	used := make(map[string]string)
+	for _, f := range fields {
+		if col, ok := mapping[f.Key]; ok {
+			if strings.TrimSpace(col) == "" {
+				continue // explicitly unmapped
 			}
+			idx, exists := index[strings.ToLower(strings.TrimSpace(col))]
+			if !exists {
+				return nil, nil, fmt.Errorf("Column %q not found for %s", col, f.Key)
+			}
+			columns[f.Key] = idx
+			used[f.Key] = header[idx]
+			continue
 		}
-
-		// Stock Qty
-		for _, col := range []string{"stok", "stock", "qty", "jumlah", "stock qty"} {
-			if idx, ok := colMap[col]; ok && idx < len(row) {
-				if val, err := strconv.Atoi(strings.TrimSpace(row[idx])); err == nil {
-					importRow.StockQty = val
-				}
+		for _, alias := range append([]string{strings.ToLower(f.Header)}, f.Aliases...) {
+			if idx, exists := index[alias]; exists {
+				columns[f.Key] = idx
+				used[f.Key] = header[idx]
 				break
 			}
 		}
+	}
 
-		// Price
-		for _, col := range []string{"harga", "price", "harga jual"} {
-			if idx, ok := colMap[col]; ok && idx < len(row) {
-				if val, err := strconv.ParseFloat(strings.TrimSpace(row[idx]), 64); err == nil {
-					importRow.Price = val
-				}
-				break
-			}
+	for _, f := range fields {
+		if _, ok := columns[f.Key]; f.Required && !ok {
+			return nil, nil, fmt.Errorf("No %s column found. Map the %s field to a column.", f.Header, f.Key)
 		}
+	}
+	return columns, used, nil
+}
 
-		// Cost
-		for _, col := range []string{"modal", "cost", "harga modal", "cogs"} {
-			if idx, ok := colMap[col]; ok && idx < len(row) {
-				if val, err := strconv.ParseFloat(strings.TrimSpace(row[idx]), 64); err == nil {
-					importRow.Cost = val
-				}
-				break
-			}
+// parseRows turns data rows into records. Completely empty rows are dropped.
+func parseRows(records [][]string, columns map[string]int) []Record {
+	var result []Record
+	for i, row := range records[1:] {
+		if strings.TrimSpace(strings.Join(row, "")) == "" {
+			continue
 		}
 
-		if importRow.ProductName != "" {
-			result = append(result, importRow)
+		record := Record{Line: i + 2, Values: make(map[string]string)}
+		for key, idx := range columns {
+			if idx < len(row) {
+				record.Values[key] = export.Unquote(strings.TrimSpace(row[idx]))
+			}
 		}
+		result = append(result, record)
 	}
-
-	return result, nil
+	return result
 }
 
-// DownloadTemplate generates a sample Excel template for import
-func (h *ImportHandler) DownloadTemplate(c *gin.Context) {
-	f := excelize.NewFile()
+// readExcel reads the first sheet of an .xlsx file
+func readExcel(file io.Reader) ([][]string, error) {
+	f, err := excelize.OpenReader(file)
+	if err != nil {
+		return nil, err
+	}
 	defer f.Close()
 
-	// Create header
-	headers := []string{"Nama Produk", "SKU", "Stok", "Harga", "Modal"}
-	for i, header := range headers {
-		cell, _ := excelize.CoordinatesToCellName(i+1, 1)
-		f.SetCellValue("Sheet1", cell, header)
+	// Get first sheet
+	sheets := f.GetSheetList()
+	if len(sheets) == 0 {
+		return nil, fmt.Errorf("no sheets found in file")
+	}
+
+	rows, err := f.GetRows(sheets[0])
+	if err != nil {
+		return nil, err
 	}
 
-	// Add sample data
-	sampleData := [][]interface{}{
-		{"Nasi Goreng", "NG-001", 100, 15000, 8000},
-		{"Es Teh Manis", "ETM-001", 50, 5000, 2000},
-		{"Ayam Geprek", "AG-001", 30, 20000, 12000},
+	if len(rows) < 2 {
+		return nil, fmt.Errorf("file must have header row and at least one data row")
 	}
+	return rows, nil
+}
 
-	for rowIdx, row := range sampleData {
-		for colIdx, value := range row {
-			cell, _ := excelize.CoordinatesToCellName(colIdx+1, rowIdx+2)
-			f.SetCellValue("Sheet1", cell, value)
-		}
+// readCSV reads a .csv file
+func readCSV(file io.Reader) ([][]string, error) {
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
 	}
 
-	// Set column widths
-	f.SetColWidth("Sheet1", "A", "A", 20)
-	f.SetColWidth("Sheet1", "B", "B", 15)
-	f.SetColWidth("Sheet1", "C", "E", 12)
+	if len(records) < 2 {
+		return nil, fmt.Errorf("file must have header row and at least one data row")
+	}
+	// Exports and Excel start CSV files with a UTF-8 BOM
+	records[0][0] = strings.TrimPrefix(records[0][0], "\ufeff")
+	return records, nil
+}
 
-	c.Header("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
-	c.Header("Content-Disposition", "attachment; filename=template_import_stok.xlsx")
+// writeSheet sends a header row plus data rows as an .xlsx or .csv download.
+// It goes through pkg/export, so text can't be read as a formula.
+func writeSheet(c *gin.Context, filename, format string, fields []Field, rows [][]interface{}) {
+	if format != export.CSV {
+		format = export.XLSX
+	}
+	columns := make([]export.Column, len(fields))
+	for i, f := range fields {
+		columns[i] = export.Column{Header: f.Header}
+	}
+	columns[0].Width = 1.25 // Names
 
-	if err := f.Write(c.Writer); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate template"})
+	w, err := export.Start(c, format, filename, "", columns)
+	if err != nil {
+		export.Fail(c, err)
 		return
 	}
+	for _, row := range rows {
+		if err := w.Row(row...); err != nil {
+			export.Fail(c, err)
+			return
+		}
+	}
+	if err := w.Close(); err != nil {
+		export.Fail(c, err)
+	}
 }
@@ -0,0 +1,219 @@
+package inventory
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/yuditriaji/warungin-backend/pkg/categories"
+	"github.com/yuditriaji/warungin-backend/pkg/database"
+	"gorm.io/gorm"
+)
+
+// Longest category name accepted by import
+const maxCategoryName = 100
+
+var hexColor = regexp.MustCompile(`^#([0-9a-fA-F]{3}|[0-9a-fA-F]{6})$`)
+
+// categoryImport imports product categories, matched by name. Parents are
+// given by name and must already exist or appear on an earlier row.
+type categoryImport struct{}
+
+type categoryPlan struct {
+	sortOrder *int
+}
+
+func (categoryImport) fields() []Field {
+	return []Field{
+		{Key: "name", Header: "Nama Kategori", Aliases: []string{"kategori", "category", "nama", "name"}, Required: true},
+		{Key: "parent", Header: "Induk", Aliases: []string{"parent", "kategori induk", "parent category"}},
+		{Key: "color", Header: "Warna", Aliases: []string{"color", "colour"}},
+		{Key: "icon", Header: "Ikon", Aliases: []string{"icon"}},
+		{Key: "sort_order", Header: "Urutan", Aliases: []string{"sort order", "order", "urut"}},
+	}
+}
+
+func (categoryImport) samples() [][]interface{} {
+	return [][]interface{}{
+		{"Makanan", "", "#FF7043", "utensils", 1},
+		{"Nasi", "Makanan", "", "", 1},
+		{"Minuman", "", "#29B6F6", "cup", 2},
+	}
+}
+
+// findCategory looks up a tenant's category by name
+func findCategory(db *gorm.DB, tenantID, name string) (database.Category, bool) {
+	var cat database.Category
+	err := db.Where("tenant_id = ? AND LOWER(name) = ?", tenantID, strings.ToLower(name)).
+		Order("created_at ASC").
+		First(&cat).Error
+	return cat, err == nil
+}
+
+func (categoryImport) plan(ctx *planContext, row *PreviewRow) {
+	name := row.text("name")
+	if utf8.RuneCountInString(name) > maxCategoryName {
+		row.fail("Nama Kategori is longer than %d characters", maxCategoryName)
+		return
+	}
+	if color := row.text("color"); color != "" && !hexColor.MatchString(color) {
+		row.fail("Warna must be a hex color like #FF7043")
+		return
+	}
+
+	p := categoryPlan{}
+	var err error
+	if p.sortOrder, err = row.integer("sort_order", "Urutan"); err != nil {
+		row.fail("%v", err)
+		return
+	}
+
+	parent := row.text("parent")
+	if strings.EqualFold(parent, name) {
+		row.fail("Category cannot be its own parent")
+		return
+	}
+	var parentCat database.Category
+	parentFound := false
+	if parent != "" {
+		parentCat, parentFound = findCategory(ctx.db, ctx.tenantID, parent)
+		if _, earlier := ctx.seen["name:"+strings.ToLower(parent)]; !parentFound && !earlier {
+			row.fail("Parent category not found: %s", parent)
+			return
+		}
+	}
+
+	if line, dup := ctx.duplicate(row.Line, "name:"+strings.ToLower(name)); dup {
+		row.fail("Duplicate of row %d", line)
+		return
+	}
+	row.data = p
+
+	existing, found := findCategory(ctx.db, ctx.tenantID, name)
+	if !found {
+		// Parents created earlier in this import are checked when applied
+		if parentFound {
+			if err := categories.Load(ctx.db, ctx.tenantID).CheckParent(uuid.Nil, parentCat.ID); err != nil {
+				row.fail("%v", err)
+				return
+			}
+		}
+		row.Action = ActionCreate
+		return
+	}
+
+	row.MatchID = &existing.ID
+	var changes []string
+	if parent != "" && (!parentFound || existing.ParentID == nil || *existing.ParentID != parentCat.ID) {
+		if parentFound {
+			if err := categories.Load(ctx.db, ctx.tenantID).CheckParent(existing.ID, parentCat.ID); err != nil {
+				row.fail("%v", err)
+				return
+			}
+		}
+		changes = append(changes, "parent")
+	}
+	if color := row.text("color"); color != "" && color != existing.Color {
+		changes = append(changes, "color")
+	}
+	if icon := row.text("icon"); icon != "" && icon != existing.Icon {
+		changes = append(changes, "icon")
+	}
+	if p.sortOrder != nil && *p.sortOrder != existing.SortOrder {
+		changes = append(changes, "order")
+	}
+
+	if len(changes) == 0 {
+		row.Action, row.Message = ActionSkip, "Already exists"
+		return
+	}
+	row.Action, row.Message = ActionUpdate, strings.Join(changes, ", ")
+}
+
+func (categoryImport) apply(ctx *applyContext, row PreviewRow) error {
+	p := row.data.(categoryPlan)
+	tenantID := ctx.batch.TenantID.String()
+
+	// Parents created earlier in this import are visible inside the transaction
+	var parentID *uuid.UUID
+	if parent := row.text("parent"); parent != "" {
+		if cat, found := findCategory(ctx.tx, tenantID, parent); found {
+			parentID = &cat.ID
+		}
+	}
+	if parentID != nil {
+		id := uuid.Nil
+		if row.Action == ActionUpdate {
+			id = *row.MatchID
+		}
+		if err := categories.Load(ctx.tx, tenantID).CheckParent(id, *parentID); err != nil {
+			return err
+		}
+	}
+
+	if row.Action == ActionCreate {
+		category := database.Category{
+			TenantID: ctx.batch.TenantID,
+			ParentID: parentID,
+			Name:     row.text("name"),
+			Color:    row.text("color"),
+			Icon:     row.text("icon"),
+		}
+		if p.sortOrder != nil {
+			category.SortOrder = *p.sortOrder
+		}
+		return ctx.tx.Create(&category).Error
+	}
+
+	// Empty cells keep the current value
+	updates := map[string]interface{}{}
+	if parentID != nil {
+		updates["parent_id"] = *parentID
+	}
+	for _, key := range []string{"color", "icon"} {
+		if val := row.text(key); val != "" {
+			updates[key] = val
+		}
+	}
+	if p.sortOrder != nil {
+		updates["sort_order"] = *p.sortOrder
+	}
+	return ctx.tx.Model(&database.Category{}).Where("id = ?", *row.MatchID).Updates(updates).Error
+}
+
+func (categoryImport) export(db *gorm.DB, tenantID, outletID string) [][]interface{} {
+	var cats []database.Category
+	db.Where("tenant_id = ?", tenantID).Order("sort_order ASC, name ASC").Find(&cats)
+
+	names := make(map[uuid.UUID]string, len(cats))
+	for _, cat := range cats {
+		names[cat.ID] = cat.Name
+	}
+
+	// Parents first, so the file can be re-imported as is
+	rows := make([][]interface{}, 0, len(cats))
+	written := make(map[uuid.UUID]bool, len(cats))
+	for len(written) < len(cats) {
+		progress := false
+		for _, cat := range cats {
+			if written[cat.ID] {
+				continue
+			}
+			if cat.ParentID != nil && !written[*cat.ParentID] && names[*cat.ParentID] != "" {
+				continue
+			}
+			parent := ""
+			if cat.ParentID != nil {
+				parent = names[*cat.ParentID]
+			}
+			rows = append(rows, []interface{}{cat.Name, parent, cat.Color, cat.Icon, cat.SortOrder})
+			written[cat.ID] = true
+			progress = true
+		}
+		if !progress {
+			break
+		}
+	}
+	return rows
+}
@@ -0,0 +1,160 @@
+package inventory
+
+import (
+	"net/mail"
+	"strings"
+
+	"github.com/yuditriaji/warungin-backend/pkg/database"
+	"gorm.io/gorm"
+)
+
+// customerImport imports customers, matched by phone then email
+type customerImport struct{}
+
+func (customerImport) fields() []Field {
+	return []Field{
+		{Key: "name", Header: "Nama", Aliases: []string{"nama pelanggan", "name", "customer", "pelanggan"}, Required: true},
+		{Key: "phone", Header: "Telepon", Aliases: []string{"phone", "hp", "no hp", "no. hp", "whatsapp", "wa"}},
+		{Key: "email", Header: "Email", Aliases: []string{"e-mail"}},
+		{Key: "address", Header: "Alamat", Aliases: []string{"address"}},
+		{Key: "group", Header: "Grup", Aliases: []string{"group", "grup harga", "customer group", "tipe"}},
+	}
+}
+
+func (customerImport) samples() [][]interface{} {
+	return [][]interface{}{
+		{"Budi Santoso", "081234567890", "budi@example.com", "Jl. Merdeka No. 1, Bandung", "member"},
+		{"Siti Aminah", "+6285712345678", "", "", ""},
+	}
+}
+
+// normalizePhone strips formatting from a phone number, keeping a leading +
+func normalizePhone(phone string) (string, bool) {
+	var b strings.Builder
+	for i, r := range phone {
+		switch {
+		case r >= '0' && r <= '9':
+			b.WriteRune(r)
+		case r == '+' && i == 0:
+			b.WriteRune(r)
+		case r == ' ' || r == '-' || r == '.' || r == '(' || r == ')':
+		default:
+			return "", false
+		}
+	}
+	digits := strings.TrimPrefix(b.String(), "+")
+	return b.String(), len(digits) >= 8 && len(digits) <= 15
+}
+
+func (customerImport) plan(ctx *planContext, row *PreviewRow) {
+	phone := row.text("phone")
+	if phone != "" {
+		normalized, ok := normalizePhone(phone)
+		if !ok {
+			row.fail("Invalid phone number: %s", phone)
+			return
+		}
+		phone = normalized
+		row.Values["phone"] = phone
+	}
+
+	email := strings.ToLower(row.text("email"))
+	if email != "" {
+		if addr, err := mail.ParseAddress(email); err != nil || addr.Address != email {
+			row.fail("Invalid email: %s", row.text("email"))
+			return
+		}
+		row.Values["email"] = email
+	}
+
+	var keys []string
+	if phone != "" {
+		keys = append(keys, "phone:"+phone)
+	}
+	if email != "" {
+		keys = append(keys, "email:"+email)
+	}
+	if len(keys) == 0 {
+		keys = append(keys, "name:"+strings.ToLower(row.text("name")))
+	}
+	if line, dup := ctx.duplicate(row.Line, keys...); dup {
+		row.fail("Duplicate of row %d", line)
+		return
+	}
+
+	var existing database.Customer
+	found := false
+	if phone != "" {
+		if err := ctx.db.Where("tenant_id = ? AND phone = ?", ctx.tenantID, phone).First(&existing).Error; err == nil {
+			found = true
+		}
+	}
+	if !found && email != "" {
+		if err := ctx.db.Where("tenant_id = ? AND LOWER(email) = ?", ctx.tenantID, email).First(&existing).Error; err == nil {
+			found = true
+		}
+	}
+
+	if !found {
+		row.Action = ActionCreate
+		return
+	}
+
+	row.MatchID = &existing.ID
+	var changes []string
+	if row.text("name") != existing.Name {
+		changes = append(changes, "name")
+	}
+	if phone != "" && phone != existing.Phone {
+		changes = append(changes, "phone")
+	}
+	if email != "" && email != strings.ToLower(existing.Email) {
+		changes = append(changes, "email")
+	}
+	if address := row.text("address"); address != "" && address != existing.Address {
+		changes = append(changes, "address")
+	}
+	if group := row.text("group"); group != "" && group != existing.Group {
+		changes = append(changes, "group")
+	}
+
+	if len(changes) == 0 {
+		row.Action, row.Message = ActionSkip, "No changes"
+		return
+	}
+	row.Action, row.Message = ActionUpdate, strings.Join(changes, ", ")
+}
+
+func (customerImport) apply(ctx *applyContext, row PreviewRow) error {
+	if row.Action == ActionCreate {
+		customer := database.Customer{
+			TenantID: ctx.batch.TenantID,
+			Name:     row.text("name"),
+			Phone:    row.text("phone"),
+			Email:    row.text("email"),
+			Address:  row.text("address"),
+			Group:    row.text("group"),
+		}
+		return ctx.tx.Create(&customer).Error
+	}
+
+	// Empty cells keep the current value
+	updates := map[string]interface{}{"name": row.text("name")}
+	for _, key := range []string{"phone", "email", "address", "group"} {
+		if val := row.text(key); val != "" {
+			updates[key] = val
+		}
+	}
+	return ctx.tx.Model(&database.Customer{}).Where("id = ?", *row.MatchID).Updates(updates).Error
+}
+
+func (customerImport) export(db *gorm.DB, tenantID, outletID string) [][]interface{} {
+	var customers []database.Customer
+	db.Where("tenant_id = ?", tenantID).Order("name ASC").Find(&customers)
+
+	rows := make([][]interface{}, 0, len(customers))
+	for _, cu := range customers {
+		rows = append(rows, []interface{}{cu.Name, cu.Phone, cu.Email, cu.Address, cu.Group})
+	}
+	return rows
+}
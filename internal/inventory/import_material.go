@@ -0,0 +1,199 @@
+package inventory
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/yuditriaji/warungin-backend/pkg/costing"
+	"github.com/yuditriaji/warungin-backend/pkg/database"
+	"github.com/yuditriaji/warungin-backend/pkg/units"
+	"gorm.io/gorm"
+)
+
+// materialImport imports raw materials, matched by name
+type materialImport struct{}
+
+type materialPlan struct {
+	stock        *float64
+	unitPrice    *float64
+	minStock     *float64
+	currentStock float64
+	newStock     *float64
+}
+
+func (materialImport) fields() []Field {
+	return []Field{
+		{Key: "name", Header: "Nama Bahan", Aliases: []string{"bahan", "material", "nama", "name"}, Required: true},
+		{Key: "unit", Header: "Satuan", Aliases: []string{"unit", "uom"}},
+		{Key: "stock", Header: "Stok", Aliases: []string{"stock", "qty", "jumlah"}},
+		{Key: "unit_price", Header: "Harga Satuan", Aliases: []string{"unit price", "harga", "price", "cost", "modal"}},
+		{Key: "min_stock", Header: "Stok Minimum", Aliases: []string{"min stock", "minimum", "min_stock_level"}},
+		{Key: "supplier", Header: "Supplier", Aliases: []string{"pemasok", "vendor"}},
+	}
+}
+
+func (materialImport) samples() [][]interface{} {
+	return [][]interface{}{
+		{"Beras", "kg", 25, 14000, 5, "Toko Sembako Jaya"},
+		{"Minyak Goreng", "l", 10, 18000, 2, "Toko Sembako Jaya"},
+		{"Telur", "pcs", 60, 2000, 30, ""},
+	}
+}
+
+func (materialImport) plan(ctx *planContext, row *PreviewRow) {
+	name := row.text("name")
+	unit := units.Normalize(row.text("unit"))
+
+	p := materialPlan{}
+	var err error
+	if p.stock, err = row.number("stock", "Stok"); err != nil {
+		row.fail("%v", err)
+		return
+	}
+	if p.unitPrice, err = row.number("unit_price", "Harga Satuan"); err != nil {
+		row.fail("%v", err)
+		return
+	}
+	if p.minStock, err = row.number("min_stock", "Stok Minimum"); err != nil {
+		row.fail("%v", err)
+		return
+	}
+
+	if line, dup := ctx.duplicate(row.Line, "name:"+strings.ToLower(name)); dup {
+		row.fail("Duplicate of row %d", line)
+		return
+	}
+
+	var existing database.RawMaterial
+	if err := ctx.db.Where("tenant_id = ? AND LOWER(name) = ?", ctx.tenantID, strings.ToLower(name)).First(&existing).Error; err != nil {
+		if unit == "" {
+			row.fail("Satuan is required for new materials")
+			return
+		}
+		// Recipes and conversions only work with units in the catalog
+		if _, ok := ctx.units.Lookup(unit); !ok {
+			row.fail("Unknown unit: %s", unit)
+			return
+		}
+		stock := 0.0
+		if p.stock != nil {
+			stock = *p.stock
+		}
+		p.newStock = &stock
+		row.data = p
+		row.Action = ActionCreate
+		return
+	}
+
+	row.MatchID = &existing.ID
+	p.currentStock = existing.StockQty
+
+	// Recipes are written in the material's unit, so it can't change underneath them
+	if unit != "" && unit != units.Normalize(existing.Unit) {
+		row.fail("Unit cannot be changed from %s to %s by import", existing.Unit, unit)
+		return
+	}
+
+	var changes []string
+	if p.stock != nil {
+		newStock := *p.stock
+		if ctx.mode == ModeAdd {
+			newStock = existing.StockQty + *p.stock
+		}
+		p.newStock = &newStock
+		if newStock != existing.StockQty {
+			changes = append(changes, fmt.Sprintf("stock %g → %g", existing.StockQty, newStock))
+		}
+	}
+	if p.unitPrice != nil && *p.unitPrice != existing.UnitPrice && ctx.method == costing.MethodManual {
+		changes = append(changes, "unit price")
+	}
+	if p.minStock != nil && *p.minStock != existing.MinStockLevel {
+		changes = append(changes, "minimum stock")
+	}
+	if supplier := row.text("supplier"); supplier != "" && supplier != existing.Supplier {
+		changes = append(changes, "supplier")
+	}
+	row.data = p
+
+	if len(changes) == 0 {
+		row.Action, row.Message = ActionSkip, "No changes"
+		return
+	}
+	row.Action, row.Message = ActionUpdate, strings.Join(changes, ", ")
+}
+
+// apply writes one planned row. Stock changes go through the costing ledger;
+// with average/fifo the unit price column is used as the cost of added stock.
+func (materialImport) apply(ctx *applyContext, row PreviewRow) error {
+	p := row.data.(materialPlan)
+	var unitPrice float64
+	if p.unitPrice != nil {
+		unitPrice = *p.unitPrice
+	}
+
+	if row.Action == ActionCreate {
+		material := database.RawMaterial{
+			TenantID:      ctx.batch.TenantID,
+			OutletID:      ctx.batch.OutletID,
+			Name:          row.text("name"),
+			Unit:          units.Normalize(row.text("unit")),
+			UnitPrice:     unitPrice,
+			MinStockLevel: 10,
+			Supplier:      row.text("supplier"),
+			YieldQty:      1,
+		}
+		if p.minStock != nil {
+			material.MinStockLevel = *p.minStock
+		}
+		if err := ctx.tx.Create(&material).Error; err != nil {
+			return err
+		}
+		return ctx.ledger.Receive(costing.ItemMaterial, material.ID, *p.newStock, unitPrice, nil)
+	}
+
+	updates := map[string]interface{}{}
+	if p.unitPrice != nil && ctx.ledger.Method() == costing.MethodManual {
+		updates["unit_price"] = *p.unitPrice
+	}
+	if p.minStock != nil {
+		updates["min_stock_level"] = *p.minStock
+	}
+	if supplier := row.text("supplier"); supplier != "" {
+		updates["supplier"] = supplier
+	}
+	if len(updates) > 0 {
+		if err := ctx.tx.Model(&database.RawMaterial{}).Where("id = ?", *row.MatchID).Updates(updates).Error; err != nil {
+			return err
+		}
+	}
+
+	if p.newStock == nil {
+		return nil
+	}
+	delta := *p.newStock - p.currentStock
+	if delta > 0 {
+		if p.unitPrice != nil {
+			return ctx.ledger.Receive(costing.ItemMaterial, *row.MatchID, delta, unitPrice, nil)
+		}
+		return ctx.ledger.Restore(costing.ItemMaterial, *row.MatchID, delta, 0)
+	}
+	_, err := ctx.ledger.Consume(costing.ItemMaterial, *row.MatchID, -delta)
+	return err
+}
+
+func (materialImport) export(db *gorm.DB, tenantID, outletID string) [][]interface{} {
+	query := db.Where("tenant_id = ?", tenantID)
+	if outletID != "" {
+		query = query.Where("outlet_id = ?", outletID)
+	}
+
+	var materials []database.RawMaterial
+	query.Order("name ASC").Find(&materials)
+
+	rows := make([][]interface{}, 0, len(materials))
+	for _, m := range materials {
+		rows = append(rows, []interface{}{m.Name, m.Unit, m.StockQty, m.UnitPrice, m.MinStockLevel, m.Supplier})
+	}
+	return rows
+}
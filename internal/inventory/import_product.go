@@ -0,0 +1,205 @@
+package inventory
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/yuditriaji/warungin-backend/pkg/costing"
+	"github.com/yuditriaji/warungin-backend/pkg/database"
+	"github.com/yuditriaji/warungin-backend/pkg/stock"
+	"gorm.io/gorm"
+)
+
+// productImport imports products, matched by SKU then name
+type productImport struct{}
+
+type productPlan struct {
+	stock        *int
+	price        *float64
+	cost         *float64
+	currentStock int
+	newStock     *int
+}
+
+func (productImport) fields() []Field {
+	return []Field{
+		{Key: "name", Header: "Nama Produk", Aliases: []string{"product name", "nama", "name", "produk"}, Required: true},
+		{Key: "sku", Header: "SKU", Aliases: []string{"kode", "code", "kode produk"}},
+		{Key: "stock", Header: "Stok", Aliases: []string{"stock", "qty", "jumlah", "stock qty"}},
+		{Key: "price", Header: "Harga", Aliases: []string{"price", "harga jual"}},
+		{Key: "cost", Header: "Modal", Aliases: []string{"cost", "harga modal", "cogs"}},
+	}
+}
+
+func (productImport) samples() [][]interface{} {
+	return [][]interface{}{
+		{"Nasi Goreng", "NG-001", 100, 15000, 8000},
+		{"Es Teh Manis", "ETM-001", 50, 5000, 2000},
+		{"Ayam Geprek", "AG-001", 30, 20000, 12000},
+	}
+}
+
+func (productImport) plan(ctx *planContext, row *PreviewRow) {
+	name, sku := row.text("name"), row.text("sku")
+
+	p := productPlan{}
+	var err error
+	if p.stock, err = row.integer("stock", "Stok"); err != nil {
+		row.fail("%v", err)
+		return
+	}
+	if p.price, err = row.number("price", "Harga"); err != nil {
+		row.fail("%v", err)
+		return
+	}
+	if p.cost, err = row.number("cost", "Modal"); err != nil {
+		row.fail("%v", err)
+		return
+	}
+
+	// The same product twice in one file would be applied twice
+	keys := []string{"name:" + strings.ToLower(name)}
+	if sku != "" {
+		keys = append(keys, "sku:"+strings.ToLower(sku))
+	}
+	if line, dup := ctx.duplicate(row.Line, keys...); dup {
+		row.fail("Duplicate of row %d", line)
+		return
+	}
+
+	// Check if product exists by SKU or name
+	var existing database.Product
+	found := false
+	if sku != "" {
+		if err := ctx.db.Where("tenant_id = ? AND sku = ?", ctx.tenantID, sku).First(&existing).Error; err == nil {
+			found = true
+		}
+	}
+	if !found {
+		if err := ctx.db.Where("tenant_id = ? AND name = ?", ctx.tenantID, name).First(&existing).Error; err == nil {
+			found = true
+		}
+	}
+
+	if !found {
+		stock := 0
+		if p.stock != nil {
+			stock = *p.stock
+		}
+		if stock < 0 {
+			row.fail("Stock cannot go below zero")
+			return
+		}
+		p.newStock = &stock
+		row.data = p
+		row.Action = ActionCreate
+		return
+	}
+
+	row.MatchID = &existing.ID
+	p.currentStock = existing.StockQty
+
+	var changes []string
+	if p.stock != nil {
+		if existing.UseMaterialStock {
+			row.fail("Stock is calculated from materials and cannot be imported")
+			return
+		}
+		newStock := *p.stock
+		if ctx.mode == ModeAdd {
+			newStock = existing.StockQty + *p.stock
+		}
+		if newStock < 0 {
+			row.fail("Stock cannot go below zero")
+			return
+		}
+		p.newStock = &newStock
+		if newStock != existing.StockQty {
+			changes = append(changes, fmt.Sprintf("stock %d → %d", existing.StockQty, newStock))
+		}
+	}
+	if p.price != nil && *p.price != existing.Price {
+		changes = append(changes, "price")
+	}
+	if p.cost != nil && *p.cost != existing.Cost && ctx.method == costing.MethodManual {
+		changes = append(changes, "cost")
+	}
+	row.data = p
+
+	if len(changes) == 0 {
+		row.Action, row.Message = ActionSkip, "No changes"
+		return
+	}
+	row.Action, row.Message = ActionUpdate, strings.Join(changes, ", ")
+}
+
+// apply writes one planned row. Stock changes go through the costing ledger;
+// with average/fifo the cost column is used as the unit cost of added stock.
+func (productImport) apply(ctx *applyContext, row PreviewRow) error {
+	p := row.data.(productPlan)
+	var cost float64
+	if p.cost != nil {
+		cost = *p.cost
+	}
+
+	if row.Action == ActionCreate {
+		product := database.Product{
+			TenantID:      ctx.batch.TenantID,
+			OutletID:      ctx.batch.OutletID,
+			Name:          row.text("name"),
+			SKU:           row.text("sku"),
+			Cost:          cost,
+			MinStockLevel: stock.DefaultMinStockLevel,
+			IsActive:      true,
+		}
+		if p.price != nil {
+			product.Price = *p.price
+		}
+		if err := ctx.tx.Create(&product).Error; err != nil {
+			return err
+		}
+		return ctx.ledger.Receive(costing.ItemProduct, product.ID, float64(*p.newStock), cost, nil)
+	}
+
+	updates := map[string]interface{}{}
+	if p.price != nil {
+		updates["price"] = *p.price
+	}
+	if p.cost != nil && ctx.ledger.Method() == costing.MethodManual {
+		updates["cost"] = *p.cost
+	}
+	if len(updates) > 0 {
+		if err := ctx.tx.Model(&database.Product{}).Where("id = ?", *row.MatchID).Updates(updates).Error; err != nil {
+			return err
+		}
+	}
+
+	if p.newStock == nil {
+		return nil
+	}
+	delta := float64(*p.newStock - p.currentStock)
+	if delta > 0 {
+		if p.cost != nil {
+			return ctx.ledger.Receive(costing.ItemProduct, *row.MatchID, delta, cost, nil)
+		}
+		return ctx.ledger.Restore(costing.ItemProduct, *row.MatchID, delta, 0)
+	}
+	_, err := ctx.ledger.Consume(costing.ItemProduct, *row.MatchID, -delta)
+	return err
+}
+
+func (productImport) export(db *gorm.DB, tenantID, outletID string) [][]interface{} {
+	query := db.Where("tenant_id = ?", tenantID)
+	if outletID != "" {
+		query = query.Where("outlet_id = ?", outletID)
+	}
+
+	var products []database.Product
+	query.Order("name ASC").Find(&products)
+
+	rows := make([][]interface{}, 0, len(products))
+	for _, p := range products {
+		rows = append(rows, []interface{}{p.Name, p.SKU, p.StockQty, p.Price, p.Cost})
+	}
+	return rows
+}
@@ -0,0 +1,164 @@
+package inventory
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/yuditriaji/warungin-backend/pkg/database"
+	"github.com/yuditriaji/warungin-backend/pkg/units"
+	"gorm.io/gorm"
+)
+
+// recipeImport imports product-material links (how much of each material one
+// product unit uses). Products are matched by SKU then name, materials by name.
+type recipeImport struct{}
+
+type recipePlan struct {
+	productID      uuid.UUID
+	materialID     uuid.UUID
+	quantity       float64
+	usedUnit       string
+	conversionRate float64
+}
+
+func (recipeImport) fields() []Field {
+	return []Field{
+		{Key: "product", Header: "Produk", Aliases: []string{"nama produk", "product", "product name"}, Required: true},
+		{Key: "product_sku", Header: "SKU Produk", Aliases: []string{"sku", "product sku"}},
+		{Key: "material", Header: "Bahan", Aliases: []string{"nama bahan", "material", "material name"}, Required: true},
+		{Key: "quantity", Header: "Jumlah", Aliases: []string{"qty", "quantity", "takaran"}, Required: true},
+		{Key: "unit", Header: "Satuan", Aliases: []string{"unit", "uom"}},
+	}
+}
+
+func (recipeImport) samples() [][]interface{} {
+	return [][]interface{}{
+		{"Nasi Goreng", "NG-001", "Beras", 150, "g"},
+		{"Nasi Goreng", "NG-001", "Telur", 1, "pcs"},
+		{"Nasi Goreng", "NG-001", "Minyak Goreng", 15, "ml"},
+	}
+}
+
+func (recipeImport) plan(ctx *planContext, row *PreviewRow) {
+	qty, err := row.number("quantity", "Jumlah")
+	if err != nil {
+		row.fail("%v", err)
+		return
+	}
+	if qty == nil || *qty <= 0 {
+		row.fail("Jumlah must be greater than zero")
+		return
+	}
+
+	// Find product by SKU, then name
+	var product database.Product
+	found := false
+	if sku := row.text("product_sku"); sku != "" {
+		if err := ctx.db.Where("tenant_id = ? AND sku = ?", ctx.tenantID, sku).First(&product).Error; err == nil {
+			found = true
+		}
+	}
+	if !found {
+		if err := ctx.db.Where("tenant_id = ? AND name = ?", ctx.tenantID, row.text("product")).First(&product).Error; err != nil {
+			row.fail("Product not found: %s", row.text("product"))
+			return
+		}
+	}
+
+	var material database.RawMaterial
+	if err := ctx.db.Where("tenant_id = ? AND LOWER(name) = ?", ctx.tenantID, strings.ToLower(row.text("material"))).First(&material).Error; err != nil {
+		row.fail("Material not found: %s", row.text("material"))
+		return
+	}
+
+	if line, dup := ctx.duplicate(row.Line, product.ID.String()+":"+material.ID.String()); dup {
+		row.fail("Duplicate of row %d", line)
+		return
+	}
+
+	// Derive the conversion rate from the unit catalog
+	p := recipePlan{
+		productID:      product.ID,
+		materialID:     material.ID,
+		quantity:       *qty,
+		conversionRate: 1,
+	}
+	if unit := row.text("unit"); unit != "" && units.Normalize(unit) != units.Normalize(material.Unit) {
+		rate, known, err := ctx.units.ConversionRate(unit, material.Unit)
+		if err != nil {
+			row.fail("%v", err)
+			return
+		}
+		if !known {
+			row.fail("Unknown unit: %s", unit)
+			return
+		}
+		p.usedUnit = units.Normalize(unit)
+		p.conversionRate = rate
+	}
+	row.data = p
+
+	var existing database.ProductMaterial
+	if err := ctx.db.Where("product_id = ? AND material_id = ?", product.ID, material.ID).First(&existing).Error; err != nil {
+		row.Action = ActionCreate
+		return
+	}
+
+	row.MatchID = &existing.ID
+	if existing.QuantityUsed == p.quantity && existing.UsedUnit == p.usedUnit && existing.ConversionRate == p.conversionRate {
+		row.Action, row.Message = ActionSkip, "No changes"
+		return
+	}
+	row.Action = ActionUpdate
+	row.Message = fmt.Sprintf("quantity %g → %g", existing.QuantityUsed, p.quantity)
+}
+
+func (recipeImport) apply(ctx *applyContext, row PreviewRow) error {
+	p := row.data.(recipePlan)
+
+	if row.Action == ActionCreate {
+		link := database.ProductMaterial{
+			ProductID:      p.productID,
+			MaterialID:     p.materialID,
+			QuantityUsed:   p.quantity,
+			UsedUnit:       p.usedUnit,
+			ConversionRate: p.conversionRate,
+		}
+		return ctx.tx.Omit("Product", "Material").Create(&link).Error
+	}
+
+	return ctx.tx.Model(&database.ProductMaterial{}).
+		Where("id = ?", *row.MatchID).
+		Updates(map[string]interface{}{
+			"quantity_used":   p.quantity,
+			"used_unit":       p.usedUnit,
+			"conversion_rate": p.conversionRate,
+		}).Error
+}
+
+func (recipeImport) export(db *gorm.DB, tenantID, outletID string) [][]interface{} {
+	query := db.Table("product_materials").
+		Select("products.name as product, products.sku as product_sku, raw_materials.name as material, product_materials.quantity_used as quantity, COALESCE(NULLIF(product_materials.used_unit, ''), raw_materials.unit) as unit").
+		Joins("JOIN products ON products.id = product_materials.product_id AND products.deleted_at IS NULL").
+		Joins("JOIN raw_materials ON raw_materials.id = product_materials.material_id AND raw_materials.deleted_at IS NULL").
+		Where("products.tenant_id = ?", tenantID)
+	if outletID != "" {
+		query = query.Where("products.outlet_id = ?", outletID)
+	}
+
+	var links []struct {
+		Product    string
+		ProductSKU string
+		Material   string
+		Quantity   float64
+		Unit       string
+	}
+	query.Order("products.name ASC, raw_materials.name ASC").Scan(&links)
+
+	rows := make([][]interface{}, 0, len(links))
+	for _, l := range links {
+		rows = append(rows, []interface{}{l.Product, l.ProductSKU, l.Material, l.Quantity, l.Unit})
+	}
+	return rows
+}
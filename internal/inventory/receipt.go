@@ -0,0 +1,202 @@
+package inventory
+
+import (
+	"math"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/yuditriaji/warungin-backend/pkg/costing"
+	"github.com/yuditriaji/warungin-backend/pkg/database"
+)
+
+type ReceiptRequest struct {
+	ItemType string  `json:"item_type" binding:"required,oneof=material product"`
+	ItemID   string  `json:"item_id" binding:"required"`
+	Quantity float64 `json:"quantity" binding:"required,gt=0"`
+	UnitCost float64 `json:"unit_cost" binding:"gte=0"`
+	Supplier string  `json:"supplier"`
+	Note     string  `json:"note"`
+}
+
+type WasteRequest struct {
+	ItemType string  `json:"item_type" binding:"required,oneof=material product"`
+	ItemID   string  `json:"item_id" binding:"required"`
+	Quantity float64 `json:"quantity" binding:"required,gt=0"`
+	Reason   string  `json:"reason"`
+}
+
+// stockItem is the common view of a product or material being moved
+type stockItem struct {
+	ID       uuid.UUID
+	TenantID uuid.UUID
+	OutletID *uuid.UUID
+	Name     string
+	Unit     string
+	StockQty float64
+}
+
+// loadItem loads a product or material belonging to the tenant
+func (h *Handler) loadItem(tenantID, itemType, itemID string) (stockItem, error) {
+	if itemType == costing.ItemMaterial {
+		var material database.RawMaterial
+		if err := h.db.Where("id = ? AND tenant_id = ?", itemID, tenantID).First(&material).Error; err != nil {
+			return stockItem{}, err
+		}
+		return stockItem{material.ID, material.TenantID, material.OutletID, material.Name, material.Unit, material.StockQty}, nil
+	}
+
+	var product database.Product
+	if err := h.db.Where("id = ? AND tenant_id = ?", itemID, tenantID).First(&product).Error; err != nil {
+		return stockItem{}, err
+	}
+	return stockItem{product.ID, product.TenantID, product.OutletID, product.Name, "pcs", float64(product.StockQty)}, nil
+}
+
+// CreateReceipt records purchased stock and updates the item's cost
+func (h *Handler) CreateReceipt(c *gin.Context) {
+	tenantID := c.GetString("tenant_id")
+	userID := c.GetString("user_id")
+
+	var req ReceiptRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.ItemType == costing.ItemProduct && req.Quantity != math.Trunc(req.Quantity) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Product quantity must be a whole number"})
+		return
+	}
+
+	item, err := h.loadItem(tenantID, req.ItemType, req.ItemID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Item not found"})
+		return
+	}
+
+	userUUID, _ := uuid.Parse(userID)
+	receipt := database.StockReceipt{
+		TenantID:  item.TenantID,
+		OutletID:  item.OutletID,
+		ItemType:  req.ItemType,
+		ItemID:    item.ID,
+		ItemName:  item.Name,
+		Quantity:  req.Quantity,
+		UnitCost:  req.UnitCost,
+		TotalCost: req.Quantity * req.UnitCost,
+		Supplier:  req.Supplier,
+		Note:      req.Note,
+		UserID:    userUUID,
+	}
+
+	tx := h.db.Begin()
+	if err := tx.Create(&receipt).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record receipt"})
+		return
+	}
+
+	ledger := costing.NewLedger(tx, item.TenantID, costing.LoadMethod(h.db, tenantID))
+	if err := ledger.Receive(req.ItemType, item.ID, req.Quantity, req.UnitCost, &receipt.ID); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update stock"})
+		return
+	}
+	tx.Commit()
+
+	c.JSON(http.StatusCreated, gin.H{"data": receipt})
+}
+
+// ListReceipts returns stock receipts, optionally filtered by item
+func (h *Handler) ListReceipts(c *gin.Context) {
+	tenantID := c.GetString("tenant_id")
+
+	query := h.db.Where("tenant_id = ?", tenantID)
+	if itemType := c.Query("item_type"); itemType != "" {
+		query = query.Where("item_type = ?", itemType)
+	}
+	if itemID := c.Query("item_id"); itemID != "" {
+		query = query.Where("item_id = ?", itemID)
+	}
+
+	var receipts []database.StockReceipt
+	query.Preload("User").Order("created_at DESC").Limit(100).Find(&receipts)
+
+	c.JSON(http.StatusOK, gin.H{"data": receipts})
+}
+
+// CreateWaste writes off spoiled or damaged stock at its current cost
+func (h *Handler) CreateWaste(c *gin.Context) {
+	tenantID := c.GetString("tenant_id")
+	userID := c.GetString("user_id")
+
+	var req WasteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.ItemType == costing.ItemProduct && req.Quantity != math.Trunc(req.Quantity) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Product quantity must be a whole number"})
+		return
+	}
+
+	item, err := h.loadItem(tenantID, req.ItemType, req.ItemID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Item not found"})
+		return
+	}
+	if req.Quantity > item.StockQty {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Stock cannot go below zero"})
+		return
+	}
+
+	tx := h.db.Begin()
+	ledger := costing.NewLedger(tx, item.TenantID, costing.LoadMethod(h.db, tenantID))
+	totalCost, err := ledger.Consume(req.ItemType, item.ID, req.Quantity)
+	if err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update stock"})
+		return
+	}
+
+	userUUID, _ := uuid.Parse(userID)
+	waste := database.WasteRecord{
+		TenantID:  item.TenantID,
+		OutletID:  item.OutletID,
+		ItemType:  req.ItemType,
+		ItemID:    item.ID,
+		ItemName:  item.Name,
+		Quantity:  req.Quantity,
+		Unit:      item.Unit,
+		UnitCost:  totalCost / req.Quantity,
+		TotalCost: totalCost,
+		Reason:    req.Reason,
+		UserID:    userUUID,
+	}
+	if err := tx.Create(&waste).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record waste"})
+		return
+	}
+	tx.Commit()
+
+	c.JSON(http.StatusCreated, gin.H{"data": waste})
+}
+
+// ListWaste returns waste records, optionally filtered by item
+func (h *Handler) ListWaste(c *gin.Context) {
+	tenantID := c.GetString("tenant_id")
+
+	query := h.db.Where("tenant_id = ?", tenantID)
+	if itemType := c.Query("item_type"); itemType != "" {
+		query = query.Where("item_type = ?", itemType)
+	}
+	if itemID := c.Query("item_id"); itemID != "" {
+		query = query.Where("item_id = ?", itemID)
+	}
+
+	var records []database.WasteRecord
+	query.Preload("User").Order("created_at DESC").Limit(100).Find(&records)
+
+	c.JSON(http.StatusOK, gin.H{"data": records})
+}
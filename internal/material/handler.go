@@ -5,7 +5,10 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/yuditriaji/warungin-backend/pkg/costing"
 	"github.com/yuditriaji/warungin-backend/pkg/database"
+	"github.com/yuditriaji/warungin-backend/pkg/recipe"
+	"github.com/yuditriaji/warungin-backend/pkg/units"
 	"gorm.io/gorm"
 )
 
@@ -24,7 +27,9 @@ type CreateMaterialInput struct {
 	StockQty      float64 `json:"stock_qty"`
 	MinStockLevel float64 `json:"min_stock_level"`
 	Supplier      string  `json:"supplier"`
-	OutletID      string  `json:"outlet_id"` // Optional outlet assignment
+	OutletID      string  `json:"outlet_id"`   // Optional outlet assignment
+	IsPrepared    *bool   `json:"is_prepared"` // Made in-house from other materials; updates keep it when omitted
+	YieldQty      float64 `json:"yield_qty"`   // Units produced per recipe batch (defaults to 1)
 }
 
 // List returns all raw materials for tenant, optionally filtered by outlet
@@ -64,6 +69,11 @@ func (h *Handler) Create(c *gin.Context) {
 		minStock = 10 // Default
 	}
 
+	yieldQty := input.YieldQty
+	if yieldQty <= 0 {
+		yieldQty = 1
+	}
+
 	material := database.RawMaterial{
 		TenantID:      tenantUUID,
 		Name:          input.Name,
@@ -72,6 +82,8 @@ func (h *Handler) Create(c *gin.Context) {
 		StockQty:      input.StockQty,
 		MinStockLevel: minStock,
 		Supplier:      input.Supplier,
+		IsPrepared:    input.IsPrepared != nil && *input.IsPrepared,
+		YieldQty:      yieldQty,
 	}
 
 	// Set outlet if provided
@@ -123,16 +135,56 @@ func (h *Handler) Update(c *gin.Context) {
 		return
 	}
 
+	if input.StockQty < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Stock cannot go below zero"})
+		return
+	}
+
+	// Stock changes go through the costing ledger so fifo layers stay in
+	// sync. With average or fifo costing the unit price follows receipts and
+	// production, so an edited one is ignored.
+	method := costing.LoadMethod(h.db, tenantID)
+	omit := []string{"stock_qty"}
+	if method == costing.MethodManual {
+		material.UnitPrice = input.UnitPrice
+	} else {
+		omit = append(omit, "unit_price")
+	}
+	stockDelta := input.StockQty - material.StockQty
+
 	material.Name = input.Name
 	material.Unit = input.Unit
-	material.UnitPrice = input.UnitPrice
-	material.StockQty = input.StockQty
 	if input.MinStockLevel > 0 {
 		material.MinStockLevel = input.MinStockLevel
 	}
 	material.Supplier = input.Supplier
+	if input.IsPrepared != nil {
+		material.IsPrepared = *input.IsPrepared
+	}
+	if input.YieldQty > 0 {
+		material.YieldQty = input.YieldQty
+	}
+
+	tx := h.db.Begin()
+	if err := tx.Omit(omit...).Save(&material).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update material"})
+		return
+	}
+	if stockDelta != 0 {
+		ledger := costing.NewLedger(tx, material.TenantID, method)
+		if err := ledger.Adjust(costing.ItemMaterial, material.ID, stockDelta); err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update stock"})
+			return
+		}
+	}
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update material"})
+		return
+	}
 
-	h.db.Save(&material)
+	h.db.Where("id = ?", material.ID).First(&material)
 
 	c.JSON(http.StatusOK, gin.H{"data": material})
 }
@@ -173,11 +225,23 @@ func (h *Handler) UpdateStock(c *gin.Context) {
 		return
 	}
 
-	material.StockQty += input.Adjustment
-	if material.StockQty < 0 {
-		material.StockQty = 0
+	// Never take stock below zero
+	adjustment := input.Adjustment
+	if material.StockQty+adjustment < 0 {
+		adjustment = -material.StockQty
 	}
-	h.db.Save(&material)
+
+	// Go through the costing ledger so fifo layers stay in sync with stock
+	tx := h.db.Begin()
+	ledger := costing.NewLedger(tx, material.TenantID, costing.LoadMethod(h.db, tenantID))
+	if err := ledger.Adjust(costing.ItemMaterial, material.ID, adjustment); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update stock"})
+		return
+	}
+	tx.Commit()
+
+	h.db.Where("id = ?", material.ID).First(&material)
 
 	c.JSON(http.StatusOK, gin.H{"data": material})
 }
@@ -227,15 +291,11 @@ func (h *Handler) GetProductMaterials(c *gin.Context) {
 		return
 	}
 
-	// Calculate total material cost (considering conversion rate)
+	// Calculate total material cost (considering conversion rate and sub-recipes)
+	calc := recipe.NewCalculator(h.db)
 	var totalCost float64
 	for _, link := range links {
-		convRate := link.ConversionRate
-		if convRate <= 0 {
-			convRate = 1
-		}
-		// Cost = quantity * conversion_rate * unit_price
-		totalCost += link.Material.UnitPrice * link.QuantityUsed * convRate
+		totalCost += calc.LinkCost(link)
 	}
 
 	c.JSON(http.StatusOK, gin.H{
@@ -252,13 +312,22 @@ func (h *Handler) LinkMaterial(c *gin.Context) {
 		return
 	}
 
+	tenantID := c.GetString("tenant_id")
 	productUUID, _ := uuid.Parse(input.ProductID)
 	materialUUID, _ := uuid.Parse(input.MaterialID)
 
-	// Default conversion rate to 1 if not provided
-	conversionRate := input.ConversionRate
-	if conversionRate <= 0 {
-		conversionRate = 1
+	var material database.RawMaterial
+	if err := h.db.Where("id = ? AND tenant_id = ?", materialUUID, tenantID).
+		First(&material).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Material not found"})
+		return
+	}
+
+	// Validate the recipe unit and derive the conversion rate from the unit catalog
+	conversionRate, err := h.resolveConversion(tenantID, input.UsedUnit, material.Unit, input.ConversionRate)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
 
 	// Check if already linked
@@ -290,6 +359,28 @@ func (h *Handler) LinkMaterial(c *gin.Context) {
 	c.JSON(http.StatusCreated, gin.H{"data": link})
 }
 
+// resolveConversion returns the conversion rate from usedUnit to materialUnit.
+// When both units are in the tenant's unit catalog the rate is computed and
+// incompatible units are rejected; otherwise the manual rate is used (default 1).
+func (h *Handler) resolveConversion(tenantID, usedUnit, materialUnit string, manualRate float64) (float64, error) {
+	if manualRate <= 0 {
+		manualRate = 1
+	}
+	if usedUnit == "" {
+		return manualRate, nil
+	}
+
+	catalog := units.LoadCatalog(h.db, tenantID)
+	rate, known, err := catalog.ConversionRate(usedUnit, materialUnit)
+	if err != nil {
+		return 0, err
+	}
+	if !known {
+		return manualRate, nil
+	}
+	return rate, nil
+}
+
 // UnlinkMaterial removes a material from a product
 func (h *Handler) UnlinkMaterial(c *gin.Context) {
 	productID := c.Param("product_id")
@@ -312,6 +403,7 @@ func (h *Handler) CalculateProductCost(c *gin.Context) {
 	var links []database.ProductMaterial
 	h.db.Preload("Material").Where("product_id = ?", productID).Find(&links)
 
+	calc := recipe.NewCalculator(h.db)
 	var totalCost float64
 	var breakdown []gin.H
 	for _, link := range links {
@@ -319,8 +411,9 @@ func (h *Handler) CalculateProductCost(c *gin.Context) {
 		if convRate <= 0 {
 			convRate = 1
 		}
-		// Cost = quantity * conversion_rate * unit_price
-		cost := link.Material.UnitPrice * link.QuantityUsed * convRate
+		// Cost = quantity * conversion_rate * unit_cost (rolled up for prepared materials)
+		unitCost := calc.MaterialUnitCost(link.Material)
+		cost := unitCost * link.QuantityUsed * convRate
 		totalCost += cost
 		
 		usedUnit := link.UsedUnit
@@ -334,7 +427,8 @@ func (h *Handler) CalculateProductCost(c *gin.Context) {
 			"used_unit":       usedUnit,
 			"material_unit":   link.Material.Unit,
 			"conversion_rate": convRate,
-			"unit_price":      link.Material.UnitPrice,
+			"unit_price":      unitCost,
+			"is_prepared":     link.Material.IsPrepared,
 			"cost":            cost,
 		})
 	}
@@ -0,0 +1,327 @@
+package material
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/yuditriaji/warungin-backend/pkg/costing"
+	"github.com/yuditriaji/warungin-backend/pkg/database"
+	"github.com/yuditriaji/warungin-backend/pkg/recipe"
+	"gorm.io/gorm/clause"
+)
+
+// === Prepared materials (sub-recipes) ===
+
+type AddComponentInput struct {
+	ComponentID    string  `json:"component_id" binding:"required"`
+	QuantityUsed   float64 `json:"quantity_used" binding:"required,gt=0"` // Per batch
+	UsedUnit       string  `json:"used_unit"`                             // Optional: unit used in recipe
+	ConversionRate float64 `json:"conversion_rate"`                       // Optional: defaults to 1
+}
+
+type ProduceInput struct {
+	Batches  float64 `json:"batches"`  // Number of recipe batches made
+	Quantity float64 `json:"quantity"` // Alternatively, output quantity in the material's unit
+	Note     string  `json:"note"`
+}
+
+// GetRecipe returns the components of a prepared material with rolled-up cost
+func (h *Handler) GetRecipe(c *gin.Context) {
+	tenantID := c.GetString("tenant_id")
+	id := c.Param("id")
+
+	var material database.RawMaterial
+	if err := h.db.Where("id = ? AND tenant_id = ?", id, tenantID).
+		First(&material).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Material not found"})
+		return
+	}
+
+	var components []database.MaterialComponent
+	h.db.Where("material_id = ?", material.ID).Preload("Component").Find(&components)
+
+	calc := recipe.NewCalculator(h.db)
+	var batchCost float64
+	breakdown := []gin.H{}
+	for _, comp := range components {
+		convRate := comp.ConversionRate
+		if convRate <= 0 {
+			convRate = 1
+		}
+		unitCost := calc.MaterialUnitCost(comp.Component)
+		cost := comp.QuantityUsed * convRate * unitCost
+		batchCost += cost
+
+		breakdown = append(breakdown, gin.H{
+			"component_id":    comp.ComponentID,
+			"component":       comp.Component.Name,
+			"is_prepared":     comp.Component.IsPrepared,
+			"quantity":        comp.QuantityUsed,
+			"used_unit":       comp.UsedUnit,
+			"component_unit":  comp.Component.Unit,
+			"conversion_rate": convRate,
+			"unit_cost":       unitCost,
+			"cost":            cost,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": gin.H{
+			"material":   material,
+			"components": breakdown,
+			"batch_cost": batchCost,
+			"unit_cost":  calc.RecipeUnitCost(material),
+		},
+	})
+}
+
+// AddComponent adds or updates a component in a prepared material's recipe
+func (h *Handler) AddComponent(c *gin.Context) {
+	tenantID := c.GetString("tenant_id")
+	id := c.Param("id")
+
+	var input AddComponentInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var material database.RawMaterial
+	if err := h.db.Where("id = ? AND tenant_id = ?", id, tenantID).
+		First(&material).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Material not found"})
+		return
+	}
+
+	var component database.RawMaterial
+	if err := h.db.Where("id = ? AND tenant_id = ?", input.ComponentID, tenantID).
+		First(&component).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Component material not found"})
+		return
+	}
+
+	if err := recipe.CheckCycle(h.db, material.ID, component.ID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("%s cannot be used in %s: recipe would create a cycle", component.Name, material.Name),
+		})
+		return
+	}
+
+	conversionRate, err := h.resolveConversion(tenantID, input.UsedUnit, component.Unit, input.ConversionRate)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Adding a component makes the material a prepared one
+	if !material.IsPrepared {
+		h.db.Model(&material).Update("is_prepared", true)
+	}
+
+	var existing database.MaterialComponent
+	if h.db.Where("material_id = ? AND component_id = ?", material.ID, component.ID).
+		First(&existing).Error == nil {
+		existing.QuantityUsed = input.QuantityUsed
+		existing.UsedUnit = input.UsedUnit
+		existing.ConversionRate = conversionRate
+		h.db.Save(&existing)
+		c.JSON(http.StatusOK, gin.H{"data": existing})
+		return
+	}
+
+	link := database.MaterialComponent{
+		MaterialID:     material.ID,
+		ComponentID:    component.ID,
+		QuantityUsed:   input.QuantityUsed,
+		UsedUnit:       input.UsedUnit,
+		ConversionRate: conversionRate,
+	}
+	if err := h.db.Create(&link).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"data": link})
+}
+
+// RemoveComponent removes a component from a prepared material's recipe
+func (h *Handler) RemoveComponent(c *gin.Context) {
+	tenantID := c.GetString("tenant_id")
+	id := c.Param("id")
+	componentID := c.Param("component_id")
+
+	var material database.RawMaterial
+	if err := h.db.Where("id = ? AND tenant_id = ?", id, tenantID).
+		First(&material).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Material not found"})
+		return
+	}
+
+	result := h.db.Where("material_id = ? AND component_id = ?", material.ID, componentID).
+		Delete(&database.MaterialComponent{})
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Component not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Component removed"})
+}
+
+// Produce records a production run: consumes the recipe inputs and
+// increases the prepared material's stock
+func (h *Handler) Produce(c *gin.Context) {
+	tenantID := c.GetString("tenant_id")
+	tenantUUID, _ := uuid.Parse(tenantID)
+	userUUID, _ := uuid.Parse(c.GetString("user_id"))
+	id := c.Param("id")
+
+	var input ProduceInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var material database.RawMaterial
+	if err := h.db.Where("id = ? AND tenant_id = ?", id, tenantID).
+		First(&material).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Material not found"})
+		return
+	}
+
+	var components []database.MaterialComponent
+	h.db.Where("material_id = ?", material.ID).Preload("Component").Find(&components)
+	if !material.IsPrepared || len(components) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Material has no recipe"})
+		return
+	}
+
+	yield := material.YieldQty
+	if yield <= 0 {
+		yield = 1
+	}
+
+	batches := input.Batches
+	if batches <= 0 && input.Quantity > 0 {
+		batches = input.Quantity / yield
+	}
+	if batches <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "batches or quantity must be greater than zero"})
+		return
+	}
+	produced := batches * yield
+
+	// Cost is calculated before stock changes
+	unitCost := recipe.NewCalculator(h.db).RecipeUnitCost(material)
+
+	tx := h.db.Begin()
+	ledger := costing.NewLedger(tx, tenantUUID, costing.LoadMethod(h.db, tenantID))
+
+	// Inputs are locked, in a fixed order, so sales can't use the stock
+	// between the check and the deduction
+	sort.Slice(components, func(i, j int) bool {
+		return components[i].ComponentID.String() < components[j].ComponentID.String()
+	})
+
+	var inputs []gin.H
+	var consumedCost float64
+	for _, comp := range components {
+		convRate := comp.ConversionRate
+		if convRate <= 0 {
+			convRate = 1
+		}
+		required := comp.QuantityUsed * convRate * batches
+
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ?", comp.ComponentID).First(&comp.Component).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update material stock"})
+			return
+		}
+		if comp.Component.StockQty < required {
+			tx.Rollback()
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": fmt.Sprintf("Insufficient material: %s (need %.2f %s, have %.2f %s)",
+					comp.Component.Name, required, comp.Component.Unit, comp.Component.StockQty, comp.Component.Unit),
+			})
+			return
+		}
+
+		cost, err := ledger.Consume(costing.ItemMaterial, comp.ComponentID, required)
+		if err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update material stock"})
+			return
+		}
+		consumedCost += cost
+
+		inputs = append(inputs, gin.H{
+			"material_id": comp.ComponentID,
+			"name":        comp.Component.Name,
+			"quantity":    required,
+			"unit":        comp.Component.Unit,
+		})
+	}
+
+	// Average and fifo cost the output at what the inputs actually cost
+	if ledger.Method() != costing.MethodManual {
+		unitCost = consumedCost / produced
+	}
+
+	// Increase prepared stock
+	if err := ledger.Receive(costing.ItemMaterial, material.ID, produced, unitCost, nil); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update material stock"})
+		return
+	}
+
+	// Manual costing keeps the unit price in line with the recipe cost
+	if ledger.Method() == costing.MethodManual {
+		tx.Model(&database.RawMaterial{}).Where("id = ?", material.ID).Update("unit_price", unitCost)
+	}
+
+	inputsJSON, _ := json.Marshal(inputs)
+	run := database.ProductionRun{
+		TenantID:         tenantUUID,
+		MaterialID:       material.ID,
+		Batches:          batches,
+		QuantityProduced: produced,
+		UnitCost:         unitCost,
+		Inputs:           string(inputsJSON),
+		Note:             input.Note,
+		UserID:           userUUID,
+	}
+	if err := tx.Create(&run).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record production run"})
+		return
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record production run"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"data": run})
+}
+
+// ListProductionRuns returns production history for a prepared material
+func (h *Handler) ListProductionRuns(c *gin.Context) {
+	tenantID := c.GetString("tenant_id")
+	id := c.Param("id")
+
+	var runs []database.ProductionRun
+	if err := h.db.Where("tenant_id = ? AND material_id = ?", tenantID, id).
+		Preload("User").
+		Order("created_at DESC").
+		Limit(100).
+		Find(&runs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": runs})
+}
@@ -0,0 +1,266 @@
+package pricelist
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/yuditriaji/warungin-backend/pkg/activitylog"
+	"github.com/yuditriaji/warungin-backend/pkg/database"
+	"github.com/yuditriaji/warungin-backend/pkg/schedule"
+	"gorm.io/gorm"
+)
+
+type Handler struct {
+	db     *gorm.DB
+	logger *activitylog.Logger
+}
+
+func NewHandler(db *gorm.DB) *Handler {
+	return &Handler{
+		db:     db,
+		logger: activitylog.NewLogger(db),
+	}
+}
+
+type PriceListItemRequest struct {
+	ProductID uuid.UUID `json:"product_id" binding:"required"` // A product or a variant
+	Price     float64   `json:"price" binding:"min=0"`
+}
+
+type PriceListRequest struct {
+	Name          string                  `json:"name" binding:"required"`
+	OutletID      *uuid.UUID              `json:"outlet_id"`
+	CustomerGroup string                  `json:"customer_group"`
+	Days          string                  `json:"days"`       // e.g. "1,2,3,4,5"
+	StartTime     string                  `json:"start_time"` // HH:MM
+	EndTime       string                  `json:"end_time"`   // HH:MM
+	Priority      int                     `json:"priority"`
+	IsActive      *bool                   `json:"is_active"`
+	Items         *[]PriceListItemRequest `json:"items"` // Replaces all items when given
+}
+
+// validate normalizes and checks a request, returning an error message
+func (h *Handler) validate(tenantID string, req *PriceListRequest) string {
+	req.Name = strings.TrimSpace(req.Name)
+	req.CustomerGroup = strings.TrimSpace(req.CustomerGroup)
+	if req.Name == "" {
+		return "Name is required"
+	}
+
+	if req.OutletID != nil {
+		var count int64
+		h.db.Model(&database.Outlet{}).Where("id = ? AND tenant_id = ?", *req.OutletID, tenantID).Count(&count)
+		if count == 0 {
+			return "Outlet not found"
+		}
+	}
+
+	window := schedule.Window{Days: req.Days, Start: req.StartTime, End: req.EndTime}
+	if err := window.Validate(); err != nil {
+		return err.Error()
+	}
+
+	if req.Items != nil {
+		seen := make(map[uuid.UUID]bool)
+		for _, item := range *req.Items {
+			if seen[item.ProductID] {
+				return fmt.Sprintf("Product %s is listed twice", item.ProductID)
+			}
+			seen[item.ProductID] = true
+
+			var count int64
+			h.db.Model(&database.Product{}).Where("id = ? AND tenant_id = ?", item.ProductID, tenantID).Count(&count)
+			if count == 0 {
+				return fmt.Sprintf("Product %s not found", item.ProductID)
+			}
+		}
+	}
+	return ""
+}
+
+// saveItems replaces a price list's items
+func saveItems(tx *gorm.DB, listID uuid.UUID, items []PriceListItemRequest) error {
+	if err := tx.Where("price_list_id = ?", listID).Delete(&database.PriceListItem{}).Error; err != nil {
+		return err
+	}
+	for _, item := range items {
+		row := database.PriceListItem{PriceListID: listID, ProductID: item.ProductID, Price: item.Price}
+		if err := tx.Create(&row).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// List returns the tenant's price lists, highest priority first
+func (h *Handler) List(c *gin.Context) {
+	tenantID := c.GetString("tenant_id")
+
+	var lists []database.PriceList
+	if err := h.db.Where("tenant_id = ?", tenantID).
+		Preload("Outlet").
+		Order("priority DESC, name ASC").
+		Find(&lists).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch price lists"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": lists})
+}
+
+// Get returns a price list with its items
+func (h *Handler) Get(c *gin.Context) {
+	tenantID := c.GetString("tenant_id")
+
+	var list database.PriceList
+	if err := h.db.Where("id = ? AND tenant_id = ?", c.Param("id"), tenantID).
+		Preload("Outlet").
+		Preload("Items").
+		First(&list).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Price list not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": list})
+}
+
+// Create adds a price list
+func (h *Handler) Create(c *gin.Context) {
+	tenantID := c.GetString("tenant_id")
+	tenantUUID, _ := uuid.Parse(tenantID)
+
+	var req PriceListRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if msg := h.validate(tenantID, &req); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	list := database.PriceList{
+		TenantID:      tenantUUID,
+		Name:          req.Name,
+		OutletID:      req.OutletID,
+		CustomerGroup: req.CustomerGroup,
+		Days:          req.Days,
+		StartTime:     req.StartTime,
+		EndTime:       req.EndTime,
+		Priority:      req.Priority,
+		IsActive:      true,
+	}
+	if req.IsActive != nil {
+		list.IsActive = *req.IsActive
+	}
+
+	tx := h.db.Begin()
+	if err := tx.Create(&list).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create price list"})
+		return
+	}
+	if req.Items != nil {
+		if err := saveItems(tx, list.ID, *req.Items); err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save price list items"})
+			return
+		}
+	}
+	tx.Commit()
+
+	h.logger.LogCreate(c, "price_list", list.ID, map[string]interface{}{
+		"name":     list.Name,
+		"priority": list.Priority,
+	})
+
+	h.db.Preload("Items").First(&list, list.ID)
+	c.JSON(http.StatusCreated, gin.H{"data": list})
+}
+
+// Update modifies a price list; items are replaced only when given
+func (h *Handler) Update(c *gin.Context) {
+	tenantID := c.GetString("tenant_id")
+
+	var list database.PriceList
+	if err := h.db.Where("id = ? AND tenant_id = ?", c.Param("id"), tenantID).First(&list).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Price list not found"})
+		return
+	}
+
+	var req PriceListRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if msg := h.validate(tenantID, &req); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	oldValues := map[string]interface{}{
+		"name":      list.Name,
+		"priority":  list.Priority,
+		"is_active": list.IsActive,
+	}
+
+	list.Name = req.Name
+	list.OutletID = req.OutletID
+	list.CustomerGroup = req.CustomerGroup
+	list.Days = req.Days
+	list.StartTime = req.StartTime
+	list.EndTime = req.EndTime
+	list.Priority = req.Priority
+	if req.IsActive != nil {
+		list.IsActive = *req.IsActive
+	}
+
+	tx := h.db.Begin()
+	if err := tx.Save(&list).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update price list"})
+		return
+	}
+	if req.Items != nil {
+		if err := saveItems(tx, list.ID, *req.Items); err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save price list items"})
+			return
+		}
+	}
+	tx.Commit()
+
+	h.logger.LogUpdate(c, "price_list", list.ID, oldValues, map[string]interface{}{
+		"name":      list.Name,
+		"priority":  list.Priority,
+		"is_active": list.IsActive,
+	})
+
+	h.db.Preload("Items").First(&list, list.ID)
+	c.JSON(http.StatusOK, gin.H{"data": list})
+}
+
+// Delete soft-deletes a price list
+func (h *Handler) Delete(c *gin.Context) {
+	tenantID := c.GetString("tenant_id")
+
+	var list database.PriceList
+	if err := h.db.Where("id = ? AND tenant_id = ?", c.Param("id"), tenantID).First(&list).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Price list not found"})
+		return
+	}
+
+	if err := h.db.Delete(&list).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete price list"})
+		return
+	}
+
+	h.logger.LogDelete(c, "price_list", list.ID, map[string]interface{}{
+		"name": list.Name,
+	})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Price list deleted"})
+}
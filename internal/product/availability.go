@@ -0,0 +1,229 @@
+package product
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/yuditriaji/warungin-backend/pkg/availability"
+	"github.com/yuditriaji/warungin-backend/pkg/database"
+	"gorm.io/gorm"
+)
+
+type AvailabilityRequest struct {
+	Days  string `json:"days"`  // ISO weekdays, e.g. "1,2,3,4,5"; empty = every day
+	From  string `json:"from"`  // HH:MM; empty with Until = all day
+	Until string `json:"until"` // HH:MM, exclusive; earlier than From spans midnight
+}
+
+type SoldOutRequest struct {
+	OutletID *uuid.UUID `json:"outlet_id"` // Defaults to the user's outlet
+}
+
+// filterAvailable drops products that cannot be sold now. Unavailable
+// variants are dropped from their parent, and a parent with none left is
+// dropped too.
+func filterAvailable(products []database.Product, checker *availability.Checker) []database.Product {
+	var kept []database.Product
+	for _, p := range products {
+		if !checker.Available(p) {
+			continue
+		}
+		if p.HasVariants {
+			var variants []database.Product
+			for _, v := range p.Variants {
+				if checker.Available(v) {
+					variants = append(variants, v)
+				}
+			}
+			if len(variants) == 0 {
+				continue
+			}
+			p.Variants = variants
+		}
+		kept = append(kept, p)
+	}
+	return kept
+}
+
+// soldOutOutlet resolves the outlet a sold-out mark is for: the requested
+// one, else the user's own, else nil for every outlet
+func (h *Handler) soldOutOutlet(c *gin.Context, requested *uuid.UUID) (*uuid.UUID, bool) {
+	tenantID := c.GetString("tenant_id")
+	if requested != nil {
+		var count int64
+		h.db.Model(&database.Outlet{}).Where("id = ? AND tenant_id = ?", *requested, tenantID).Count(&count)
+		if count == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Outlet not found"})
+			return nil, false
+		}
+		return requested, true
+	}
+
+	var user database.User
+	h.db.Select("outlet_id").Where("id = ?", c.GetString("user_id")).First(&user)
+	return user.OutletID, true
+}
+
+// soldOutQuery matches a product's marks for one date and outlet
+func soldOutQuery(db *gorm.DB, productID uuid.UUID, outletID *uuid.UUID, date string) *gorm.DB {
+	query := db.Where("product_id = ? AND date = ?", productID, date)
+	if outletID == nil {
+		return query.Where("outlet_id IS NULL")
+	}
+	return query.Where("outlet_id = ?", *outletID)
+}
+
+// SetAvailability sets the days and hours a product can be sold
+func (h *Handler) SetAvailability(c *gin.Context) {
+	product, ok := h.loadProduct(c)
+	if !ok {
+		return
+	}
+
+	var req AvailabilityRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	oldValues := map[string]interface{}{
+		"available_days":  product.AvailableDays,
+		"available_from":  product.AvailableFrom,
+		"available_until": product.AvailableUntil,
+	}
+
+	product.AvailableDays = req.Days
+	product.AvailableFrom = req.From
+	product.AvailableUntil = req.Until
+	if err := availability.Window(product).Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.db.Model(&product).Updates(map[string]interface{}{
+		"available_days":  product.AvailableDays,
+		"available_from":  product.AvailableFrom,
+		"available_until": product.AvailableUntil,
+	}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update availability"})
+		return
+	}
+
+	h.logger.LogUpdate(c, "product", product.ID, oldValues, map[string]interface{}{
+		"available_days":  product.AvailableDays,
+		"available_from":  product.AvailableFrom,
+		"available_until": product.AvailableUntil,
+	})
+
+	c.JSON(http.StatusOK, gin.H{"data": product})
+}
+
+// ListSoldOut returns today's sold-out marks, for ?outlet_id when given
+func (h *Handler) ListSoldOut(c *gin.Context) {
+	tenantID := c.GetString("tenant_id")
+	today := time.Now().In(h.tenantLocation(tenantID)).Format(availability.DateFormat)
+
+	query := h.db.Where("tenant_id = ? AND date = ?", tenantID, today)
+	if outletID, err := uuid.Parse(c.Query("outlet_id")); err == nil {
+		query = query.Where("outlet_id IS NULL OR outlet_id = ?", outletID)
+	}
+
+	var marks []database.ProductSoldOut
+	if err := query.Preload("Product").Order("created_at ASC").Find(&marks).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch sold-out products"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": marks, "date": today})
+}
+
+// MarkSoldOut marks a product as sold out for the rest of today. The mark
+// lapses on its own the next day.
+func (h *Handler) MarkSoldOut(c *gin.Context) {
+	tenantID := c.GetString("tenant_id")
+	product, ok := h.loadProduct(c)
+	if !ok {
+		return
+	}
+
+	var req SoldOutRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	outletID, ok := h.soldOutOutlet(c, req.OutletID)
+	if !ok {
+		return
+	}
+
+	today := time.Now().In(h.tenantLocation(tenantID)).Format(availability.DateFormat)
+
+	var mark database.ProductSoldOut
+	if err := soldOutQuery(h.db, product.ID, outletID, today).First(&mark).Error; err == nil {
+		c.JSON(http.StatusOK, gin.H{"data": mark})
+		return
+	}
+
+	userID, _ := uuid.Parse(c.GetString("user_id"))
+	mark = database.ProductSoldOut{
+		TenantID:  product.TenantID,
+		ProductID: product.ID,
+		OutletID:  outletID,
+		Date:      today,
+		UserID:    &userID,
+	}
+	if err := h.db.Create(&mark).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to mark product as sold out"})
+		return
+	}
+
+	h.logger.LogCreate(c, "product_sold_out", product.ID, map[string]interface{}{
+		"name":      product.Name,
+		"outlet_id": outletID,
+		"date":      today,
+	})
+
+	c.JSON(http.StatusCreated, gin.H{"data": mark})
+}
+
+// ClearSoldOut makes a product available again today, for ?outlet_id or the
+// user's outlet
+func (h *Handler) ClearSoldOut(c *gin.Context) {
+	tenantID := c.GetString("tenant_id")
+	product, ok := h.loadProduct(c)
+	if !ok {
+		return
+	}
+
+	var requested *uuid.UUID
+	if id, err := uuid.Parse(c.Query("outlet_id")); err == nil {
+		requested = &id
+	}
+	outletID, ok := h.soldOutOutlet(c, requested)
+	if !ok {
+		return
+	}
+
+	today := time.Now().In(h.tenantLocation(tenantID)).Format(availability.DateFormat)
+	result := soldOutQuery(h.db, product.ID, outletID, today).Delete(&database.ProductSoldOut{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to clear sold-out mark"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Product is not marked sold out"})
+		return
+	}
+
+	h.logger.LogDelete(c, "product_sold_out", product.ID, map[string]interface{}{
+		"name":      product.Name,
+		"outlet_id": outletID,
+		"date":      today,
+	})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Product available again"})
+}
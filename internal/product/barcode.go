@@ -0,0 +1,330 @@
+package product
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/yuditriaji/warungin-backend/pkg/barcode"
+	"github.com/yuditriaji/warungin-backend/pkg/database"
+	"gorm.io/gorm"
+)
+
+type BarcodeRequest struct {
+	Code      string `json:"code" binding:"required"`
+	IsPrimary bool   `json:"is_primary"`
+}
+
+// checkBarcode validates a code for a product and returns it normalized with
+// its format. Codes are unique per tenant.
+func checkBarcode(db *gorm.DB, tenantID string, productID uuid.UUID, code string) (string, string, error) {
+	code = barcode.Normalize(code)
+	format, err := barcode.Detect(code)
+	if err != nil {
+		return "", "", err
+	}
+
+	// A UPC-A code and its EAN-13 form scan as the same product
+	var existing database.ProductBarcode
+	codes := append([]string{code}, barcode.Alternates(code)...)
+	if err := db.Where("tenant_id = ? AND code IN ? AND product_id <> ?", tenantID, codes, productID).First(&existing).Error; err == nil {
+		var other database.Product
+		db.Unscoped().Select("name").Where("id = ?", existing.ProductID).First(&other)
+		return "", "", fmt.Errorf("Barcode %s is already used by %s", existing.Code, other.Name)
+	}
+	return code, format, nil
+}
+
+// addBarcode attaches a checked code to a product. The first code becomes the
+// primary one, which is mirrored to Product.Barcode.
+func addBarcode(tx *gorm.DB, product *database.Product, code, format string, primary, internal bool) (database.ProductBarcode, error) {
+	var pb database.ProductBarcode
+	if err := tx.Where("product_id = ? AND code = ?", product.ID, code).First(&pb).Error; err != nil {
+		pb = database.ProductBarcode{
+			TenantID:  product.TenantID,
+			ProductID: product.ID,
+			Code:      code,
+			Format:    format,
+			Internal:  internal,
+		}
+		if err := tx.Create(&pb).Error; err != nil {
+			return pb, err
+		}
+	}
+
+	if primary || product.Barcode == "" {
+		if err := setPrimary(tx, product, &pb); err != nil {
+			return pb, err
+		}
+	}
+	return pb, nil
+}
+
+// setPrimary makes a barcode the product's primary one
+func setPrimary(tx *gorm.DB, product *database.Product, pb *database.ProductBarcode) error {
+	if err := tx.Model(&database.ProductBarcode{}).
+		Where("product_id = ? AND id <> ?", product.ID, pb.ID).
+		Update("is_primary", false).Error; err != nil {
+		return err
+	}
+	if err := tx.Model(pb).Update("is_primary", true).Error; err != nil {
+		return err
+	}
+	product.Barcode = pb.Code
+	return tx.Model(&database.Product{}).Where("id = ?", product.ID).Update("barcode", pb.Code).Error
+}
+
+// removeBarcode deletes a barcode, promoting the oldest remaining one when
+// the primary is removed
+func removeBarcode(tx *gorm.DB, product *database.Product, pb database.ProductBarcode) error {
+	if err := tx.Delete(&pb).Error; err != nil {
+		return err
+	}
+	if !pb.IsPrimary {
+		return nil
+	}
+
+	var next database.ProductBarcode
+	if err := tx.Where("product_id = ?", product.ID).Order("created_at ASC").First(&next).Error; err == nil {
+		return setPrimary(tx, product, &next)
+	}
+	product.Barcode = ""
+	return tx.Model(&database.Product{}).Where("id = ?", product.ID).Update("barcode", "").Error
+}
+
+// replacePrimary swaps a product's primary barcode for code, keeping its
+// other barcodes. Used by endpoints that edit the single barcode field.
+func replacePrimary(tx *gorm.DB, product *database.Product, code, format string) error {
+	old := product.Barcode
+	if _, err := addBarcode(tx, product, code, format, true, false); err != nil {
+		return err
+	}
+	if old != "" && old != code {
+		return tx.Where("product_id = ? AND code = ?", product.ID, old).Delete(&database.ProductBarcode{}).Error
+	}
+	return nil
+}
+
+// newInternalCode generates an in-store EAN-13 not yet used by the tenant
+func newInternalCode(db *gorm.DB, tenantID string) (string, error) {
+	for i := 0; i < 5; i++ {
+		code, err := barcode.Internal()
+		if err != nil {
+			return "", err
+		}
+		var count int64
+		db.Model(&database.ProductBarcode{}).Where("tenant_id = ? AND code = ?", tenantID, code).Count(&count)
+		if count == 0 {
+			return code, nil
+		}
+	}
+	return "", fmt.Errorf("could not generate a unique barcode")
+}
+
+// loadProduct loads one of the tenant's products from the :id parameter
+func (h *Handler) loadProduct(c *gin.Context) (database.Product, bool) {
+	var product database.Product
+	if err := h.db.Where("id = ? AND tenant_id = ?", c.Param("id"), c.GetString("tenant_id")).First(&product).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+		return product, false
+	}
+	return product, true
+}
+
+// Lookup finds the product for a scanned code. Barcodes are matched first,
+// then SKUs. A variant's code returns its parent product with the variant.
+func (h *Handler) Lookup(c *gin.Context) {
+	tenantID := c.GetString("tenant_id")
+	code := barcode.Normalize(c.Query("code"))
+	if code == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "code is required"})
+		return
+	}
+
+	var product database.Product
+	found := false
+	var pb database.ProductBarcode
+	codes := append([]string{code}, barcode.Alternates(code)...)
+	if err := h.db.Where("tenant_id = ? AND code IN ?", tenantID, codes).First(&pb).Error; err == nil {
+		found = h.db.Where("id = ?", pb.ProductID).First(&product).Error == nil
+	}
+	if !found {
+		found = h.db.Where("tenant_id = ? AND sku = ?", tenantID, code).First(&product).Error == nil
+	}
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Produk dengan kode " + code + " tidak ditemukan"})
+		return
+	}
+
+	var variant *database.Product
+	if product.ParentID != nil {
+		v := product
+		variant = &v
+		if err := h.db.Where("id = ?", *product.ParentID).First(&product).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Produk dengan kode " + code + " tidak ditemukan"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": gin.H{
+		"product": product,
+		"variant": variant,
+	}})
+}
+
+// ListBarcodes returns a product's barcodes, primary first
+func (h *Handler) ListBarcodes(c *gin.Context) {
+	product, ok := h.loadProduct(c)
+	if !ok {
+		return
+	}
+
+	var barcodes []database.ProductBarcode
+	h.db.Where("product_id = ?", product.ID).Order("is_primary DESC, created_at ASC").Find(&barcodes)
+
+	c.JSON(http.StatusOK, gin.H{"data": barcodes})
+}
+
+// AddBarcode attaches another barcode to a product
+func (h *Handler) AddBarcode(c *gin.Context) {
+	product, ok := h.loadProduct(c)
+	if !ok {
+		return
+	}
+
+	var req BarcodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	code, format, err := checkBarcode(h.db, c.GetString("tenant_id"), product.ID, req.Code)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tx := h.db.Begin()
+	pb, err := addBarcode(tx, &product, code, format, req.IsPrimary, false)
+	if err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add barcode"})
+		return
+	}
+	tx.Commit()
+
+	h.logger.LogUpdate(c, "product", product.ID, nil, map[string]interface{}{
+		"barcode_added": pb.Code,
+	})
+
+	c.JSON(http.StatusCreated, gin.H{"data": pb})
+}
+
+// GenerateBarcode gives a product an internal EAN-13 barcode
+func (h *Handler) GenerateBarcode(c *gin.Context) {
+	tenantID := c.GetString("tenant_id")
+
+	product, ok := h.loadProduct(c)
+	if !ok {
+		return
+	}
+
+	code, err := newInternalCode(h.db, tenantID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate barcode"})
+		return
+	}
+
+	tx := h.db.Begin()
+	pb, err := addBarcode(tx, &product, code, barcode.EAN13, c.Query("primary") == "true", true)
+	if err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate barcode"})
+		return
+	}
+	tx.Commit()
+
+	c.JSON(http.StatusCreated, gin.H{"data": pb})
+}
+
+// GenerateMissingBarcodes gives every product without a barcode an internal
+// one. Parents of variants are skipped; their variants are labelled instead.
+func (h *Handler) GenerateMissingBarcodes(c *gin.Context) {
+	tenantID := c.GetString("tenant_id")
+
+	var products []database.Product
+	h.db.Where("tenant_id = ? AND barcode = ? AND has_variants = ?", tenantID, "", false).Find(&products)
+
+	tx := h.db.Begin()
+	for i := range products {
+		code, err := newInternalCode(tx, tenantID)
+		if err == nil {
+			_, err = addBarcode(tx, &products[i], code, barcode.EAN13, true, true)
+		}
+		if err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate barcodes"})
+			return
+		}
+	}
+	tx.Commit()
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":   fmt.Sprintf("%d barcodes generated", len(products)),
+		"generated": len(products),
+	})
+}
+
+// SetPrimaryBarcode makes one of a product's barcodes the primary one
+func (h *Handler) SetPrimaryBarcode(c *gin.Context) {
+	product, ok := h.loadProduct(c)
+	if !ok {
+		return
+	}
+
+	var pb database.ProductBarcode
+	if err := h.db.Where("id = ? AND product_id = ?", c.Param("barcode_id"), product.ID).First(&pb).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Barcode not found"})
+		return
+	}
+
+	tx := h.db.Begin()
+	if err := setPrimary(tx, &product, &pb); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update barcode"})
+		return
+	}
+	tx.Commit()
+
+	pb.IsPrimary = true
+	c.JSON(http.StatusOK, gin.H{"data": pb})
+}
+
+// DeleteBarcode removes a barcode from a product
+func (h *Handler) DeleteBarcode(c *gin.Context) {
+	product, ok := h.loadProduct(c)
+	if !ok {
+		return
+	}
+
+	var pb database.ProductBarcode
+	if err := h.db.Where("id = ? AND product_id = ?", c.Param("barcode_id"), product.ID).First(&pb).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Barcode not found"})
+		return
+	}
+
+	tx := h.db.Begin()
+	if err := removeBarcode(tx, &product, pb); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete barcode"})
+		return
+	}
+	tx.Commit()
+
+	h.logger.LogUpdate(c, "product", product.ID, map[string]interface{}{
+		"barcode_removed": pb.Code,
+	}, nil)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Barcode deleted"})
+}
@@ -0,0 +1,245 @@
+package product
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/yuditriaji/warungin-backend/pkg/database"
+	"gorm.io/gorm"
+)
+
+type BundleComponentRequest struct {
+	ProductID  uuid.UUID `json:"product_id" binding:"required"` // A variant for products with variants
+	Quantity   int       `json:"quantity" binding:"required,min=1"`
+	PriceDelta float64   `json:"price_delta"` // Options only: added to the bundle price when chosen
+}
+
+type BundleGroupRequest struct {
+	Name      string                   `json:"name" binding:"required"`
+	MinSelect int                      `json:"min_select" binding:"min=0"`
+	MaxSelect int                      `json:"max_select" binding:"required,min=1"`
+	Options   []BundleComponentRequest `json:"options" binding:"required,min=1,dive"`
+}
+
+// BundleRequest replaces a bundle's contents
+type BundleRequest struct {
+	Components []BundleComponentRequest `json:"components" binding:"dive"` // Always included
+	Groups     []BundleGroupRequest     `json:"groups" binding:"dive"`     // Customer picks from each
+}
+
+// preloadBundle loads a bundle's fixed components and choice groups
+func preloadBundle(db *gorm.DB) *gorm.DB {
+	return db.
+		Preload("Components", func(db *gorm.DB) *gorm.DB {
+			return db.Where("group_id IS NULL").Order("sort_order ASC")
+		}).
+		Preload("Components.Product").
+		Preload("BundleGroups", func(db *gorm.DB) *gorm.DB { return db.Order("sort_order ASC") }).
+		Preload("BundleGroups.Options", func(db *gorm.DB) *gorm.DB { return db.Order("sort_order ASC") }).
+		Preload("BundleGroups.Options.Product")
+}
+
+// checkComponent validates a product used inside a bundle
+func (h *Handler) checkComponent(tenantID string, bundle database.Product, req BundleComponentRequest) error {
+	var product database.Product
+	if err := h.db.Where("id = ? AND tenant_id = ?", req.ProductID, tenantID).First(&product).Error; err != nil {
+		return fmt.Errorf("Product %s not found", req.ProductID)
+	}
+	switch {
+	case product.ID == bundle.ID:
+		return fmt.Errorf("A bundle cannot contain itself")
+	case product.IsBundle:
+		return fmt.Errorf("%s is a bundle; bundles cannot be nested", product.Name)
+	case product.HasVariants:
+		return fmt.Errorf("Choose a variant of %s", product.Name)
+	}
+	return nil
+}
+
+// GetBundle returns a bundle's components and choice groups
+func (h *Handler) GetBundle(c *gin.Context) {
+	tenantID := c.GetString("tenant_id")
+
+	var bundle database.Product
+	if err := preloadBundle(h.db).Where("id = ? AND tenant_id = ?", c.Param("id"), tenantID).First(&bundle).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+		return
+	}
+	if !bundle.IsBundle {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Product is not a bundle"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": bundle})
+}
+
+// SetBundle turns a product into a bundle, or replaces an existing bundle's
+// contents. The bundle keeps its own price; selling it deducts its components.
+func (h *Handler) SetBundle(c *gin.Context) {
+	tenantID := c.GetString("tenant_id")
+
+	var bundle database.Product
+	if err := h.db.Where("id = ? AND tenant_id = ?", c.Param("id"), tenantID).First(&bundle).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+		return
+	}
+	if bundle.ParentID != nil || bundle.HasVariants {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Products with variants cannot be bundles"})
+		return
+	}
+	if !bundle.IsBundle && !bundle.UseMaterialStock && bundle.StockQty > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("Product still has %d in stock. Adjust its stock to 0 before making it a bundle", bundle.StockQty),
+		})
+		return
+	}
+	if names := bundlesUsing(h.db, bundle); len(names) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Product is part of bundles: " + strings.Join(names, ", ") + "; bundles cannot be nested"})
+		return
+	}
+
+	var req BundleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(req.Components) == 0 && len(req.Groups) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A bundle needs at least one component or choice group"})
+		return
+	}
+
+	for _, comp := range req.Components {
+		if err := h.checkComponent(tenantID, bundle, comp); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	for i, group := range req.Groups {
+		req.Groups[i].Name = strings.TrimSpace(group.Name)
+		if req.Groups[i].Name == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Choice group name is required"})
+			return
+		}
+		if group.MinSelect > group.MaxSelect {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("%s: min_select cannot be more than max_select", group.Name)})
+			return
+		}
+		for _, option := range group.Options {
+			if err := h.checkComponent(tenantID, bundle, option); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+		}
+	}
+
+	tx := h.db.Begin()
+
+	// Contents are replaced as a whole; past sales keep their own component records
+	if err := tx.Where("bundle_id = ?", bundle.ID).Delete(&database.BundleComponent{}).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update bundle"})
+		return
+	}
+	if err := tx.Where("bundle_id = ?", bundle.ID).Delete(&database.BundleGroup{}).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update bundle"})
+		return
+	}
+
+	for i, comp := range req.Components {
+		component := database.BundleComponent{
+			BundleID:  bundle.ID,
+			ProductID: comp.ProductID,
+			Quantity:  comp.Quantity,
+			SortOrder: i,
+		}
+		if err := tx.Create(&component).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update bundle"})
+			return
+		}
+	}
+	for i, g := range req.Groups {
+		group := database.BundleGroup{
+			BundleID:  bundle.ID,
+			Name:      g.Name,
+			MinSelect: g.MinSelect,
+			MaxSelect: g.MaxSelect,
+			SortOrder: i,
+		}
+		if err := tx.Create(&group).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update bundle"})
+			return
+		}
+		for j, opt := range g.Options {
+			option := database.BundleComponent{
+				BundleID:   bundle.ID,
+				GroupID:    &group.ID,
+				ProductID:  opt.ProductID,
+				Quantity:   opt.Quantity,
+				PriceDelta: opt.PriceDelta,
+				SortOrder:  j,
+			}
+			if err := tx.Create(&option).Error; err != nil {
+				tx.Rollback()
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update bundle"})
+				return
+			}
+		}
+	}
+
+	if err := tx.Model(&bundle).Update("is_bundle", true).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update bundle"})
+		return
+	}
+	tx.Commit()
+
+	h.logger.LogUpdate(c, "product", bundle.ID, nil, map[string]interface{}{
+		"name":       bundle.Name,
+		"components": len(req.Components),
+		"groups":     len(req.Groups),
+	})
+
+	preloadBundle(h.db).Where("id = ?", bundle.ID).First(&bundle)
+	c.JSON(http.StatusOK, gin.H{"data": bundle})
+}
+
+// DeleteBundle turns a bundle back into a regular product
+func (h *Handler) DeleteBundle(c *gin.Context) {
+	tenantID := c.GetString("tenant_id")
+
+	var bundle database.Product
+	if err := h.db.Where("id = ? AND tenant_id = ? AND is_bundle = ?", c.Param("id"), tenantID, true).First(&bundle).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Bundle not found"})
+		return
+	}
+
+	tx := h.db.Begin()
+	tx.Where("bundle_id = ?", bundle.ID).Delete(&database.BundleComponent{})
+	tx.Where("bundle_id = ?", bundle.ID).Delete(&database.BundleGroup{})
+	if err := tx.Model(&bundle).Update("is_bundle", false).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove bundle"})
+		return
+	}
+	tx.Commit()
+
+	c.JSON(http.StatusOK, gin.H{"message": "Bundle removed"})
+}
+
+// bundlesUsing returns the names of bundles that contain a product or its variants
+func bundlesUsing(db *gorm.DB, product database.Product) []string {
+	var names []string
+	db.Model(&database.Product{}).
+		Distinct("products.name").
+		Joins("JOIN bundle_components ON bundle_components.bundle_id = products.id").
+		Where("bundle_components.product_id = ? OR bundle_components.product_id IN (?)",
+			product.ID, db.Model(&database.Product{}).Select("id").Where("parent_id = ?", product.ID)).
+		Pluck("products.name", &names)
+	return names
+}
@@ -3,29 +3,42 @@ package product
 import (
 	"math"
 	"net/http"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/yuditriaji/warungin-backend/pkg/activitylog"
+	"github.com/yuditriaji/warungin-backend/pkg/availability"
+	"github.com/yuditriaji/warungin-backend/pkg/categories"
+	"github.com/yuditriaji/warungin-backend/pkg/costing"
 	"github.com/yuditriaji/warungin-backend/pkg/database"
+	"github.com/yuditriaji/warungin-backend/pkg/images"
+	"github.com/yuditriaji/warungin-backend/pkg/pagination"
+	"github.com/yuditriaji/warungin-backend/pkg/pricing"
+	"github.com/yuditriaji/warungin-backend/pkg/search"
+	"github.com/yuditriaji/warungin-backend/pkg/stock"
+	"github.com/yuditriaji/warungin-backend/pkg/storage"
 	"gorm.io/gorm"
 )
 
 type Handler struct {
 	db     *gorm.DB
 	logger *activitylog.Logger
+	store  storage.Storage
 }
 
-func NewHandler(db *gorm.DB) *Handler {
+func NewHandler(db *gorm.DB, store storage.Storage) *Handler {
 	return &Handler{
 		db:     db,
 		logger: activitylog.NewLogger(db),
+		store:  store,
 	}
 }
 
 type CreateProductRequest struct {
 	Name             string     `json:"name" binding:"required"`
 	SKU              string     `json:"sku"`
+	Barcode          string     `json:"barcode"` // Primary barcode; empty keeps the current one on update
 	Price            float64    `json:"price" binding:"required"`
 	Cost             float64    `json:"cost"`
 	StockQty         int        `json:"stock_qty"`
@@ -33,6 +46,11 @@ type CreateProductRequest struct {
 	ImageURL         string     `json:"image_url"`
 	OutletID         string     `json:"outlet_id"`
 	UseMaterialStock bool       `json:"use_material_stock"`
+	MinStockLevel    *int       `json:"min_stock_level" binding:"omitempty,min=0"` // 0 = no low stock alert; omitted = default on create, unchanged on update
+	ReorderQty       int        `json:"reorder_qty"`
+	AutoReorderPoint bool       `json:"auto_reorder_point"`
+	LeadTimeDays     int        `json:"lead_time_days"`
+	SalesWindowDays  int        `json:"sales_window_days"`
 }
 
 // ProductResponse includes calculated fields for material-driven products
@@ -41,31 +59,127 @@ type ProductResponse struct {
 	CalculatedStock int `json:"calculated_stock,omitempty"`
 }
 
-// List returns all products for the tenant, filtered by outlet
+// List returns the tenant's products with their variants. Query parameters:
+//
+//	q              name or SKU containing it, or barcode starting with it;
+//	               tolerates typos in names when pg_trgm is installed
+//	category_id    includes subcategories
+//	outlet_id      products of one outlet
+//	is_active      true or false
+//	stock_status   ok, low or out against the minimum stock level
+//	min_price      list price bounds; a parent matches through any variant
+//	max_price
+//	available      true for what the POS can sell now at outlet_id
+//	sort, order    name (default), price, stock_qty, created_at or
+//	               updated_at; asc or desc. Searches sort by relevance.
+//	page, per_page default 1 and 50; without either, the first 200
 func (h *Handler) List(c *gin.Context) {
 	tenantID := c.GetString("tenant_id")
 	outletID := c.Query("outlet_id")
+	term := strings.TrimSpace(c.Query("q"))
+
+	// Typo-tolerant searches set their similarity threshold for one
+	// transaction, so it can't leak to other requests on the connection
+	db := h.db
+	if term != "" && search.Trigram(h.db) {
+		tx := h.db.Begin()
+		defer tx.Rollback()
+		if err := search.UseThreshold(tx); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch products"})
+			return
+		}
+		db = tx
+	}
 
-	query := h.db.Where("tenant_id = ?", tenantID)
+	// Variants are listed under their parent product
+	query := db.Model(&database.Product{}).Where("products.tenant_id = ? AND products.parent_id IS NULL", tenantID)
 	
 	// Filter by outlet_id if provided
 	if outletID != "" {
-		query = query.Where("outlet_id = ?", outletID)
+		query = query.Where("products.outlet_id = ?", outletID)
+	}
+
+	// Filter by category, including its subcategories
+	if categoryID, err := uuid.Parse(c.Query("category_id")); err == nil {
+		tree := categories.Load(h.db, tenantID)
+		query = query.Where("products.category_id IN ?", tree.WithDescendants(categoryID))
+	}
+
+	query, err := filterProducts(query, c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if term != "" {
+		query = h.searchProducts(query, term)
+	}
+
+	// With ?available=true, only products that can be sold now at ?outlet_id
+	// are returned: active, inside their sale hours and not sold out today
+	var checker *availability.Checker
+	if c.Query("available") == "true" {
+		checker = h.availabilityChecker(tenantID, outletID)
+		query = h.availableOnly(query, checker)
 	}
+	query = query.Session(&gorm.Session{})
+
+	// Clients from before paging send neither page nor per_page and expect
+	// the whole catalog; they get the largest page instead
+	page, paginated := pagination.FromQuery(c)
+	if !paginated {
+		page.PerPage = pagination.MaxPerPage
+	}
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch products"})
+		return
+	}
+
+	ordered, err := h.orderProducts(query, c, term)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	ordered = page.Apply(ordered)
 
 	var products []database.Product
-	if err := query.Preload("Category").Preload("Outlet").Find(&products).Error; err != nil {
+	if err := preloadBundle(ordered).Preload("Category").Preload("Outlet").
+		Preload("Variants", func(db *gorm.DB) *gorm.DB { return db.Order("created_at ASC") }).
+		Find(&products).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch products"})
 		return
 	}
 
+	// Drop the variants that can't be sold now
+	if checker != nil {
+		products = filterAvailable(products, checker)
+	}
+
+	// With ?effective_price=true, prices include the price lists that apply
+	// at ?outlet_id, ?customer_group and ?at (RFC 3339, default now)
+	var prices *pricing.Prices
+	if c.Query("effective_price") == "true" {
+		ctx, err := h.priceContext(c, tenantID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		prices = pricing.Load(h.db, tenantID, ctx)
+	}
+
 	// Build response with calculated stock for material-driven products
 	var response []ProductResponse
 	for _, p := range products {
+		if prices != nil {
+			applyPrice(prices, &p)
+			for i := range p.Variants {
+				applyPrice(prices, &p.Variants[i])
+			}
+		}
 		pr := ProductResponse{Product: p}
 		
 		if p.UseMaterialStock {
-			pr.CalculatedStock = h.calculateMaterialStock(p.ID)
+			pr.CalculatedStock = stock.MaterialStock(h.db, p.ID)
 			// Override stock_qty with calculated for frontend compatibility
 			pr.Product.StockQty = pr.CalculatedStock
 		}
@@ -73,7 +187,7 @@ func (h *Handler) List(c *gin.Context) {
 		response = append(response, pr)
 	}
 
-	c.JSON(http.StatusOK, gin.H{"data": response})
+	c.JSON(http.StatusOK, gin.H{"data": response, "pagination": page.Meta(total)})
 }
 
 // Create adds a new product
@@ -87,6 +201,33 @@ func (h *Handler) Create(c *gin.Context) {
 	tenantIDStr := c.GetString("tenant_id")
 	tenantID, _ := uuid.Parse(tenantIDStr)
 
+	if req.CategoryID != nil && !categories.Load(h.db, tenantIDStr).Exists(*req.CategoryID) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Category not found"})
+		return
+	}
+
+	var code, format string
+	if req.Barcode != "" {
+		var err error
+		if code, format, err = checkBarcode(h.db, tenantIDStr, uuid.Nil, req.Barcode); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	minStock := stock.DefaultMinStockLevel
+	if req.MinStockLevel != nil {
+		minStock = *req.MinStockLevel
+	}
+	leadTime := req.LeadTimeDays
+	if leadTime <= 0 {
+		leadTime = stock.DefaultLeadTimeDays
+	}
+	salesWindow := req.SalesWindowDays
+	if salesWindow <= 0 {
+		salesWindow = stock.DefaultSalesWindowDays
+	}
+
 	product := database.Product{
 		TenantID:         tenantID,
 		Name:             req.Name,
@@ -97,6 +238,11 @@ func (h *Handler) Create(c *gin.Context) {
 		CategoryID:       req.CategoryID,
 		ImageURL:         req.ImageURL,
 		UseMaterialStock: req.UseMaterialStock,
+		MinStockLevel:    minStock,
+		ReorderQty:       req.ReorderQty,
+		AutoReorderPoint: req.AutoReorderPoint,
+		LeadTimeDays:     leadTime,
+		SalesWindowDays:  salesWindow,
 		IsActive:         true,
 	}
 
@@ -108,7 +254,20 @@ func (h *Handler) Create(c *gin.Context) {
 		}
 	}
 
-	if err := h.db.Create(&product).Error; err != nil {
+	tx := h.db.Begin()
+	if err := createProduct(tx, &product); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create product"})
+		return
+	}
+	if code != "" {
+		if _, err := addBarcode(tx, &product, code, format, true, false); err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create product"})
+			return
+		}
+	}
+	if err := tx.Commit().Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create product"})
 		return
 	}
@@ -123,14 +282,27 @@ func (h *Handler) Create(c *gin.Context) {
 	c.JSON(http.StatusCreated, gin.H{"data": product})
 }
 
+// createProduct inserts a product. gorm leaves a zero min_stock_level to the
+// column default, so a threshold of 0 (no alert) is written separately.
+func createProduct(tx *gorm.DB, product *database.Product) error {
+	if err := tx.Create(product).Error; err != nil {
+		return err
+	}
+	if product.MinStockLevel == 0 {
+		return tx.Model(product).Update("min_stock_level", 0).Error
+	}
+	return nil
+}
+
 // Get returns a single product
 func (h *Handler) Get(c *gin.Context) {
 	tenantID := c.GetString("tenant_id")
 	productID := c.Param("id")
 
 	var product database.Product
-	if err := h.db.Where("id = ? AND tenant_id = ?", productID, tenantID).
+	if err := preloadBundle(h.db).Where("id = ? AND tenant_id = ?", productID, tenantID).
 		Preload("Category").
+		Preload("Variants", func(db *gorm.DB) *gorm.DB { return db.Order("created_at ASC") }).
 		First(&product).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
 		return
@@ -150,6 +322,11 @@ func (h *Handler) Update(c *gin.Context) {
 		return
 	}
 
+	if product.ParentID != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Use the product's variant endpoints to edit a variant"})
+		return
+	}
+
 	// Store old values for logging
 	oldValues := map[string]interface{}{
 		"name":     product.Name,
@@ -165,19 +342,97 @@ func (h *Handler) Update(c *gin.Context) {
 		return
 	}
 
+	if req.CategoryID != nil && !categories.Load(h.db, tenantID).Exists(*req.CategoryID) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Category not found"})
+		return
+	}
+
+	if req.StockQty < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Stock cannot go below zero"})
+		return
+	}
+
+	var code, format string
+	if req.Barcode != "" && req.Barcode != product.Barcode {
+		var err error
+		if code, format, err = checkBarcode(h.db, tenantID, product.ID, req.Barcode); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	// Stock changes go through the costing ledger so fifo layers stay in
+	// sync. With average or fifo costing the cost follows receipts, so an
+	// edited cost is ignored.
+	method := costing.LoadMethod(h.db, tenantID)
+	omit := []string{"stock_qty"}
+	if method == costing.MethodManual {
+		product.Cost = req.Cost
+	} else {
+		omit = append(omit, "cost")
+	}
+	// Material-driven products are listed with their calculated stock, which
+	// clients send back; the stored stock_qty isn't used for them
+	stockDelta := req.StockQty - product.StockQty
+	if req.UseMaterialStock {
+		stockDelta = 0
+	}
+
 	product.Name = req.Name
 	product.SKU = req.SKU
 	product.Price = req.Price
-	product.Cost = req.Cost
-	product.StockQty = req.StockQty
 	product.CategoryID = req.CategoryID
-	product.ImageURL = req.ImageURL
 	product.UseMaterialStock = req.UseMaterialStock
+	if req.MinStockLevel != nil {
+		product.MinStockLevel = *req.MinStockLevel
+	}
+	product.ReorderQty = req.ReorderQty
+	product.AutoReorderPoint = req.AutoReorderPoint
+	if req.LeadTimeDays > 0 {
+		product.LeadTimeDays = req.LeadTimeDays
+	}
+	if req.SalesWindowDays > 0 {
+		product.SalesWindowDays = req.SalesWindowDays
+	}
 
-	if err := h.db.Save(&product).Error; err != nil {
+	tx := h.db.Begin()
+	if err := tx.Omit(omit...).Save(&product).Error; err != nil {
+		tx.Rollback()
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update product"})
 		return
 	}
+	if stockDelta != 0 {
+		ledger := costing.NewLedger(tx, product.TenantID, method)
+		if err := ledger.Adjust(costing.ItemProduct, product.ID, float64(stockDelta)); err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update stock"})
+			return
+		}
+	}
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update product"})
+		return
+	}
+	h.db.Select("stock_qty", "cost").Where("id = ?", product.ID).First(&product)
+
+	// An external image URL replaces any uploaded image
+	if req.ImageURL != product.ImageURL {
+		if err := h.swapImage(c.Request.Context(), &product, images.Stored{URL: req.ImageURL}); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update product"})
+			return
+		}
+	}
+	if code != "" {
+		if err := replacePrimary(h.db, &product, code, format); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update barcode"})
+			return
+		}
+	}
+
+	// Keep variants' names, category, outlet and tax in line with the parent
+	if product.HasVariants {
+		syncVariants(h.db, product)
+	}
 
 	// Log activity with old and new values
 	h.logger.LogUpdate(c, "product", product.ID, oldValues, map[string]interface{}{
@@ -203,11 +458,31 @@ func (h *Handler) Delete(c *gin.Context) {
 		return
 	}
 
+	if names := bundlesUsing(h.db, product); len(names) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Product is part of bundles: " + strings.Join(names, ", ")})
+		return
+	}
+
 	if err := h.db.Delete(&product).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete product"})
 		return
 	}
 
+	// Variants, barcodes and sold-out marks go with their product
+	h.db.Where("product_id = ? OR product_id IN (?)", product.ID,
+		h.db.Model(&database.Product{}).Select("id").Where("parent_id = ?", product.ID)).
+		Delete(&database.ProductBarcode{})
+	h.db.Where("product_id = ? OR product_id IN (?)", product.ID,
+		h.db.Model(&database.Product{}).Select("id").Where("parent_id = ?", product.ID)).
+		Delete(&database.ProductSoldOut{})
+	if product.HasVariants {
+		h.db.Where("parent_id = ?", product.ID).Delete(&database.Product{})
+	}
+	if product.IsBundle {
+		h.db.Where("bundle_id = ?", product.ID).Delete(&database.BundleComponent{})
+		h.db.Where("bundle_id = ?", product.ID).Delete(&database.BundleGroup{})
+	}
+
 	// Log activity
 	h.logger.LogDelete(c, "product", product.ID, map[string]interface{}{
 		"name":  product.Name,
@@ -243,6 +518,10 @@ func (h *Handler) ToggleActive(c *gin.Context) {
 		return
 	}
 
+	if product.HasVariants {
+		h.db.Model(&database.Product{}).Where("parent_id = ?", product.ID).Update("is_active", req.IsActive)
+	}
+
 	// Log toggle action
 	h.logger.LogToggle(c, "product", product.ID, product.IsActive, product.Name)
 
@@ -335,33 +614,3 @@ func (h *Handler) GetAvailableStock(c *gin.Context) {
 	})
 }
 
-// calculateMaterialStock returns the max units that can be made from available materials
-func (h *Handler) calculateMaterialStock(productID uuid.UUID) int {
-	var productMaterials []database.ProductMaterial
-	h.db.Where("product_id = ?", productID).Preload("Material").Find(&productMaterials)
-
-	if len(productMaterials) == 0 {
-		return 0
-	}
-
-	availableStock := math.MaxFloat64
-	for _, pm := range productMaterials {
-		if pm.QuantityUsed <= 0 {
-			continue
-		}
-		convRate := pm.ConversionRate
-		if convRate <= 0 {
-			convRate = 1
-		}
-		actualUsage := pm.QuantityUsed * convRate
-		canMake := pm.Material.StockQty / actualUsage
-		if canMake < availableStock {
-			availableStock = canMake
-		}
-	}
-
-	if availableStock == math.MaxFloat64 {
-		return 0
-	}
-	return int(math.Floor(availableStock))
-}
@@ -0,0 +1,114 @@
+package product
+
+import (
+	"context"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yuditriaji/warungin-backend/pkg/database"
+	"github.com/yuditriaji/warungin-backend/pkg/images"
+	"github.com/yuditriaji/warungin-backend/pkg/storage"
+)
+
+// swapImage points a product, and any of its variants still showing its old
+// image, at a new image. The old upload is deleted once nothing shows it.
+func (h *Handler) swapImage(ctx context.Context, product *database.Product, stored images.Stored) error {
+	old := *product
+	if err := h.db.Model(&database.Product{}).Where("id = ?", product.ID).Updates(map[string]interface{}{
+		"image_url":     stored.URL,
+		"thumbnail_url": stored.ThumbnailURL,
+		"image_key":     stored.Key,
+	}).Error; err != nil {
+		return err
+	}
+	if product.HasVariants && old.ImageURL != "" {
+		h.db.Model(&database.Product{}).Where("parent_id = ? AND image_url = ?", product.ID, old.ImageURL).
+			Updates(map[string]interface{}{"image_url": stored.URL, "thumbnail_url": stored.ThumbnailURL})
+	}
+	product.ImageURL, product.ThumbnailURL, product.ImageKey = stored.URL, stored.ThumbnailURL, stored.Key
+
+	if old.ImageKey != "" {
+		var count int64
+		h.db.Model(&database.Product{}).Where("image_url = ?", old.ImageURL).Count(&count)
+		if count == 0 {
+			if err := images.Remove(ctx, h.store, old.ImageKey); err != nil {
+				log.Printf("Failed to delete image %s: %v", old.ImageKey, err)
+			}
+		}
+	}
+	return nil
+}
+
+// UploadImage replaces a product's image with an uploaded file (form field
+// "image") and a thumbnail
+func (h *Handler) UploadImage(c *gin.Context) {
+	tenantID := c.GetString("tenant_id")
+	product, ok := h.loadProduct(c)
+	if !ok {
+		return
+	}
+
+	header, err := c.FormFile("image")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No file uploaded"})
+		return
+	}
+	data, err := images.Read(header)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	img, err := images.Process(data, images.Product)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	oldURL := product.ImageURL
+	ctx := c.Request.Context()
+	stored, err := images.Save(ctx, h.store, storage.TenantKey(tenantID, "products", product.ID.String()), img)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store image"})
+		return
+	}
+	if err := h.swapImage(ctx, &product, stored); err != nil {
+		images.Remove(ctx, h.store, stored.Key)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update product"})
+		return
+	}
+
+	h.logger.LogUpdate(c, "product", product.ID, map[string]interface{}{
+		"image_url": oldURL,
+	}, map[string]interface{}{
+		"image_url": product.ImageURL,
+	})
+
+	c.JSON(http.StatusOK, gin.H{"data": product})
+}
+
+// DeleteImage removes a product's image
+func (h *Handler) DeleteImage(c *gin.Context) {
+	product, ok := h.loadProduct(c)
+	if !ok {
+		return
+	}
+	if product.ImageURL == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Product has no image"})
+		return
+	}
+
+	oldURL := product.ImageURL
+	if err := h.swapImage(c.Request.Context(), &product, images.Stored{}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update product"})
+		return
+	}
+
+	h.logger.LogUpdate(c, "product", product.ID, map[string]interface{}{
+		"image_url": oldURL,
+	}, map[string]interface{}{
+		"image_url": "",
+	})
+
+	c.JSON(http.StatusOK, gin.H{"data": product})
+}
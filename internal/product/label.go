@@ -0,0 +1,172 @@
+package product
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/yuditriaji/warungin-backend/pkg/barcode"
+	"github.com/yuditriaji/warungin-backend/pkg/database"
+	"github.com/yuditriaji/warungin-backend/pkg/pdf"
+)
+
+// Label sheet layout
+const (
+	MaxLabelsPerPrint  = 1000
+	DefaultLabelCols   = 3
+	DefaultLabelRows   = 8
+	labelSheetMargin   = 8 * pdf.MM
+	labelPadding       = 2 * pdf.MM
+	barcodeQuietModule = 10 // Blank modules either side of the bars
+)
+
+type LabelRequest struct {
+	Items []struct {
+		ProductID uuid.UUID `json:"product_id" binding:"required"`
+		Copies    int       `json:"copies"` // Defaults to 1
+	} `json:"items" binding:"required,min=1,dive"`
+	Columns int `json:"columns"` // Labels across an A4 sheet, default 3
+	Rows    int `json:"rows"`    // Labels down an A4 sheet, default 8
+}
+
+// formatPrice formats a price as Rupiah with thousand separators
+func formatPrice(amount float64) string {
+	digits := fmt.Sprintf("%.0f", amount)
+	var b strings.Builder
+	for i, d := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			b.WriteByte('.')
+		}
+		b.WriteRune(d)
+	}
+	return "Rp " + b.String()
+}
+
+// drawLabel draws one product label in the cell with bottom-left corner x, y
+func drawLabel(page *pdf.Page, x, y, w, h float64, product database.Product, modules []bool) {
+	inner := w - 2*labelPadding
+	top := y + h - labelPadding
+
+	// Name and price
+	nameSize := 8.0
+	page.Text(x+labelPadding, top-nameSize, pdf.HelveticaBold, nameSize, pdf.Fit(pdf.HelveticaBold, nameSize, inner, product.Name))
+	priceSize := 9.0
+	priceY := top - nameSize - 2 - priceSize
+	page.Text(x+labelPadding, priceY, pdf.Helvetica, priceSize, formatPrice(product.Price))
+
+	// Human-readable code under the bars
+	digitSize := 7.0
+	digitY := y + labelPadding
+	digitWidth := pdf.TextWidth(pdf.Courier, digitSize, product.Barcode)
+	page.Text(x+(w-digitWidth)/2, digitY, pdf.Courier, digitSize, product.Barcode)
+
+	// Bars fill the space between price and digits, as wide as fits
+	barBottom := digitY + digitSize + 1
+	barHeight := priceY - 3 - barBottom
+	if barHeight <= 0 {
+		return
+	}
+	module := inner / float64(len(modules)+2*barcodeQuietModule)
+	if module > 0.5*pdf.MM {
+		module = 0.5 * pdf.MM
+	}
+	barX := x + (w-module*float64(len(modules)))/2
+	for i := 0; i < len(modules); {
+		if !modules[i] {
+			i++
+			continue
+		}
+		// Merge runs of dark modules into one bar
+		j := i
+		for j < len(modules) && modules[j] {
+			j++
+		}
+		page.Rect(barX+float64(i)*module, barBottom, float64(j-i)*module, barHeight)
+		i = j
+	}
+}
+
+// PrintLabels renders a PDF sheet of price labels with barcodes. Products
+// must already have a primary barcode; see GenerateBarcode.
+func (h *Handler) PrintLabels(c *gin.Context) {
+	tenantID := c.GetString("tenant_id")
+
+	var req LabelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Columns <= 0 {
+		req.Columns = DefaultLabelCols
+	}
+	if req.Rows <= 0 {
+		req.Rows = DefaultLabelRows
+	}
+	if req.Columns > 6 || req.Rows > 15 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A sheet can have at most 6 columns and 15 rows"})
+		return
+	}
+
+	type label struct {
+		product database.Product
+		modules []bool
+	}
+	var labels []label
+	var missing []string
+	for _, item := range req.Items {
+		var product database.Product
+		if err := h.db.Where("id = ? AND tenant_id = ?", item.ProductID, tenantID).First(&product).Error; err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Product %s not found", item.ProductID)})
+			return
+		}
+		if product.Barcode == "" {
+			missing = append(missing, product.Name)
+			continue
+		}
+		format, err := barcode.Detect(product.Barcode)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("%s: %v", product.Name, err)})
+			return
+		}
+		modules, _ := barcode.Modules(product.Barcode, format)
+
+		copies := item.Copies
+		if copies <= 0 {
+			copies = 1
+		}
+		if len(labels)+copies > MaxLabelsPerPrint {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("At most %d labels can be printed at once", MaxLabelsPerPrint)})
+			return
+		}
+		for i := 0; i < copies; i++ {
+			labels = append(labels, label{product, modules})
+		}
+	}
+	if len(missing) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Products without a barcode: " + strings.Join(missing, ", ")})
+		return
+	}
+
+	doc := pdf.New(pdf.A4Width, pdf.A4Height)
+	cellW := (pdf.A4Width - 2*labelSheetMargin) / float64(req.Columns)
+	cellH := (pdf.A4Height - 2*labelSheetMargin) / float64(req.Rows)
+	perPage := req.Columns * req.Rows
+
+	var page *pdf.Page
+	for i, l := range labels {
+		if i%perPage == 0 {
+			page = doc.AddPage()
+		}
+		col := i % req.Columns
+		row := (i % perPage) / req.Columns
+		x := labelSheetMargin + float64(col)*cellW
+		y := pdf.A4Height - labelSheetMargin - float64(row+1)*cellH
+		drawLabel(page, x, y, cellW, cellH, l.product, l.modules)
+	}
+
+	c.Header("Content-Type", "application/pdf")
+	c.Header("Content-Disposition", "attachment; filename=labels.pdf")
+	doc.WriteTo(c.Writer)
+}
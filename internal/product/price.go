@@ -0,0 +1,52 @@
+package product
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/yuditriaji/warungin-backend/pkg/database"
+	"github.com/yuditriaji/warungin-backend/pkg/pricing"
+)
+
+// tenantLocation returns the tenant's time zone
+func (h *Handler) tenantLocation(tenantID string) *time.Location {
+	var tenant database.Tenant
+	h.db.Select("settings").Where("id = ?", tenantID).First(&tenant)
+	var settings database.TenantSettings
+	if tenant.Settings != "" && tenant.Settings != "{}" {
+		json.Unmarshal([]byte(tenant.Settings), &settings)
+	}
+	return settings.Location()
+}
+
+// priceContext reads the outlet, customer group and time to price products for
+func (h *Handler) priceContext(c *gin.Context, tenantID string) (pricing.Context, error) {
+	loc := h.tenantLocation(tenantID)
+	ctx := pricing.Context{
+		CustomerGroup: c.Query("customer_group"),
+		At:            time.Now().In(loc),
+	}
+	if outletID, err := uuid.Parse(c.Query("outlet_id")); err == nil {
+		ctx.OutletID = &outletID
+	}
+	if at := c.Query("at"); at != "" {
+		parsed, err := time.Parse(time.RFC3339, at)
+		if err != nil {
+			return ctx, fmt.Errorf("at must be an RFC 3339 time, e.g. 2024-01-31T08:00:00+07:00")
+		}
+		ctx.At = parsed.In(loc)
+	}
+	return ctx, nil
+}
+
+// applyPrice fills a product's effective price
+func applyPrice(prices *pricing.Prices, product *database.Product) {
+	price, list := prices.Resolve(*product)
+	product.EffectivePrice = &price
+	if list != nil {
+		product.PriceList = list.Name
+	}
+}
@@ -0,0 +1,167 @@
+package product
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/yuditriaji/warungin-backend/pkg/availability"
+	"github.com/yuditriaji/warungin-backend/pkg/barcode"
+	"github.com/yuditriaji/warungin-backend/pkg/database"
+	"github.com/yuditriaji/warungin-backend/pkg/search"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Columns ?sort accepts
+var productSorts = map[string]string{
+	"name":       "products.name",
+	"price":      "products.price",
+	"stock_qty":  "products.stock_qty",
+	"created_at": "products.created_at",
+	"updated_at": "products.updated_at",
+}
+
+// orVariant matches a parent product on its own row when it has no
+// variants, or when any of its variants matches. cond uses %[1]s for the
+// table alias.
+func orVariant(cond string) string {
+	return fmt.Sprintf("((products.has_variants = false AND %s) OR (products.has_variants = true AND EXISTS "+
+		"(SELECT 1 FROM products v WHERE v.parent_id = products.id AND v.deleted_at IS NULL AND %s)))",
+		fmt.Sprintf(cond, "products"), fmt.Sprintf(cond, "v"))
+}
+
+// filterProducts applies ?is_active, ?stock_status, ?min_price and
+// ?max_price. Stock status compares stored stock with the minimum stock
+// level, so bundles and material-driven products never match it.
+func filterProducts(query *gorm.DB, c *gin.Context) (*gorm.DB, error) {
+	if active := c.Query("is_active"); active != "" {
+		value, err := strconv.ParseBool(active)
+		if err != nil {
+			return nil, fmt.Errorf("is_active must be true or false")
+		}
+		query = query.Where("products.is_active = ?", value)
+	}
+
+	if status := c.Query("stock_status"); status != "" {
+		var cond string
+		switch status {
+		case "out":
+			cond = "%[1]s.stock_qty <= 0"
+		case "low":
+			cond = "%[1]s.stock_qty > 0 AND %[1]s.stock_qty < %[1]s.min_stock_level"
+		case "ok":
+			cond = "%[1]s.stock_qty > 0 AND %[1]s.stock_qty >= %[1]s.min_stock_level"
+		default:
+			return nil, fmt.Errorf("stock_status must be ok, low or out")
+		}
+		query = query.Where("products.is_bundle = false").
+			Where(orVariant("%[1]s.use_material_stock = false AND " + cond))
+	}
+
+	var conds []string
+	var args []interface{}
+	for _, bound := range []struct{ param, op string }{{"min_price", ">="}, {"max_price", "<="}} {
+		value := c.Query(bound.param)
+		if value == "" {
+			continue
+		}
+		price, err := strconv.ParseFloat(value, 64)
+		if err != nil || price < 0 {
+			return nil, fmt.Errorf("%s must be a non-negative number", bound.param)
+		}
+		conds = append(conds, "%[1]s.price "+bound.op+" ?")
+		args = append(args, price)
+	}
+	if len(conds) > 0 {
+		// The same bounds apply to the parent row and to a variant
+		query = query.Where(orVariant(strings.Join(conds, " AND ")), append(args, args...)...)
+	}
+	return query, nil
+}
+
+// searchProducts narrows a query to products whose name or SKU contains
+// term, whose barcode starts with it, or that have such a variant. With
+// pg_trgm installed, names with a typo match too; the query must then run in
+// a transaction set up with search.UseThreshold.
+func (h *Handler) searchProducts(query *gorm.DB, term string) *gorm.DB {
+	cond := "(%[1]s.name ILIKE @contains OR %[1]s.sku ILIKE @contains" +
+		" OR EXISTS (SELECT 1 FROM product_barcodes pb WHERE pb.product_id = %[1]s.id AND pb.code LIKE @prefix)"
+	if search.Trigram(h.db) {
+		// Indexed, unlike comparing word_similarity() with the threshold
+		cond += " OR @term <%% %[1]s.name"
+	}
+	cond += ")"
+
+	args := map[string]interface{}{
+		"contains": search.Contains(term),
+		"prefix":   search.Prefix(barcode.Normalize(term)),
+		"term":     term,
+	}
+	self := fmt.Sprintf(cond, "products")
+	variant := fmt.Sprintf(cond, "v")
+	return query.Where("("+self+" OR EXISTS (SELECT 1 FROM products v WHERE v.parent_id = products.id AND v.deleted_at IS NULL AND "+variant+"))", args)
+}
+
+// orderProducts applies ?sort and ?order. Searches without ?sort put the
+// closest names first.
+func (h *Handler) orderProducts(query *gorm.DB, c *gin.Context, term string) (*gorm.DB, error) {
+	desc := false
+	switch c.Query("order") {
+	case "", "asc":
+	case "desc":
+		desc = true
+	default:
+		return nil, fmt.Errorf("order must be asc or desc")
+	}
+
+	sort := c.Query("sort")
+	if sort == "" {
+		if term != "" && search.Trigram(h.db) {
+			return query.Clauses(clause.OrderBy{Expression: clause.Expr{
+				SQL:  "word_similarity(?, products.name) DESC, products.name ASC, products.id ASC",
+				Vars: []interface{}{term},
+			}}), nil
+		}
+		sort = "name"
+	}
+	column, ok := productSorts[sort]
+	if !ok {
+		return nil, fmt.Errorf("sort must be one of name, price, stock_qty, created_at or updated_at")
+	}
+	if desc {
+		column += " DESC"
+	}
+	// The ID breaks ties so pages don't overlap
+	return query.Order(column).Order("products.id ASC"), nil
+}
+
+// availabilityChecker checks products at an outlet, or anywhere when
+// outletID is empty, at the current local time
+func (h *Handler) availabilityChecker(tenantID, outletID string) *availability.Checker {
+	var outlet *uuid.UUID
+	if id, err := uuid.Parse(outletID); err == nil {
+		outlet = &id
+	}
+	return availability.Load(h.db, tenantID, outlet, time.Now().In(h.tenantLocation(tenantID)))
+}
+
+// availableOnly narrows a query to products that can be sold now, before
+// paging so every page is full. Sale hours are checked in Go, on a light
+// copy of the candidate rows.
+func (h *Handler) availableOnly(query *gorm.DB, checker *availability.Checker) *gorm.DB {
+	columns := "id, parent_id, name, is_active, has_variants, available_days, available_from, available_until"
+	var candidates []database.Product
+	query.Session(&gorm.Session{}).Select(columns).
+		Preload("Variants", func(db *gorm.DB) *gorm.DB { return db.Select(columns) }).
+		Find(&candidates)
+
+	ids := []uuid.UUID{}
+	for _, p := range filterAvailable(candidates, checker) {
+		ids = append(ids, p.ID)
+	}
+	return query.Where("products.id IN ?", ids)
+}
@@ -0,0 +1,546 @@
+package product
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/yuditriaji/warungin-backend/pkg/costing"
+	"github.com/yuditriaji/warungin-backend/pkg/database"
+	"gorm.io/gorm"
+)
+
+// Variants are stored as Product rows with ParentID set, so stock, costing and
+// recipes work on them unchanged. They do not count against the plan's
+// MaxProducts: a product with variants counts once, and MaxVariantsPerProduct
+// caps how many variants one product can have.
+const (
+	MaxVariantAxes        = 3
+	MaxVariantsPerProduct = 100
+)
+
+type VariantAxis struct {
+	Name   string   `json:"name" binding:"required"`
+	Values []string `json:"values" binding:"required,min=1"`
+}
+
+type SetVariantAxesRequest struct {
+	Axes []VariantAxis `json:"axes" binding:"required,dive"`
+}
+
+type VariantRequest struct {
+	Options  map[string]string `json:"options"` // Axis name -> value, required on create
+	SKU      string            `json:"sku"`
+	Barcode  string            `json:"barcode"`
+	Price    *float64          `json:"price"` // Defaults to the parent's price
+	Cost     *float64          `json:"cost"`  // Defaults to the parent's cost
+	StockQty int               `json:"stock_qty"`
+	IsActive *bool             `json:"is_active"`
+}
+
+// parseAxes reads a parent's variant axes
+func parseAxes(product database.Product) []VariantAxis {
+	var axes []VariantAxis
+	if product.VariantAxes != "" {
+		json.Unmarshal([]byte(product.VariantAxes), &axes)
+	}
+	return axes
+}
+
+// variantLabel validates options against the axes and returns the label
+// ("M / Red", values in axis order) and the canonical options JSON
+func variantLabel(axes []VariantAxis, options map[string]string) (string, string, error) {
+	if len(axes) == 0 {
+		return "", "", fmt.Errorf("Set the product's variant options first")
+	}
+	if len(options) != len(axes) {
+		return "", "", fmt.Errorf("A value is required for each option")
+	}
+
+	parts := make([]string, 0, len(axes))
+	canonical := make(map[string]string, len(axes))
+	for _, axis := range axes {
+		value, ok := options[axis.Name]
+		if !ok {
+			return "", "", fmt.Errorf("Missing value for %s", axis.Name)
+		}
+		valid := false
+		for _, v := range axis.Values {
+			if v == value {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return "", "", fmt.Errorf("%s is not a valid %s", value, axis.Name)
+		}
+		parts = append(parts, value)
+		canonical[axis.Name] = value
+	}
+
+	optionsJSON, _ := json.Marshal(canonical)
+	return strings.Join(parts, " / "), string(optionsJSON), nil
+}
+
+// loadParent loads a product that can have variants
+func (h *Handler) loadParent(c *gin.Context) (database.Product, bool) {
+	tenantID := c.GetString("tenant_id")
+
+	var parent database.Product
+	if err := h.db.Where("id = ? AND tenant_id = ?", c.Param("id"), tenantID).First(&parent).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+		return parent, false
+	}
+	if parent.ParentID != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A variant cannot have its own variants"})
+		return parent, false
+	}
+	if parent.IsBundle {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Bundles cannot have variants"})
+		return parent, false
+	}
+	return parent, true
+}
+
+// newVariant builds a variant row inheriting the parent's settings
+func newVariant(parent database.Product, label, optionsJSON string) database.Product {
+	return database.Product{
+		TenantID:         parent.TenantID,
+		OutletID:         parent.OutletID,
+		CategoryID:       parent.CategoryID,
+		Name:             parent.Name + " - " + label,
+		Price:            parent.Price,
+		Cost:             parent.Cost,
+		TaxRate:          parent.TaxRate,
+		MinStockLevel:    parent.MinStockLevel,
+		ReorderQty:       parent.ReorderQty,
+		AutoReorderPoint: parent.AutoReorderPoint,
+		LeadTimeDays:     parent.LeadTimeDays,
+		SalesWindowDays:  parent.SalesWindowDays,
+		UseMaterialStock: parent.UseMaterialStock,
+		ImageURL:         parent.ImageURL,
+		ThumbnailURL:     parent.ThumbnailURL,
+		IsActive:         true,
+		ParentID:         &parent.ID,
+		VariantOptions:   optionsJSON,
+		VariantName:      label,
+	}
+}
+
+// syncVariants copies the parent's shared settings onto its variants
+func syncVariants(db *gorm.DB, parent database.Product) error {
+	return db.Model(&database.Product{}).
+		Where("parent_id = ?", parent.ID).
+		Updates(map[string]interface{}{
+			"name":        gorm.Expr("? || ' - ' || variant_name", parent.Name),
+			"category_id": parent.CategoryID,
+			"outlet_id":   parent.OutletID,
+			"tax_rate":    parent.TaxRate,
+		}).Error
+}
+
+// SetVariantAxes defines the option axes (e.g. Size, Color) variants are made from
+func (h *Handler) SetVariantAxes(c *gin.Context) {
+	parent, ok := h.loadParent(c)
+	if !ok {
+		return
+	}
+
+	var req SetVariantAxesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(req.Axes) > MaxVariantAxes {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("A product can have at most %d variant options", MaxVariantAxes)})
+		return
+	}
+
+	names := make(map[string]bool)
+	for i, axis := range req.Axes {
+		axis.Name = strings.TrimSpace(axis.Name)
+		if axis.Name == "" || names[strings.ToLower(axis.Name)] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Option names must be unique and not empty"})
+			return
+		}
+		names[strings.ToLower(axis.Name)] = true
+
+		seen := make(map[string]bool)
+		for j, value := range axis.Values {
+			value = strings.TrimSpace(value)
+			if value == "" || seen[strings.ToLower(value)] {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Values of %s must be unique and not empty", axis.Name)})
+				return
+			}
+			seen[strings.ToLower(value)] = true
+			axis.Values[j] = value
+		}
+		req.Axes[i] = axis
+	}
+
+	// Existing variants must still fit the new axes
+	var variants []database.Product
+	h.db.Where("parent_id = ?", parent.ID).Find(&variants)
+	for _, v := range variants {
+		var options map[string]string
+		json.Unmarshal([]byte(v.VariantOptions), &options)
+		if _, _, err := variantLabel(req.Axes, options); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": fmt.Sprintf("Variant %s does not fit the new options; delete it first", v.VariantName),
+			})
+			return
+		}
+	}
+
+	axesJSON, _ := json.Marshal(req.Axes)
+	if err := h.db.Model(&parent).Update("variant_axes", string(axesJSON)).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save variant options"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": req.Axes})
+}
+
+// ListVariants returns a product's variants
+func (h *Handler) ListVariants(c *gin.Context) {
+	parent, ok := h.loadParent(c)
+	if !ok {
+		return
+	}
+
+	var variants []database.Product
+	h.db.Where("parent_id = ?", parent.ID).Order("created_at ASC").Find(&variants)
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": variants,
+		"axes": parseAxes(parent),
+	})
+}
+
+// checkParentStock refuses to turn a stocked product into a parent, since a
+// parent's own stock can no longer be sold
+func checkParentStock(parent database.Product) error {
+	if !parent.HasVariants && !parent.UseMaterialStock && parent.StockQty > 0 {
+		return fmt.Errorf("Product still has %d in stock. Adjust its stock to 0 before adding variants", parent.StockQty)
+	}
+	return nil
+}
+
+// createVariant inserts a variant and books its opening stock
+func createVariant(tx *gorm.DB, parent database.Product, variant *database.Product, stockQty int, method string) error {
+	if err := createProduct(tx, variant); err != nil {
+		return err
+	}
+	if stockQty > 0 {
+		ledger := costing.NewLedger(tx, parent.TenantID, method)
+		if err := ledger.Receive(costing.ItemProduct, variant.ID, float64(stockQty), variant.Cost, nil); err != nil {
+			return err
+		}
+	}
+	if !parent.HasVariants {
+		return tx.Model(&database.Product{}).Where("id = ?", parent.ID).Update("has_variants", true).Error
+	}
+	return nil
+}
+
+// CreateVariant adds one variant to a product
+func (h *Handler) CreateVariant(c *gin.Context) {
+	tenantID := c.GetString("tenant_id")
+
+	parent, ok := h.loadParent(c)
+	if !ok {
+		return
+	}
+
+	var req VariantRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.StockQty < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Stock cannot go below zero"})
+		return
+	}
+	if err := checkParentStock(parent); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	label, optionsJSON, err := variantLabel(parseAxes(parent), req.Options)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var count, dup int64
+	h.db.Model(&database.Product{}).Where("parent_id = ?", parent.ID).Count(&count)
+	if count >= MaxVariantsPerProduct {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("A product can have at most %d variants", MaxVariantsPerProduct)})
+		return
+	}
+	h.db.Model(&database.Product{}).Where("parent_id = ? AND variant_name = ?", parent.ID, label).Count(&dup)
+	if dup > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Variant %s already exists", label)})
+		return
+	}
+
+	var code, format string
+	if req.Barcode != "" {
+		if code, format, err = checkBarcode(h.db, tenantID, uuid.Nil, req.Barcode); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	variant := newVariant(parent, label, optionsJSON)
+	variant.SKU = req.SKU
+	if req.Price != nil {
+		variant.Price = *req.Price
+	}
+	if req.Cost != nil {
+		variant.Cost = *req.Cost
+	}
+	if req.IsActive != nil {
+		variant.IsActive = *req.IsActive
+	}
+
+	tx := h.db.Begin()
+	if err := createVariant(tx, parent, &variant, req.StockQty, costing.LoadMethod(h.db, tenantID)); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create variant"})
+		return
+	}
+	if code != "" {
+		if _, err := addBarcode(tx, &variant, code, format, true, false); err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create variant"})
+			return
+		}
+	}
+	tx.Commit()
+
+	h.db.Where("id = ?", variant.ID).First(&variant)
+
+	h.logger.LogCreate(c, "product_variant", variant.ID, map[string]interface{}{
+		"product": parent.Name,
+		"variant": label,
+		"price":   variant.Price,
+	})
+
+	c.JSON(http.StatusCreated, gin.H{"data": variant})
+}
+
+// GenerateVariants creates every missing combination of the product's
+// options, priced like the parent with no stock
+func (h *Handler) GenerateVariants(c *gin.Context) {
+	tenantID := c.GetString("tenant_id")
+
+	parent, ok := h.loadParent(c)
+	if !ok {
+		return
+	}
+	if err := checkParentStock(parent); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	axes := parseAxes(parent)
+	if len(axes) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Set the product's variant options first"})
+		return
+	}
+
+	// Cartesian product of all axis values
+	combos := []map[string]string{{}}
+	for _, axis := range axes {
+		next := make([]map[string]string, 0, len(combos)*len(axis.Values))
+		for _, combo := range combos {
+			for _, value := range axis.Values {
+				options := make(map[string]string, len(combo)+1)
+				for k, v := range combo {
+					options[k] = v
+				}
+				options[axis.Name] = value
+				next = append(next, options)
+			}
+		}
+		combos = next
+	}
+
+	var existing []string
+	h.db.Model(&database.Product{}).Where("parent_id = ?", parent.ID).Pluck("variant_name", &existing)
+	exists := make(map[string]bool, len(existing))
+	for _, name := range existing {
+		exists[name] = true
+	}
+
+	var toCreate []database.Product
+	for _, options := range combos {
+		label, optionsJSON, _ := variantLabel(axes, options)
+		if !exists[label] {
+			toCreate = append(toCreate, newVariant(parent, label, optionsJSON))
+		}
+	}
+	if len(existing)+len(toCreate) > MaxVariantsPerProduct {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("A product can have at most %d variants", MaxVariantsPerProduct)})
+		return
+	}
+
+	method := costing.LoadMethod(h.db, tenantID)
+	tx := h.db.Begin()
+	for i := range toCreate {
+		if err := createVariant(tx, parent, &toCreate[i], 0, method); err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create variants"})
+			return
+		}
+		parent.HasVariants = true
+	}
+	tx.Commit()
+
+	c.JSON(http.StatusCreated, gin.H{
+		"data":    toCreate,
+		"message": fmt.Sprintf("%d variants created", len(toCreate)),
+	})
+}
+
+// UpdateVariant modifies a variant's SKU, barcode, price, cost or status.
+// Stock is changed through the inventory endpoints, and cost only with manual
+// costing.
+func (h *Handler) UpdateVariant(c *gin.Context) {
+	parent, ok := h.loadParent(c)
+	if !ok {
+		return
+	}
+
+	var variant database.Product
+	if err := h.db.Where("id = ? AND parent_id = ?", c.Param("variant_id"), parent.ID).First(&variant).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Variant not found"})
+		return
+	}
+
+	var req VariantRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.Options != nil {
+		label, optionsJSON, err := variantLabel(parseAxes(parent), req.Options)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		var dup int64
+		h.db.Model(&database.Product{}).
+			Where("parent_id = ? AND variant_name = ? AND id <> ?", parent.ID, label, variant.ID).
+			Count(&dup)
+		if dup > 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Variant %s already exists", label)})
+			return
+		}
+		variant.VariantName = label
+		variant.VariantOptions = optionsJSON
+		variant.Name = parent.Name + " - " + label
+	}
+
+	oldValues := map[string]interface{}{
+		"sku":   variant.SKU,
+		"price": variant.Price,
+		"cost":  variant.Cost,
+	}
+
+	// An empty barcode keeps the current ones; extra barcodes are managed
+	// through the product barcode endpoints
+	var code, format string
+	if req.Barcode != "" && req.Barcode != variant.Barcode {
+		var err error
+		if code, format, err = checkBarcode(h.db, c.GetString("tenant_id"), variant.ID, req.Barcode); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	// Stock and, with average or fifo costing, cost are kept by the ledger
+	omit := []string{"stock_qty"}
+	if method := costing.LoadMethod(h.db, c.GetString("tenant_id")); method != costing.MethodManual {
+		if req.Cost != nil && *req.Cost != variant.Cost {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Cost is calculated from stock receipts with %s costing", method)})
+			return
+		}
+		omit = append(omit, "cost")
+	} else if req.Cost != nil {
+		variant.Cost = *req.Cost
+	}
+
+	variant.SKU = req.SKU
+	if req.Price != nil {
+		variant.Price = *req.Price
+	}
+	if req.IsActive != nil {
+		variant.IsActive = *req.IsActive
+	}
+
+	if err := h.db.Omit(omit...).Save(&variant).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update variant"})
+		return
+	}
+	if code != "" {
+		if err := replacePrimary(h.db, &variant, code, format); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update barcode"})
+			return
+		}
+	}
+
+	h.logger.LogUpdate(c, "product_variant", variant.ID, oldValues, map[string]interface{}{
+		"sku":   variant.SKU,
+		"price": variant.Price,
+		"cost":  variant.Cost,
+	})
+
+	c.JSON(http.StatusOK, gin.H{"data": variant})
+}
+
+// DeleteVariant soft-deletes a variant. When the last variant is removed the
+// product is sold directly again.
+func (h *Handler) DeleteVariant(c *gin.Context) {
+	parent, ok := h.loadParent(c)
+	if !ok {
+		return
+	}
+
+	var variant database.Product
+	if err := h.db.Where("id = ? AND parent_id = ?", c.Param("variant_id"), parent.ID).First(&variant).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Variant not found"})
+		return
+	}
+	if names := bundlesUsing(h.db, variant); len(names) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Variant is part of bundles: " + strings.Join(names, ", ")})
+		return
+	}
+
+	tx := h.db.Begin()
+	if err := tx.Delete(&variant).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete variant"})
+		return
+	}
+	tx.Where("product_id = ?", variant.ID).Delete(&database.ProductBarcode{})
+	tx.Where("product_id = ?", variant.ID).Delete(&database.ProductSoldOut{})
+	var remaining int64
+	tx.Model(&database.Product{}).Where("parent_id = ?", parent.ID).Count(&remaining)
+	if remaining == 0 {
+		tx.Model(&database.Product{}).Where("id = ?", parent.ID).Update("has_variants", false)
+	}
+	tx.Commit()
+
+	h.logger.LogDelete(c, "product_variant", variant.ID, map[string]interface{}{
+		"product": parent.Name,
+		"variant": variant.VariantName,
+	})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Variant deleted"})
+}
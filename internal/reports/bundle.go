@@ -0,0 +1,75 @@
+package reports
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yuditriaji/warungin-backend/pkg/database"
+)
+
+type BundleComponentReport struct {
+	ProductID   string  `json:"product_id"`
+	ProductName string  `json:"product_name"`
+	BundleCount int     `json:"bundle_count"` // Distinct bundles the product was sold in
+	TotalQty    int     `json:"total_qty"`
+	TotalSales  float64 `json:"total_sales"` // Share of bundle revenue, split by component prices
+	TotalCost   float64 `json:"total_cost"`
+	Profit      float64 `json:"profit"`
+}
+
+// GetBundleComponentReport returns products sold as part of bundles. Variants
+// roll up to their parent product, as in the product sales report.
+func (h *Handler) GetBundleComponentReport(c *gin.Context) {
+	tenantID := c.GetString("tenant_id")
+
+	var req SalesReportRequest
+	c.ShouldBindQuery(&req)
+	startDate, endDate := dateRange(req)
+
+	var rows []struct {
+		ProductID   string
+		ProductName string
+		BundleCount int
+		TotalQty    int
+		TotalSales  float64
+		TotalCost   float64
+	}
+	query := h.db.Model(&database.TransactionItemComponent{}).
+		Joins("JOIN transaction_items ON transaction_item_components.transaction_item_id = transaction_items.id").
+		Joins("JOIN transactions ON transaction_items.transaction_id = transactions.id").
+		Joins("JOIN products ON transaction_item_components.product_id = products.id").
+		Where("transactions.tenant_id = ? AND transactions.created_at >= ? AND transactions.created_at <= ? AND transactions.status = ?",
+			tenantID, startDate, endDate, "completed")
+	if req.OutletID != "" {
+		query = query.Where("transactions.outlet_id = ?", req.OutletID)
+	}
+	query.Select(`
+			transaction_item_components.product_id,
+			products.name as product_name,
+			COUNT(DISTINCT transaction_items.product_id) as bundle_count,
+			SUM(transaction_item_components.quantity) as total_qty,
+			SUM(transaction_item_components.revenue) as total_sales,
+			COALESCE(SUM(transaction_item_components.unit_cost * transaction_item_components.quantity), 0) as total_cost`).
+		Group("transaction_item_components.product_id, products.name").
+		Order("total_qty DESC").
+		Scan(&rows)
+
+	report := make([]BundleComponentReport, 0, len(rows))
+	for _, row := range rows {
+		report = append(report, BundleComponentReport{
+			ProductID:   row.ProductID,
+			ProductName: row.ProductName,
+			BundleCount: row.BundleCount,
+			TotalQty:    row.TotalQty,
+			TotalSales:  row.TotalSales,
+			TotalCost:   row.TotalCost,
+			Profit:      row.TotalSales - row.TotalCost,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":       report,
+		"start_date": startDate.Format("2006-01-02"),
+		"end_date":   endDate.Format("2006-01-02"),
+	})
+}
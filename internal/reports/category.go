@@ -0,0 +1,148 @@
+package reports
+
+import (
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/yuditriaji/warungin-backend/pkg/categories"
+	"github.com/yuditriaji/warungin-backend/pkg/database"
+	"github.com/yuditriaji/warungin-backend/pkg/recipe"
+)
+
+// dateRange returns the report period, defaulting to the current month
+func dateRange(req SalesReportRequest) (time.Time, time.Time) {
+	now := time.Now()
+	startDate := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	endDate := time.Date(now.Year(), now.Month()+1, 0, 23, 59, 59, 0, now.Location())
+
+	if req.StartDate != "" {
+		if parsed, err := time.Parse("2006-01-02", req.StartDate); err == nil {
+			startDate = parsed
+		}
+	}
+	if req.EndDate != "" {
+		if parsed, err := time.Parse("2006-01-02", req.EndDate); err == nil {
+			endDate = time.Date(parsed.Year(), parsed.Month(), parsed.Day(), 23, 59, 59, 0, parsed.Location())
+		}
+	}
+	return startDate, endDate
+}
+
+// ProductItem is one product's sold items in a period
+type ProductItem struct {
+	ProductID        uuid.UUID
+	ProductName      string
+	CategoryID       *uuid.UUID
+	CategoryName     string
+	TotalQty         int
+	TotalSales       float64
+	CapturedCost     float64
+	UncapturedQty    int
+	Cost             float64
+	UseMaterialStock bool
+}
+
+// productItems returns sales grouped by product, best sellers first
+func (h *Handler) productItems(tenantID string, startDate, endDate time.Time, req SalesReportRequest) []ProductItem {
+	var items []ProductItem
+	h.itemsQuery(tenantID, startDate, endDate, req).
+		Select(`
+			transaction_items.product_id,
+			products.name as product_name,
+			products.category_id,
+			COALESCE(categories.name, '') as category_name,
+			SUM(transaction_items.quantity) as total_qty,
+			SUM(transaction_items.subtotal) as total_sales,`+costSelect).
+		Joins("LEFT JOIN categories ON categories.id = products.category_id").
+		Group("transaction_items.product_id, products.name, products.category_id, categories.name, products.cost, products.use_material_stock").
+		Order("total_sales DESC").
+		Scan(&items)
+	return items
+}
+
+type CategorySalesReport struct {
+	CategoryID   *string `json:"category_id"` // nil for uncategorized products
+	CategoryName string  `json:"category_name"`
+	ParentID     *string `json:"parent_id"`
+	ProductCount int     `json:"product_count"` // Distinct products sold
+	TotalQty     int     `json:"total_qty"`
+	TotalSales   float64 `json:"total_sales"`
+	TotalCost    float64 `json:"total_cost"`
+	Profit       float64 `json:"profit"`
+	SalesShare   float64 `json:"sales_share"` // Percentage of total sales in the report
+}
+
+// GetCategorySalesReport returns sales grouped by product category.
+// With ?level=top subcategory sales roll up into their top-level category.
+func (h *Handler) GetCategorySalesReport(c *gin.Context) {
+	tenantID := c.GetString("tenant_id")
+
+	var req SalesReportRequest
+	c.ShouldBindQuery(&req)
+	startDate, endDate := dateRange(req)
+	rollUp := c.Query("level") == "top"
+
+	var cats []database.Category
+	h.db.Where("tenant_id = ?", tenantID).Find(&cats)
+	catByID := make(map[uuid.UUID]database.Category, len(cats))
+	for _, cat := range cats {
+		catByID[cat.ID] = cat
+	}
+	tree := categories.Load(h.db, tenantID)
+
+	calc := recipe.NewCalculator(h.db)
+	groups := make(map[uuid.UUID]*CategorySalesReport) // uuid.Nil = uncategorized
+	var grandTotal float64
+	for _, item := range h.productItems(tenantID, startDate, endDate, req) {
+		key := uuid.Nil
+		if item.CategoryID != nil {
+			key = *item.CategoryID
+			if rollUp {
+				key = tree.Root(key)
+			}
+		}
+
+		group, ok := groups[key]
+		if !ok {
+			group = &CategorySalesReport{CategoryName: "Tanpa Kategori"}
+			if cat, found := catByID[key]; found {
+				id := cat.ID.String()
+				group.CategoryID = &id
+				group.CategoryName = cat.Name
+				if cat.ParentID != nil && !rollUp {
+					parentID := cat.ParentID.String()
+					group.ParentID = &parentID
+				}
+			}
+			groups[key] = group
+		}
+
+		totalCost := itemCost(calc, item.ProductID, item.CapturedCost, item.UncapturedQty, item.Cost, item.UseMaterialStock)
+		group.ProductCount++
+		group.TotalQty += item.TotalQty
+		group.TotalSales += item.TotalSales
+		group.TotalCost += totalCost
+		group.Profit += item.TotalSales - totalCost
+		grandTotal += item.TotalSales
+	}
+
+	report := make([]CategorySalesReport, 0, len(groups))
+	for _, group := range groups {
+		if grandTotal > 0 {
+			group.SalesShare = group.TotalSales / grandTotal * 100
+		}
+		report = append(report, *group)
+	}
+	sort.Slice(report, func(i, j int) bool {
+		return report[i].TotalSales > report[j].TotalSales
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":       report,
+		"start_date": startDate.Format("2006-01-02"),
+		"end_date":   endDate.Format("2006-01-02"),
+	})
+}
@@ -0,0 +1,108 @@
+package reports
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/yuditriaji/warungin-backend/pkg/database"
+	"github.com/yuditriaji/warungin-backend/pkg/export"
+	"github.com/yuditriaji/warungin-backend/pkg/pagination"
+	"gorm.io/gorm"
+)
+
+// CustomerReport is one customer's completed purchases in a period
+type CustomerReport struct {
+	CustomerID    uuid.UUID `json:"customer_id"`
+	Name          string    `json:"name"`
+	Phone         string    `json:"phone"`
+	Group         string    `json:"group"`
+	Transactions  int       `json:"transactions"`
+	TotalSpent    float64   `json:"total_spent"`
+	AveragePerTx  float64   `json:"average_per_tx"`
+	FirstPurchase time.Time `json:"first_purchase"`
+	LastPurchase  time.Time `json:"last_purchase"`
+}
+
+// customerSales groups the period's completed sales by customer, biggest
+// spenders first
+func (h *Handler) customerSales(tenantID string, startDate, endDate time.Time, req SalesReportRequest) *gorm.DB {
+	query := h.db.Model(&database.Transaction{}).
+		Select(`customers.id AS customer_id, customers.name, customers.phone, customers."group",
+			COUNT(*) AS transactions, SUM(transactions.total) AS total_spent,
+			SUM(transactions.total) / COUNT(*) AS average_per_tx,
+			MIN(transactions.created_at) AS first_purchase, MAX(transactions.created_at) AS last_purchase`).
+		Joins("JOIN customers ON customers.id = transactions.customer_id").
+		Where("transactions.tenant_id = ? AND transactions.created_at >= ? AND transactions.created_at <= ? AND transactions.status = ?",
+			tenantID, startDate, endDate, "completed")
+	if req.OutletID != "" {
+		query = query.Where("transactions.outlet_id = ?", req.OutletID)
+	}
+	return query.Group(`customers.id, customers.name, customers.phone, customers."group"`).
+		Order("total_spent DESC, customers.name ASC")
+}
+
+// GetCustomerReport returns each customer's purchases in the period, a page
+// at a time, or every customer with ?format
+func (h *Handler) GetCustomerReport(c *gin.Context) {
+	tenantID := c.GetString("tenant_id")
+
+	var req SalesReportRequest
+	c.ShouldBindQuery(&req)
+	startDate, endDate := dateRange(req)
+	format, ok := export.Requested(c)
+	if !ok {
+		return
+	}
+
+	if format != "" {
+		rows, err := h.customerSales(tenantID, startDate, endDate, req).Rows()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch customer report"})
+			return
+		}
+		defer rows.Close()
+
+		period := startDate.Format("2006-01-02") + "_" + endDate.Format("2006-01-02")
+		w, err := export.Start(c, format, "pelanggan_"+period,
+			"Laporan Pelanggan "+startDate.Format("2006-01-02")+" s/d "+endDate.Format("2006-01-02"), []export.Column{
+				{Header: "Pelanggan", Width: 1.8}, {Header: "Telepon", Width: 1.2}, {Header: "Grup", Width: 0.8},
+				{Header: "Transaksi", Width: 0.7}, {Header: "Total Belanja"}, {Header: "Rata-rata"},
+				{Header: "Pertama", Width: 1.2}, {Header: "Terakhir", Width: 1.2},
+			})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate file"})
+			return
+		}
+		for rows.Next() {
+			var r CustomerReport
+			if err := h.db.ScanRows(rows, &r); err != nil {
+				break
+			}
+			if err := w.Row(r.Name, r.Phone, r.Group, r.Transactions, r.TotalSpent, r.AveragePerTx,
+				r.FirstPurchase, r.LastPurchase); err != nil {
+				break
+			}
+		}
+		w.Close()
+		return
+	}
+
+	var total int64
+	h.db.Table("(?) AS customer_sales", h.customerSales(tenantID, startDate, endDate, req)).Count(&total)
+
+	page, _ := pagination.FromQuery(c)
+	customers := []CustomerReport{}
+	if err := page.Apply(h.customerSales(tenantID, startDate, endDate, req)).Scan(&customers).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch customer report"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":       customers,
+		"start_date": startDate.Format("2006-01-02"),
+		"end_date":   endDate.Format("2006-01-02"),
+		"pagination": page.Meta(total),
+	})
+}
@@ -0,0 +1,289 @@
+package reports
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/yuditriaji/warungin-backend/pkg/database"
+	"github.com/yuditriaji/warungin-backend/pkg/email"
+	"github.com/yuditriaji/warungin-backend/pkg/export"
+	"github.com/yuditriaji/warungin-backend/pkg/middleware"
+	"github.com/yuditriaji/warungin-backend/pkg/stock"
+)
+
+// digestTopProducts is how many best sellers each outlet lists
+const digestTopProducts = 5
+
+// digestTitles name the report of each frequency
+var digestTitles = map[string]string{
+	Daily:   "Ringkasan Harian",
+	Weekly:  "Ringkasan Mingguan",
+	Monthly: "Ringkasan Bulanan",
+}
+
+// outletKey groups rows by outlet; sales without an outlet use uuid.Nil
+func outletKey(outletID *uuid.UUID) uuid.UUID {
+	if outletID == nil {
+		return uuid.Nil
+	}
+	return *outletID
+}
+
+// buildDigest summarises the tenant's sales from start to end, per outlet.
+// Cash variance comes from the shifts closed with a cash count in the period.
+func (h *Handler) buildDigest(sub database.ReportSubscription, start, end time.Time) email.ReportDigest {
+	tenantID := sub.TenantID.String()
+	digest := email.ReportDigest{Title: digestTitles[sub.Frequency]}
+
+	var tenant database.Tenant
+	h.db.Where("id = ?", sub.TenantID).First(&tenant)
+	digest.TenantName = tenant.Name
+
+	var outlets []database.Outlet
+	outletQuery := h.db.Where("tenant_id = ?", tenantID)
+	if sub.OutletID != nil {
+		outletQuery = outletQuery.Where("id = ?", *sub.OutletID)
+	} else {
+		outletQuery = outletQuery.Where("is_active = ?", true)
+	}
+	outletQuery.Order("name ASC").Find(&outlets)
+
+	byOutlet := make(map[uuid.UUID]*email.OutletDigest)
+	var order []uuid.UUID
+	add := func(id uuid.UUID, name string) *email.OutletDigest {
+		if d, ok := byOutlet[id]; ok {
+			return d
+		}
+		d := &email.OutletDigest{Name: name}
+		byOutlet[id] = d
+		order = append(order, id)
+		return d
+	}
+	for _, outlet := range outlets {
+		add(outlet.ID, outlet.Name)
+	}
+	// Sales recorded without an outlet, or at an outlet since deactivated
+	outletDigest := func(outletID *uuid.UUID) *email.OutletDigest {
+		key := outletKey(outletID)
+		if d, ok := byOutlet[key]; ok {
+			return d
+		}
+		name := tenant.Name
+		if outletID != nil {
+			var outlet database.Outlet
+			h.db.Unscoped().Where("id = ?", *outletID).First(&outlet)
+			name = outlet.Name
+		}
+		return add(key, name)
+	}
+
+	var sales []struct {
+		OutletID     *uuid.UUID
+		Sales        float64
+		Transactions int
+		CashSales    float64
+	}
+	salesQuery := h.db.Model(&database.Transaction{}).
+		Select(`outlet_id, COALESCE(SUM(total), 0) AS sales, COUNT(*) AS transactions,
+			COALESCE(SUM(CASE WHEN payment_method = 'cash' THEN total ELSE 0 END), 0) AS cash_sales`).
+		Where("tenant_id = ? AND created_at >= ? AND created_at < ? AND status = ?", tenantID, start, end, "completed")
+	if sub.OutletID != nil {
+		salesQuery = salesQuery.Where("outlet_id = ?", *sub.OutletID)
+	}
+	salesQuery.Group("outlet_id").Scan(&sales)
+	for _, row := range sales {
+		d := outletDigest(row.OutletID)
+		d.Sales, d.Transactions, d.CashSales = row.Sales, row.Transactions, row.CashSales
+		if row.Transactions > 0 {
+			d.AverageSale = row.Sales / float64(row.Transactions)
+		}
+	}
+
+	var counts []struct {
+		OutletID     *uuid.UUID
+		CashCounts   int
+		ExpectedCash float64
+		CountedCash  float64
+		CashVariance float64
+	}
+	countQuery := h.db.Model(&database.CashCount{}).
+		Select(`outlet_id, COUNT(*) AS cash_counts, COALESCE(SUM(expected_cash), 0) AS expected_cash,
+			COALESCE(SUM(counted_cash), 0) AS counted_cash, COALESCE(SUM(variance), 0) AS cash_variance`).
+		Where("tenant_id = ? AND closed_at >= ? AND closed_at < ?", tenantID, start, end)
+	if sub.OutletID != nil {
+		countQuery = countQuery.Where("outlet_id = ?", *sub.OutletID)
+	}
+	countQuery.Group("outlet_id").Scan(&counts)
+	for _, row := range counts {
+		d := outletDigest(row.OutletID)
+		d.CashCounts, d.ExpectedCash, d.CountedCash, d.CashVariance = row.CashCounts, row.ExpectedCash, row.CountedCash, row.CashVariance
+	}
+
+	// Voids are counted when they happen, whenever the sale was made
+	var voids []struct {
+		OutletID   *uuid.UUID
+		Voids      int
+		VoidAmount float64
+	}
+	voidQuery := h.db.Model(&database.Transaction{}).
+		Select("transactions.outlet_id, COUNT(*) AS voids, COALESCE(SUM(transactions.total), 0) AS void_amount").
+		Joins("JOIN transaction_audit_logs ON transaction_audit_logs.transaction_id = transactions.id AND transaction_audit_logs.action = 'void'").
+		Where("transactions.tenant_id = ? AND transaction_audit_logs.created_at >= ? AND transaction_audit_logs.created_at < ?",
+			tenantID, start, end)
+	if sub.OutletID != nil {
+		voidQuery = voidQuery.Where("transactions.outlet_id = ?", *sub.OutletID)
+	}
+	voidQuery.Group("transactions.outlet_id").Scan(&voids)
+	for _, row := range voids {
+		d := outletDigest(row.OutletID)
+		d.Voids, d.VoidAmount = row.Voids, row.VoidAmount
+	}
+
+	var top []struct {
+		OutletID *uuid.UUID
+		Name     string
+		Quantity int
+		Revenue  float64
+	}
+	topQuery := h.db.Model(&database.TransactionItem{}).
+		Select("transactions.outlet_id, products.name, SUM(transaction_items.quantity) AS quantity, SUM(transaction_items.subtotal) AS revenue").
+		Joins("JOIN transactions ON transaction_items.transaction_id = transactions.id").
+		Joins("JOIN products ON products.id = transaction_items.product_id").
+		Where("transactions.tenant_id = ? AND transactions.created_at >= ? AND transactions.created_at < ? AND transactions.status = ?",
+			tenantID, start, end, "completed")
+	if sub.OutletID != nil {
+		topQuery = topQuery.Where("transactions.outlet_id = ?", *sub.OutletID)
+	}
+	topQuery.Group("transactions.outlet_id, products.id, products.name").
+		Order("revenue DESC, products.name ASC").
+		Scan(&top)
+	for _, row := range top {
+		d := outletDigest(row.OutletID)
+		if len(d.TopProducts) < digestTopProducts {
+			d.TopProducts = append(d.TopProducts, email.TopProduct{Name: row.Name, Quantity: row.Quantity, Revenue: row.Revenue})
+		}
+	}
+
+	// Low stock as of now, for products that keep their own stock
+	var products []database.Product
+	productQuery := h.db.Where("tenant_id = ? AND is_active = ? AND has_variants = ? AND is_bundle = ? AND use_material_stock = ?",
+		tenantID, true, false, false, false)
+	if sub.OutletID != nil {
+		productQuery = productQuery.Where("outlet_id = ? OR outlet_id IS NULL", *sub.OutletID)
+	}
+	productQuery.Order("name ASC").Find(&products)
+	reorder := stock.ReorderPoints(h.db, tenantID, products)
+	for _, p := range products {
+		info := reorder[p.ID]
+		if stock.Status(p.StockQty, info.ReorderPoint) == "ok" {
+			continue
+		}
+		item := email.LowStockItem{Name: p.Name, Stock: p.StockQty, ReorderPoint: info.ReorderPoint}
+		if p.OutletID == nil {
+			digest.SharedLowStock = append(digest.SharedLowStock, item)
+		} else if d, ok := byOutlet[*p.OutletID]; ok {
+			d.LowStock = append(d.LowStock, item)
+		}
+	}
+	sortLowStock(digest.SharedLowStock)
+
+	total := email.OutletDigest{Name: "Semua Outlet"}
+	for _, id := range order {
+		d := byOutlet[id]
+		sortLowStock(d.LowStock)
+		digest.Outlets = append(digest.Outlets, *d)
+		total.Sales += d.Sales
+		total.Transactions += d.Transactions
+		total.CashSales += d.CashSales
+		total.CashCounts += d.CashCounts
+		total.ExpectedCash += d.ExpectedCash
+		total.CountedCash += d.CountedCash
+		total.CashVariance += d.CashVariance
+		total.Voids += d.Voids
+		total.VoidAmount += d.VoidAmount
+	}
+	if len(digest.Outlets) > 1 {
+		if total.Transactions > 0 {
+			total.AverageSale = total.Sales / float64(total.Transactions)
+		}
+		digest.Total = &total
+	}
+	return digest
+}
+
+// sortLowStock puts products that ran out first, then the lowest stock
+// relative to its reorder point
+func sortLowStock(items []email.LowStockItem) {
+	sort.SliceStable(items, func(i, j int) bool {
+		if out := items[i].Stock <= 0; out != (items[j].Stock <= 0) {
+			return out
+		}
+		return items[i].Stock*items[j].ReorderPoint < items[j].Stock*items[i].ReorderPoint
+	})
+}
+
+// digestAttachment writes the per-outlet figures as a file
+func digestAttachment(digest email.ReportDigest, format, filename string) (email.Attachment, error) {
+	var buf bytes.Buffer
+	w, err := export.New(&buf, format, digest.Title+" "+digest.TenantName+" "+digest.Period, []export.Column{
+		{Header: "Outlet", Width: 1.6}, {Header: "Penjualan"}, {Header: "Transaksi", Width: 0.7},
+		{Header: "Rata-rata"}, {Header: "Kas Tunai"}, {Header: "Hitung Kas", Width: 0.6}, {Header: "Kas Seharusnya"},
+		{Header: "Kas Dihitung"}, {Header: "Selisih Kas"}, {Header: "Void", Width: 0.6}, {Header: "Nilai Void"},
+	})
+	if err != nil {
+		return email.Attachment{}, err
+	}
+	rows := digest.Outlets
+	if digest.Total != nil {
+		rows = append(rows, *digest.Total)
+	}
+	for _, d := range rows {
+		if err := w.Row(d.Name, d.Sales, d.Transactions, d.AverageSale, d.CashSales, d.CashCounts, d.ExpectedCash,
+			d.CountedCash, d.CashVariance, d.Voids, d.VoidAmount); err != nil {
+			return email.Attachment{}, err
+		}
+	}
+	if err := w.Close(); err != nil {
+		return email.Attachment{}, err
+	}
+	return email.Attachment{Filename: filename + "." + format, Content: buf.Bytes()}, nil
+}
+
+// sendDigest emails the report for the period ending at end to the
+// subscriber
+func (h *Handler) sendDigest(sub database.ReportSubscription, end time.Time) error {
+	emailService := email.NewEmailService()
+	if !emailService.IsConfigured() {
+		return fmt.Errorf("email service not configured")
+	}
+
+	var user database.User
+	if err := h.db.Where("id = ? AND tenant_id = ?", sub.UserID, sub.TenantID).First(&user).Error; err != nil {
+		return fmt.Errorf("user not found")
+	}
+	if !user.IsActive || !middleware.IsManager(user.Role) {
+		return fmt.Errorf("user can no longer receive reports")
+	}
+
+	loc := h.subscriptionLocation(sub)
+	end = end.In(loc)
+	start := shiftPeriod(sub.Frequency, end, -1)
+	digest := h.buildDigest(sub, start, end)
+	digest.UserName = user.Name
+	digest.Period = start.Format("02/01/2006 15:04") + " - " + end.Format("02/01/2006 15:04")
+
+	var attachments []email.Attachment
+	if sub.Attachment != "" {
+		filename := fmt.Sprintf("ringkasan_%s_%s", sub.Frequency, end.Format("2006-01-02"))
+		attachment, err := digestAttachment(digest, sub.Attachment, filename)
+		if err != nil {
+			return err
+		}
+		attachments = append(attachments, attachment)
+	}
+
+	return emailService.SendReportDigest(user.Email, digest, attachments)
+}
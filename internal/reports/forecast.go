@@ -0,0 +1,482 @@
+package reports
+
+import (
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/yuditriaji/warungin-backend/pkg/database"
+	"github.com/yuditriaji/warungin-backend/pkg/export"
+	"github.com/yuditriaji/warungin-backend/pkg/forecast"
+	"github.com/yuditriaji/warungin-backend/pkg/middleware"
+)
+
+// Forecast limits
+const (
+	defaultForecastDays = 7
+	maxForecastDays     = 14
+	defaultHistoryWeeks = 8
+	maxHistoryWeeks     = 26
+)
+
+// ProductForecast is one product's expected sales at one outlet
+type ProductForecast struct {
+	OutletID    *uuid.UUID `json:"outlet_id"`
+	OutletName  string     `json:"outlet_name"`
+	ProductID   uuid.UUID  `json:"product_id"` // The variant, for variants
+	ProductName string     `json:"product_name"`
+	History     float64    `json:"history"` // Average a day over the history
+	Daily       []float64  `json:"daily"`   // One per forecast date
+	Total       float64    `json:"total"`
+}
+
+// RestockLine compares an item's expected use with its stock. Top-ups keep
+// stock at its minimum level; prepared materials are made, the rest bought.
+type RestockLine struct {
+	Type          string    `json:"type"` // product or material
+	ID            uuid.UUID `json:"id"`
+	Name          string    `json:"name"`
+	Unit          string    `json:"unit"`
+	IsPrepared    bool      `json:"is_prepared,omitempty"`
+	StockQty      float64   `json:"stock_qty"`
+	MinStockLevel float64   `json:"min_stock_level"`
+	Daily         []float64 `json:"daily"` // Expected use per forecast date
+	Usage         float64   `json:"usage"`
+	RunsOutOn     string    `json:"runs_out_on,omitempty"` // First date stock won't cover, if any
+	TopUp         float64   `json:"top_up"`                // To buy, or to make for prepared materials
+	UnitCost      float64   `json:"unit_cost"`
+	EstimatedCost float64   `json:"estimated_cost"` // Of buying TopUp; 0 for prepared materials, whose inputs are listed instead
+	Supplier      string    `json:"supplier,omitempty"`
+}
+
+type DemandForecast struct {
+	Method        string            `json:"method"`
+	Timezone      string            `json:"timezone"`
+	HistoryStart  string            `json:"history_start"`
+	HistoryEnd    string            `json:"history_end"`
+	Dates         []string          `json:"dates"`
+	Products      []ProductForecast `json:"products"`
+	Restock       []RestockLine     `json:"restock"`       // Products keeping their own stock
+	Materials     []RestockLine     `json:"materials"`     // Materials used by the forecast sales
+	ShoppingList  []RestockLine     `json:"shopping_list"` // Products and materials to buy
+	ShoppingTotal float64           `json:"shopping_total"`
+}
+
+// roundUp rounds a quantity up to two decimals
+func roundUp(qty float64) float64 {
+	return math.Ceil(qty*100-1e-9) / 100
+}
+
+// topUps returns how much has to be added each day to keep stock at min or
+// above while daily is used
+func topUps(daily []float64, stock, min float64) []float64 {
+	added := make([]float64, len(daily))
+	level := stock
+	for day, use := range daily {
+		level -= use
+		if level < min {
+			added[day] = min - level
+			level = min
+		}
+	}
+	return added
+}
+
+// restockLine totals daily use against stock
+func restockLine(line RestockLine, daily []float64, dates []string) RestockLine {
+	line.Daily = make([]float64, len(daily))
+	var used float64
+	for day, use := range daily {
+		line.Daily[day] = roundUp(use)
+		line.Usage += use
+		used += use
+		if line.RunsOutOn == "" && used > line.StockQty {
+			line.RunsOutOn = dates[day]
+		}
+	}
+	for _, qty := range topUps(daily, line.StockQty, line.MinStockLevel) {
+		line.TopUp += qty
+	}
+	line.Usage = roundUp(line.Usage)
+	line.TopUp = roundUp(line.TopUp)
+	if !line.IsPrepared {
+		line.EstimatedCost = line.TopUp * line.UnitCost
+	}
+	return line
+}
+
+// materialOrder lists materials so every prepared material comes before the
+// materials it is made from
+func materialOrder(materials map[uuid.UUID]*database.RawMaterial) []uuid.UUID {
+	var order []uuid.UUID
+	state := make(map[uuid.UUID]int) // 1 = visiting, 2 = done
+	var visit func(id uuid.UUID)
+	visit = func(id uuid.UUID) {
+		material, ok := materials[id]
+		if !ok || state[id] != 0 {
+			return
+		}
+		state[id] = 1
+		for _, comp := range material.Components {
+			visit(comp.ComponentID)
+		}
+		state[id] = 2
+		order = append(order, id)
+	}
+	ids := make([]uuid.UUID, 0, len(materials))
+	for id := range materials {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i].String() < ids[j].String() })
+	for _, id := range ids {
+		visit(id)
+	}
+	// Components were added before the materials made from them
+	for i, j := 0, len(order)-1; i < j; i, j = i+1, j-1 {
+		order[i], order[j] = order[j], order[i]
+	}
+	return order
+}
+
+// intQuery reads a whole-number query parameter within min and max
+func intQuery(c *gin.Context, name string, def, min, max int) (int, bool) {
+	value := c.Query(name)
+	if value == "" {
+		return def, true
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < min || n > max {
+		c.JSON(http.StatusBadRequest, gin.H{"error": name + " must be " + strconv.Itoa(min) + " to " + strconv.Itoa(max)})
+		return 0, false
+	}
+	return n, true
+}
+
+// GetDemandForecast forecasts each product's daily sales per outlet from its
+// sales history and turns them, through product recipes, into the materials
+// that will be used, giving a list of what to buy (owners and managers only).
+// Bundles count through their components and variants separately. Query
+// parameters:
+//
+//	days           days to forecast from tomorrow, 1-14, default 7
+//	history_weeks  weeks of sales to learn from, 1-26, default 8
+//	method         smoothing (default) or seasonal_naive
+//	outlet_id      one outlet's sales
+//	format         csv, xlsx or pdf to download the shopping list
+func (h *Handler) GetDemandForecast(c *gin.Context) {
+	if !middleware.IsManager(c.GetString("role")) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Hanya owner dan manager yang dapat melihat perkiraan kebutuhan"})
+		return
+	}
+	tenantID := c.GetString("tenant_id")
+
+	days, ok := intQuery(c, "days", defaultForecastDays, 1, maxForecastDays)
+	if !ok {
+		return
+	}
+	weeks, ok := intQuery(c, "history_weeks", defaultHistoryWeeks, 1, maxHistoryWeeks)
+	if !ok {
+		return
+	}
+	method := c.DefaultQuery("method", forecast.Smoothing)
+	if !forecast.ValidMethod(method) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "method must be smoothing or seasonal_naive"})
+		return
+	}
+	outletID := c.Query("outlet_id")
+	format, ok := export.Requested(c)
+	if !ok {
+		return
+	}
+
+	settings := h.tenantSettings(tenantID)
+	zone := settings.TimezoneName()
+	now := time.Now().In(settings.Location())
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	historyStart := today.AddDate(0, 0, -7*weeks)
+	historyDays := 7 * weeks
+
+	report := DemandForecast{
+		Method:       method,
+		Timezone:     zone,
+		HistoryStart: historyStart.Format("2006-01-02"),
+		HistoryEnd:   today.AddDate(0, 0, -1).Format("2006-01-02"),
+		Products:     []ProductForecast{},
+		Restock:      []RestockLine{},
+		Materials:    []RestockLine{},
+		ShoppingList: []RestockLine{},
+	}
+	for day := 1; day <= days; day++ {
+		report.Dates = append(report.Dates, today.AddDate(0, 0, day).Format("2006-01-02"))
+	}
+
+	// Daily quantities of each stock item: the variant sold, or each
+	// component of a bundle
+	soldArgs := []interface{}{zone, tenantID, historyStart, today}
+	outletFilter := ""
+	if outletID != "" {
+		outletFilter = " AND transactions.outlet_id = ?"
+		soldArgs = append(soldArgs, outletID)
+	}
+	var rows []struct {
+		OutletID *uuid.UUID
+		ItemID   uuid.UUID
+		Day      string
+		Quantity float64
+	}
+	if err := h.db.Raw(`
+		SELECT outlet_id, item_id, day, SUM(quantity) AS quantity FROM (
+			SELECT transactions.outlet_id, COALESCE(transaction_items.variant_id, transaction_items.product_id) AS item_id,
+				to_char(transactions.created_at AT TIME ZONE ?, 'YYYY-MM-DD') AS day, transaction_items.quantity
+			FROM transaction_items
+			JOIN transactions ON transactions.id = transaction_items.transaction_id
+			WHERE transactions.tenant_id = ? AND transactions.status = 'completed'
+				AND transactions.created_at >= ? AND transactions.created_at < ?`+outletFilter+`
+				AND NOT EXISTS (SELECT 1 FROM transaction_item_components WHERE transaction_item_components.transaction_item_id = transaction_items.id)
+			UNION ALL
+			SELECT transactions.outlet_id, COALESCE(transaction_item_components.variant_id, transaction_item_components.product_id),
+				to_char(transactions.created_at AT TIME ZONE ?, 'YYYY-MM-DD'), transaction_item_components.quantity
+			FROM transaction_item_components
+			JOIN transaction_items ON transaction_items.id = transaction_item_components.transaction_item_id
+			JOIN transactions ON transactions.id = transaction_items.transaction_id
+			WHERE transactions.tenant_id = ? AND transactions.status = 'completed'
+				AND transactions.created_at >= ? AND transactions.created_at < ?`+outletFilter+`
+		) AS sold
+		GROUP BY outlet_id, item_id, day`, append(soldArgs, soldArgs...)...).
+		Scan(&rows).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch sales history"})
+		return
+	}
+
+	type seriesKey struct {
+		outlet uuid.UUID
+		item   uuid.UUID
+	}
+	history := make(map[seriesKey][]float64)
+	itemIDs := make(map[uuid.UUID]bool)
+	for _, row := range rows {
+		day, err := time.ParseInLocation("2006-01-02", row.Day, today.Location())
+		if err != nil {
+			continue
+		}
+		index := int(math.Round(day.Sub(historyStart).Hours() / 24))
+		if index < 0 || index >= historyDays {
+			continue
+		}
+		key := seriesKey{outletKey(row.OutletID), row.ItemID}
+		if history[key] == nil {
+			history[key] = make([]float64, historyDays)
+		}
+		history[key][index] += row.Quantity
+		itemIDs[row.ItemID] = true
+	}
+
+	// Products still on sale, named with their parent for variants
+	ids := make([]uuid.UUID, 0, len(itemIDs))
+	for id := range itemIDs {
+		ids = append(ids, id)
+	}
+	products := make(map[uuid.UUID]database.Product)
+	names := make(map[uuid.UUID]string)
+	if len(ids) > 0 {
+		var list []database.Product
+		h.db.Where("tenant_id = ? AND id IN ? AND is_active = ?", tenantID, ids, true).Find(&list)
+		parentIDs := []uuid.UUID{}
+		for _, p := range list {
+			products[p.ID] = p
+			names[p.ID] = p.Name
+			if p.ParentID != nil {
+				parentIDs = append(parentIDs, *p.ParentID)
+			}
+		}
+		if len(parentIDs) > 0 {
+			var parents []database.Product
+			h.db.Unscoped().Where("id IN ?", parentIDs).Find(&parents)
+			parentNames := make(map[uuid.UUID]string, len(parents))
+			for _, parent := range parents {
+				parentNames[parent.ID] = parent.Name
+			}
+			for _, p := range list {
+				if p.ParentID != nil && p.VariantName != "" {
+					names[p.ID] = parentNames[*p.ParentID] + " - " + p.VariantName
+				}
+			}
+		}
+	}
+
+	var outlets []database.Outlet
+	h.db.Unscoped().Where("tenant_id = ?", tenantID).Find(&outlets)
+	outletNames := make(map[uuid.UUID]string, len(outlets))
+	for _, outlet := range outlets {
+		outletNames[outlet.ID] = outlet.Name
+	}
+
+	// Forecast each outlet's sales of each product, and total them per product
+	itemDemand := make(map[uuid.UUID][]float64)
+	for key, series := range history {
+		p, ok := products[key.item]
+		if !ok {
+			continue
+		}
+		// Learn only from when the product started selling
+		first := 0
+		for first < len(series) && series[first] == 0 {
+			first++
+		}
+		series = series[first:]
+		daily := forecast.Daily(method, series, historyStart.AddDate(0, 0, first).Weekday(), days)
+
+		pf := ProductForecast{ProductID: p.ID, ProductName: names[p.ID], Daily: make([]float64, days)}
+		if key.outlet != uuid.Nil {
+			id := key.outlet
+			pf.OutletID = &id
+			pf.OutletName = outletNames[id]
+		}
+		var sold float64
+		for _, qty := range series {
+			sold += qty
+		}
+		pf.History = sold / float64(len(series))
+		if itemDemand[p.ID] == nil {
+			itemDemand[p.ID] = make([]float64, days)
+		}
+		for day, qty := range daily {
+			pf.Daily[day] = math.Round(qty*100) / 100
+			pf.Total += qty
+			itemDemand[p.ID][day] += qty
+		}
+		pf.Total = math.Round(pf.Total*100) / 100
+		report.Products = append(report.Products, pf)
+	}
+	sort.Slice(report.Products, func(i, j int) bool {
+		if report.Products[i].OutletName != report.Products[j].OutletName {
+			return report.Products[i].OutletName < report.Products[j].OutletName
+		}
+		return report.Products[i].Total > report.Products[j].Total
+	})
+
+	// Products keeping their own stock
+	for id, daily := range itemDemand {
+		p := products[id]
+		if p.UseMaterialStock {
+			continue
+		}
+		line := restockLine(RestockLine{
+			Type: "product", ID: p.ID, Name: names[p.ID], Unit: "pcs",
+			StockQty: float64(p.StockQty), MinStockLevel: float64(p.MinStockLevel), UnitCost: p.Cost,
+		}, daily, report.Dates)
+		line.TopUp = math.Ceil(line.TopUp)
+		line.EstimatedCost = line.TopUp * line.UnitCost
+		report.Restock = append(report.Restock, line)
+	}
+
+	// Materials used by the forecast sales, then by making prepared ones
+	var links []database.ProductMaterial
+	if len(itemDemand) > 0 {
+		linkIDs := make([]uuid.UUID, 0, len(itemDemand))
+		for id := range itemDemand {
+			linkIDs = append(linkIDs, id)
+		}
+		h.db.Where("product_id IN ?", linkIDs).Find(&links)
+	}
+	var materialList []database.RawMaterial
+	h.db.Where("tenant_id = ?", tenantID).Preload("Components").Find(&materialList)
+	materials := make(map[uuid.UUID]*database.RawMaterial, len(materialList))
+	for i := range materialList {
+		materials[materialList[i].ID] = &materialList[i]
+	}
+
+	materialDemand := make(map[uuid.UUID][]float64)
+	use := func(id uuid.UUID, day int, qty float64) {
+		if materialDemand[id] == nil {
+			materialDemand[id] = make([]float64, days)
+		}
+		materialDemand[id][day] += qty
+	}
+	for _, link := range links {
+		for day, qty := range itemDemand[link.ProductID] {
+			use(link.MaterialID, day, qty*link.QuantityUsed*conversionRate(link.ConversionRate))
+		}
+	}
+	for _, id := range materialOrder(materials) {
+		daily, ok := materialDemand[id]
+		if !ok {
+			continue
+		}
+		m := materials[id]
+		line := restockLine(RestockLine{
+			Type: "material", ID: m.ID, Name: m.Name, Unit: m.Unit, IsPrepared: m.IsPrepared,
+			StockQty: m.StockQty, MinStockLevel: m.MinStockLevel, UnitCost: m.UnitPrice, Supplier: m.Supplier,
+		}, daily, report.Dates)
+		report.Materials = append(report.Materials, line)
+
+		// Batches made to top the prepared material up use its components
+		if m.IsPrepared && len(m.Components) > 0 {
+			yield := m.YieldQty
+			if yield <= 0 {
+				yield = 1
+			}
+			for day, qty := range topUps(daily, m.StockQty, m.MinStockLevel) {
+				for _, comp := range m.Components {
+					use(comp.ComponentID, day, qty/yield*comp.QuantityUsed*conversionRate(comp.ConversionRate))
+				}
+			}
+		}
+	}
+
+	for _, line := range append(report.Restock, report.Materials...) {
+		if line.TopUp > 0 && !line.IsPrepared {
+			report.ShoppingList = append(report.ShoppingList, line)
+			report.ShoppingTotal += line.EstimatedCost
+		}
+	}
+	byUrgency := func(lines []RestockLine) {
+		sort.SliceStable(lines, func(i, j int) bool {
+			a, b := lines[i].RunsOutOn, lines[j].RunsOutOn
+			if (a == "") != (b == "") {
+				return a != ""
+			}
+			if a != b {
+				return a < b
+			}
+			return lines[i].Name < lines[j].Name
+		})
+	}
+	byUrgency(report.Restock)
+	byUrgency(report.Materials)
+	byUrgency(report.ShoppingList)
+
+	if format != "" {
+		w, err := export.Start(c, format, "daftar_belanja_"+report.Dates[0],
+			"Daftar Belanja "+report.Dates[0]+" s/d "+report.Dates[len(report.Dates)-1], []export.Column{
+				{Header: "Barang", Width: 1.8}, {Header: "Jenis", Width: 0.7}, {Header: "Satuan", Width: 0.6},
+				{Header: "Stok", Width: 0.7}, {Header: "Perkiraan Pakai", Width: 0.9}, {Header: "Habis", Width: 0.9},
+				{Header: "Beli", Width: 0.7}, {Header: "Perkiraan Biaya"}, {Header: "Pemasok"},
+			})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate file"})
+			return
+		}
+		for _, line := range report.ShoppingList {
+			w.Row(line.Name, line.Type, line.Unit, line.StockQty, line.Usage, line.RunsOutOn, line.TopUp,
+				line.EstimatedCost, line.Supplier)
+		}
+		w.Row("Total", nil, nil, nil, nil, nil, nil, report.ShoppingTotal, nil)
+		w.Close()
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": report})
+}
+
+// conversionRate defaults a recipe's conversion rate to 1
+func conversionRate(rate float64) float64 {
+	if rate <= 0 {
+		return 1
+	}
+	return rate
+}
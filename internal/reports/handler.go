@@ -6,7 +6,10 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/yuditriaji/warungin-backend/pkg/categories"
 	"github.com/yuditriaji/warungin-backend/pkg/database"
+	"github.com/yuditriaji/warungin-backend/pkg/export"
+	"github.com/yuditriaji/warungin-backend/pkg/recipe"
 	"gorm.io/gorm"
 )
 
@@ -22,6 +25,7 @@ type SalesReportRequest struct {
 	StartDate string `form:"start_date"` // Format: 2024-01-01
 	EndDate   string `form:"end_date"`   // Format: 2024-01-31
 	OutletID  string `form:"outlet_id"`  // Optional outlet filter
+	CategoryID string `form:"category_id"` // Optional category filter (includes subcategories, "none" = uncategorized)
 }
 
 type DailySales struct {
@@ -52,6 +56,10 @@ func (h *Handler) GetSalesReport(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	format, ok := export.Requested(c)
+	if !ok {
+		return
+	}
 
 	// Default to current month if no dates provided
 	now := time.Now()
@@ -87,6 +95,12 @@ func (h *Handler) GetSalesReport(c *gin.Context) {
 	if req.OutletID != "" {
 		totalsQuery = totalsQuery.Where("outlet_id = ?", req.OutletID)
 	}
+
+	// With a category filter only that category's line items count
+	if req.CategoryID != "" {
+		totalsQuery = h.itemsQuery(tenantID, startDate, endDate, req).
+			Select("COALESCE(SUM(transaction_items.subtotal), 0) as sales, COUNT(DISTINCT transactions.id) as transactions")
+	}
 	totalsQuery.Scan(&totals)
 	
 	report.TotalSales = totals.Sales
@@ -97,21 +111,14 @@ func (h *Handler) GetSalesReport(c *gin.Context) {
 
 	// Get items sold count
 	var itemCount int64
-	itemsQuery := h.db.Model(&database.TransactionItem{}).
+	h.itemsQuery(tenantID, startDate, endDate, req).
 		Select("COALESCE(SUM(transaction_items.quantity), 0)").
-		Joins("JOIN transactions ON transaction_items.transaction_id = transactions.id").
-		Where("transactions.tenant_id = ? AND transactions.created_at >= ? AND transactions.created_at <= ? AND transactions.status = ?",
-			tenantID, startDate, endDate, "completed")
-	
-	if req.OutletID != "" {
-		itemsQuery = itemsQuery.Where("transactions.outlet_id = ?", req.OutletID)
-	}
-	itemsQuery.Scan(&itemCount)
+		Scan(&itemCount)
 	report.TotalItemsSold = int(itemCount)
 
 	// Calculate total cost by iterating through transaction items
 	// This properly handles material-driven products
-	report.TotalCost = h.calculateTotalCOGS(tenantID, startDate, endDate, req.OutletID)
+	report.TotalCost = h.calculateTotalCOGS(tenantID, startDate, endDate, req)
 	report.GrossProfit = report.TotalSales - report.TotalCost
 
 	// Get daily breakdown
@@ -123,8 +130,12 @@ func (h *Handler) GetSalesReport(c *gin.Context) {
 	if req.OutletID != "" {
 		dailyQuery = dailyQuery.Where("outlet_id = ?", req.OutletID)
 	}
+	if req.CategoryID != "" {
+		dailyQuery = h.itemsQuery(tenantID, startDate, endDate, req).
+			Select("DATE(transactions.created_at) as date, COALESCE(SUM(transaction_items.subtotal), 0) as sales, COUNT(DISTINCT transactions.id) as transactions")
+	}
 	
-	rows, _ := dailyQuery.Group("DATE(created_at)").Order("date ASC").Rows()
+	rows, _ := dailyQuery.Group("date").Order("date ASC").Rows()
 	
 	if rows != nil {
 		defer rows.Close()
@@ -135,69 +146,104 @@ func (h *Handler) GetSalesReport(c *gin.Context) {
 		}
 	}
 
+	if format != "" {
+		w, err := export.Start(c, format, "penjualan_"+report.StartDate+"_"+report.EndDate,
+			"Laporan Penjualan "+report.StartDate+" s/d "+report.EndDate, []export.Column{
+				{Header: "Tanggal"}, {Header: "Penjualan"}, {Header: "Transaksi"},
+			})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate file"})
+			return
+		}
+		for _, daily := range report.DailySales {
+			w.Row(daily.Date, daily.Sales, daily.Transactions)
+		}
+		w.Row("Total", report.TotalSales, report.TotalTransactions)
+		w.Row("HPP", report.TotalCost, nil)
+		w.Row("Laba Kotor", report.GrossProfit, nil)
+		w.Close()
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{"data": report})
 }
 
-// calculateTotalCOGS calculates total cost of goods sold, including material costs for material-driven products
-func (h *Handler) calculateTotalCOGS(tenantID string, startDate, endDate time.Time, outletID string) float64 {
-	// Get all transaction items in the period
-	type ItemWithProduct struct {
-		ProductID        uuid.UUID
-		Quantity         int
-		Cost             float64
-		UseMaterialStock bool
-	}
-	
-	var items []ItemWithProduct
+// itemsQuery returns completed transaction items in the period, joined with
+// their transaction and product and filtered by outlet and category
+func (h *Handler) itemsQuery(tenantID string, startDate, endDate time.Time, req SalesReportRequest) *gorm.DB {
 	query := h.db.Model(&database.TransactionItem{}).
-		Select("transaction_items.product_id, transaction_items.quantity, products.cost, products.use_material_stock").
 		Joins("JOIN transactions ON transaction_items.transaction_id = transactions.id").
 		Joins("JOIN products ON transaction_items.product_id = products.id").
 		Where("transactions.tenant_id = ? AND transactions.created_at >= ? AND transactions.created_at <= ? AND transactions.status = ?",
 			tenantID, startDate, endDate, "completed")
-	
-	if outletID != "" {
-		query = query.Where("transactions.outlet_id = ?", outletID)
+
+	if req.OutletID != "" {
+		query = query.Where("transactions.outlet_id = ?", req.OutletID)
 	}
-	query.Scan(&items)
-	
-	var totalCost float64
-	for _, item := range items {
-		var unitCost float64
-		
-		if item.UseMaterialStock {
-			// Calculate cost from materials when material-driven
-			unitCost = h.calculateMaterialCost(item.ProductID)
-		} else {
-			unitCost = item.Cost
+
+	switch req.CategoryID {
+	case "":
+	case "none":
+		query = query.Where("products.category_id IS NULL")
+	default:
+		categoryID, err := uuid.Parse(req.CategoryID)
+		if err != nil {
+			return query.Where("1 = 0")
 		}
-		
-		totalCost += unitCost * float64(item.Quantity)
+		query = query.Where("products.category_id IN ?", categories.Load(h.db, tenantID).WithDescendants(categoryID))
 	}
-	
-	return totalCost
+	return query
 }
 
-// calculateMaterialCost calculates the cost of one unit of a product based on its raw materials
-func (h *Handler) calculateMaterialCost(productID uuid.UUID) float64 {
-	var productMaterials []database.ProductMaterial
-	h.db.Where("product_id = ?", productID).Preload("Material").Find(&productMaterials)
+// costSelect sums the unit costs captured at sale time; items sold before
+// costs were captured are counted separately so they can be costed live
+const costSelect = `
+	COALESCE(SUM(transaction_items.unit_cost * transaction_items.quantity), 0) as captured_cost,
+	COALESCE(SUM(CASE WHEN transaction_items.unit_cost IS NULL THEN transaction_items.quantity ELSE 0 END), 0) as uncaptured_qty,
+	products.cost,
+	products.use_material_stock`
 
+// itemCost returns the cost of a product's sold items, using captured unit costs
+// and falling back to the current product cost for uncaptured items
+func itemCost(calc *recipe.Calculator, productID uuid.UUID, capturedCost float64, uncapturedQty int, cost float64, useMaterialStock bool) float64 {
+	if uncapturedQty == 0 {
+		return capturedCost
+	}
+	product := database.Product{Cost: cost, UseMaterialStock: useMaterialStock}
+	product.ID = productID
+	return capturedCost + calc.ProductUnitCost(product)*float64(uncapturedQty)
+}
+
+// calculateTotalCOGS calculates total cost of goods sold from unit costs captured at sale time
+func (h *Handler) calculateTotalCOGS(tenantID string, startDate, endDate time.Time, req SalesReportRequest) float64 {
+	// Get captured cost per product in the period
+	type ProductCOGS struct {
+		ProductID        uuid.UUID
+		CapturedCost     float64
+		UncapturedQty    int
+		Cost             float64
+		UseMaterialStock bool
+	}
+	
+	var items []ProductCOGS
+	query := h.itemsQuery(tenantID, startDate, endDate, req).
+		Select("transaction_items.product_id,"+costSelect)
+	query.Group("transaction_items.product_id, products.cost, products.use_material_stock").Scan(&items)
+	
+	calc := recipe.NewCalculator(h.db)
 	var totalCost float64
-	for _, pm := range productMaterials {
-		convRate := pm.ConversionRate
-		if convRate <= 0 {
-			convRate = 1
-		}
-		// Cost = quantity_used × conversion_rate × unit_price
-		totalCost += pm.QuantityUsed * convRate * pm.Material.UnitPrice
+	for _, item := range items {
+		totalCost += itemCost(calc, item.ProductID, item.CapturedCost, item.UncapturedQty, item.Cost, item.UseMaterialStock)
 	}
+	
 	return totalCost
 }
 
 type ProductSalesReport struct {
-	ProductID   string  `json:"product_id"`
-	ProductName string  `json:"product_name"`
+	ProductID    string  `json:"product_id"`
+	ProductName  string  `json:"product_name"`
+	CategoryID   *string `json:"category_id"`
+	CategoryName string  `json:"category_name"`
 	TotalQty    int     `json:"total_qty"`
 	TotalSales  float64 `json:"total_sales"`
 	TotalCost   float64 `json:"total_cost"`
@@ -210,78 +256,54 @@ func (h *Handler) GetProductSalesReport(c *gin.Context) {
 
 	var req SalesReportRequest
 	c.ShouldBindQuery(&req)
-
-	now := time.Now()
-	startDate := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
-	endDate := time.Date(now.Year(), now.Month()+1, 0, 23, 59, 59, 0, now.Location())
-
-	if req.StartDate != "" {
-		if parsed, err := time.Parse("2006-01-02", req.StartDate); err == nil {
-			startDate = parsed
-		}
-	}
-	if req.EndDate != "" {
-		if parsed, err := time.Parse("2006-01-02", req.EndDate); err == nil {
-			endDate = time.Date(parsed.Year(), parsed.Month(), parsed.Day(), 23, 59, 59, 0, parsed.Location())
-		}
-	}
-
-	// Get items grouped by product
-	type ProductItem struct {
-		ProductID        uuid.UUID
-		ProductName      string
-		TotalQty         int
-		TotalSales       float64
-		Cost             float64
-		UseMaterialStock bool
-	}
-	
-	var items []ProductItem
-	productQuery := h.db.Model(&database.TransactionItem{}).
-		Select(`
-			transaction_items.product_id, 
-			products.name as product_name, 
-			SUM(transaction_items.quantity) as total_qty, 
-			SUM(transaction_items.subtotal) as total_sales,
-			products.cost,
-			products.use_material_stock
-		`).
-		Joins("JOIN transactions ON transaction_items.transaction_id = transactions.id").
-		Joins("JOIN products ON transaction_items.product_id = products.id").
-		Where("transactions.tenant_id = ? AND transactions.created_at >= ? AND transactions.created_at <= ? AND transactions.status = ?",
-			tenantID, startDate, endDate, "completed")
-	
-	if req.OutletID != "" {
-		productQuery = productQuery.Where("transactions.outlet_id = ?", req.OutletID)
+	startDate, endDate := dateRange(req)
+	format, ok := export.Requested(c)
+	if !ok {
+		return
 	}
-	
-	productQuery.Group("transaction_items.product_id, products.name, products.cost, products.use_material_stock").
-		Order("total_sales DESC").
-		Scan(&items)
 
-	// Calculate proper cost for each product
+	calc := recipe.NewCalculator(h.db)
 	var products []ProductSalesReport
-	for _, item := range items {
-		var unitCost float64
-		if item.UseMaterialStock {
-			// Calculate cost from materials when material-driven
-			unitCost = h.calculateMaterialCost(item.ProductID)
-		} else {
-			unitCost = item.Cost
+	for _, item := range h.productItems(tenantID, startDate, endDate, req) {
+		totalCost := itemCost(calc, item.ProductID, item.CapturedCost, item.UncapturedQty, item.Cost, item.UseMaterialStock)
+
+		var categoryID *string
+		if item.CategoryID != nil {
+			id := item.CategoryID.String()
+			categoryID = &id
 		}
-		
-		totalCost := unitCost * float64(item.TotalQty)
-		
 		products = append(products, ProductSalesReport{
-			ProductID:   item.ProductID.String(),
-			ProductName: item.ProductName,
-			TotalQty:    item.TotalQty,
-			TotalSales:  item.TotalSales,
-			TotalCost:   totalCost,
-			Profit:      item.TotalSales - totalCost,
+			ProductID:    item.ProductID.String(),
+			ProductName:  item.ProductName,
+			CategoryID:   categoryID,
+			CategoryName: item.CategoryName,
+			TotalQty:     item.TotalQty,
+			TotalSales:   item.TotalSales,
+			TotalCost:    totalCost,
+			Profit:       item.TotalSales - totalCost,
 		})
 	}
 
+	if format != "" {
+		period := startDate.Format("2006-01-02") + "_" + endDate.Format("2006-01-02")
+		w, err := export.Start(c, format, "penjualan_produk_"+period,
+			"Penjualan per Produk "+startDate.Format("2006-01-02")+" s/d "+endDate.Format("2006-01-02"), []export.Column{
+				{Header: "Produk", Width: 2.5}, {Header: "Kategori", Width: 1.5}, {Header: "Qty", Width: 0.6},
+				{Header: "Penjualan"}, {Header: "HPP"}, {Header: "Laba"},
+			})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate file"})
+			return
+		}
+		for _, p := range products {
+			if err := w.Row(p.ProductName, p.CategoryName, p.TotalQty, p.TotalSales, p.TotalCost, p.Profit); err != nil {
+				break
+			}
+		}
+		w.Close()
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{"data": products})
 }
 
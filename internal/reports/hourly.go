@@ -0,0 +1,202 @@
+package reports
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yuditriaji/warungin-backend/pkg/export"
+)
+
+// weekdayNames are indexed by ISO weekday, 1 = Monday
+var weekdayNames = [8]string{"", "Senin", "Selasa", "Rabu", "Kamis", "Jumat", "Sabtu", "Minggu"}
+
+// peakSlots is how many of the busiest slots the hourly report lists
+const peakSlots = 5
+
+// HourlySlot is sales in one hour of one weekday, or of every weekday when
+// Weekday is 0, or every hour when Hour is -1
+type HourlySlot struct {
+	Weekday             int     `json:"weekday"` // 1 = Monday ... 7 = Sunday
+	WeekdayName         string  `json:"weekday_name,omitempty"`
+	Hour                int     `json:"hour"` // 0-23, local time
+	Sales               float64 `json:"sales"`
+	Transactions        int     `json:"transactions"`
+	ItemsSold           int     `json:"items_sold"`
+	AverageBasket       float64 `json:"average_basket"`        // Sales per transaction
+	ItemsPerTransaction float64 `json:"items_per_transaction"` // Items per transaction
+	Days                int     `json:"days"`                  // How many of the weekdays the period holds
+	DailyTransactions   float64 `json:"daily_transactions"`    // Transactions on an average day
+}
+
+func (s *HourlySlot) add(o HourlySlot) {
+	s.Sales += o.Sales
+	s.Transactions += o.Transactions
+	s.ItemsSold += o.ItemsSold
+}
+
+// finish works out the averages over days days
+func (s *HourlySlot) finish(days int) {
+	s.Days = days
+	if s.Transactions > 0 {
+		s.AverageBasket = s.Sales / float64(s.Transactions)
+		s.ItemsPerTransaction = float64(s.ItemsSold) / float64(s.Transactions)
+	}
+	if days > 0 {
+		s.DailyTransactions = float64(s.Transactions) / float64(days)
+	}
+}
+
+type HourlyReport struct {
+	StartDate string       `json:"start_date"`
+	EndDate   string       `json:"end_date"`
+	Timezone  string       `json:"timezone"`
+	Slots     []HourlySlot `json:"slots"`      // Every weekday × hour, Monday 00:00 first
+	ByHour    []HourlySlot `json:"by_hour"`    // Every weekday together
+	ByWeekday []HourlySlot `json:"by_weekday"` // Every hour together
+	Peaks     []HourlySlot `json:"peaks"`      // Busiest slots by transactions
+}
+
+// weekdayCounts returns how many of each ISO weekday fall from first to
+// last
+func weekdayCounts(first, last time.Time) [8]int {
+	var counts [8]int
+	for day := first; !day.After(last); day = day.AddDate(0, 0, 1) {
+		counts[isoWeekday(day)]++
+	}
+	return counts
+}
+
+// GetHourlyReport returns sales by hour of day and day of week in the
+// tenant's time zone, to see when the business is busiest.
+// Query parameters:
+//
+//	start_date, end_date  YYYY-MM-DD in the tenant's time zone, default the current month
+//	outlet_id      one outlet's sales
+//	category_id    only items in the category (and its subcategories); "none" = uncategorized
+//	format         csv, xlsx or pdf to download the slots
+func (h *Handler) GetHourlyReport(c *gin.Context) {
+	tenantID := c.GetString("tenant_id")
+
+	var req SalesReportRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	format, ok := export.Requested(c)
+	if !ok {
+		return
+	}
+	settings := h.tenantSettings(tenantID)
+	zone := settings.TimezoneName()
+	first, last, err := localPeriod(req, settings.Location())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	startTime := first
+	endTime := last.AddDate(0, 0, 1).Add(-time.Microsecond)
+
+	// Each sale once, so its total isn't repeated per item. With a category
+	// only that category's items count towards sales.
+	salesColumn := "transactions.total"
+	if req.CategoryID != "" {
+		salesColumn = "SUM(transaction_items.subtotal)"
+	}
+	sales := h.itemsQuery(tenantID, startTime, endTime, req).
+		Select(fmt.Sprintf(`transactions.id, transactions.created_at AT TIME ZONE ? AS local_time,
+			%s AS sales, SUM(transaction_items.quantity) AS items`, salesColumn), zone).
+		Group("transactions.id, transactions.created_at, transactions.total")
+
+	var rows []HourlySlot
+	if err := h.db.Table("(?) AS sales", sales).
+		Select(`CAST(EXTRACT(ISODOW FROM local_time) AS INTEGER) AS weekday,
+			CAST(EXTRACT(HOUR FROM local_time) AS INTEGER) AS hour,
+			COALESCE(SUM(sales), 0) AS sales, COUNT(*) AS transactions, COALESCE(SUM(items), 0) AS items_sold`).
+		Group("weekday, hour").
+		Scan(&rows).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch hourly report"})
+		return
+	}
+
+	days := weekdayCounts(first, last)
+	var grid [8][24]HourlySlot
+	for _, row := range rows {
+		if row.Weekday >= 1 && row.Weekday <= 7 && row.Hour >= 0 && row.Hour < 24 {
+			grid[row.Weekday][row.Hour] = row
+		}
+	}
+
+	report := HourlyReport{
+		StartDate: first.Format("2006-01-02"),
+		EndDate:   last.Format("2006-01-02"),
+		Timezone:  zone,
+		Slots:     make([]HourlySlot, 0, 7*24),
+		ByHour:    make([]HourlySlot, 24),
+		ByWeekday: make([]HourlySlot, 7),
+	}
+	totalDays := 0
+	for weekday := 1; weekday <= 7; weekday++ {
+		totalDays += days[weekday]
+	}
+	for hour := 0; hour < 24; hour++ {
+		report.ByHour[hour] = HourlySlot{Hour: hour}
+	}
+	for weekday := 1; weekday <= 7; weekday++ {
+		byWeekday := HourlySlot{Weekday: weekday, WeekdayName: weekdayNames[weekday], Hour: -1}
+		for hour := 0; hour < 24; hour++ {
+			slot := grid[weekday][hour]
+			slot.Weekday, slot.WeekdayName, slot.Hour = weekday, weekdayNames[weekday], hour
+			slot.finish(days[weekday])
+			report.Slots = append(report.Slots, slot)
+			byWeekday.add(slot)
+			report.ByHour[hour].add(slot)
+		}
+		byWeekday.finish(days[weekday])
+		report.ByWeekday[weekday-1] = byWeekday
+	}
+	for hour := range report.ByHour {
+		report.ByHour[hour].finish(totalDays)
+	}
+
+	for _, slot := range report.Slots {
+		if slot.Transactions > 0 {
+			report.Peaks = append(report.Peaks, slot)
+		}
+	}
+	sort.SliceStable(report.Peaks, func(i, j int) bool {
+		if report.Peaks[i].Transactions != report.Peaks[j].Transactions {
+			return report.Peaks[i].Transactions > report.Peaks[j].Transactions
+		}
+		return report.Peaks[i].Sales > report.Peaks[j].Sales
+	})
+	if len(report.Peaks) > peakSlots {
+		report.Peaks = report.Peaks[:peakSlots]
+	}
+	if report.Peaks == nil {
+		report.Peaks = []HourlySlot{}
+	}
+
+	if format != "" {
+		w, err := export.Start(c, format, "penjualan_per_jam_"+report.StartDate+"_"+report.EndDate,
+			"Penjualan per Jam "+report.StartDate+" s/d "+report.EndDate+" ("+zone+")", []export.Column{
+				{Header: "Hari"}, {Header: "Jam", Width: 0.6}, {Header: "Penjualan"}, {Header: "Transaksi", Width: 0.8},
+				{Header: "Item", Width: 0.7}, {Header: "Rata-rata Keranjang"}, {Header: "Item/Transaksi", Width: 0.8},
+				{Header: "Transaksi/Hari", Width: 0.8},
+			})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate file"})
+			return
+		}
+		for _, slot := range report.Slots {
+			w.Row(slot.WeekdayName, fmt.Sprintf("%02d:00", slot.Hour), slot.Sales, slot.Transactions, slot.ItemsSold,
+				slot.AverageBasket, slot.ItemsPerTransaction, slot.DailyTransactions)
+		}
+		w.Close()
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": report})
+}
@@ -0,0 +1,230 @@
+package reports
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/yuditriaji/warungin-backend/pkg/export"
+	"github.com/yuditriaji/warungin-backend/pkg/middleware"
+	"github.com/yuditriaji/warungin-backend/pkg/recipe"
+)
+
+// Menu engineering classes
+const (
+	MenuStar      = "star"      // Popular and profitable
+	MenuPlowhorse = "plowhorse" // Popular, low margin
+	MenuPuzzle    = "puzzle"    // Profitable, rarely ordered
+	MenuDog       = "dog"       // Neither
+)
+
+// ABC classes take the products making up the first 80% of revenue as A and
+// the next 15% as B
+const (
+	abcLimitA = 80.0
+	abcLimitB = 95.0
+)
+
+// popularityFactor sets the popularity threshold as a share of an equal
+// split of the quantity sold, 70% in classic menu engineering
+const popularityFactor = 0.7
+
+// menuAdvice suggests what to do with each class
+var menuAdvice = map[string]string{
+	MenuStar:      "Pertahankan kualitas dan tonjolkan di menu",
+	MenuPlowhorse: "Naikkan harga sedikit atau tekan biaya bahan",
+	MenuPuzzle:    "Promosikan atau tempatkan lebih menonjol di menu",
+	MenuDog:       "Pertimbangkan untuk diganti atau dihapus",
+}
+
+// MenuItem is one product's place in the menu engineering matrix
+type MenuItem struct {
+	ProductID       uuid.UUID  `json:"product_id"`
+	ProductName     string     `json:"product_name"`
+	CategoryID      *uuid.UUID `json:"category_id"`
+	CategoryName    string     `json:"category_name"`
+	Quantity        int        `json:"quantity"`
+	Revenue         float64    `json:"revenue"`
+	Cost            float64    `json:"cost"` // Unit costs captured at sale time
+	Margin          float64    `json:"margin"`
+	UnitPrice       float64    `json:"unit_price"`  // Average selling price
+	UnitMargin      float64    `json:"unit_margin"` // Contribution margin per item
+	MarginPercent   float64    `json:"margin_percent"`
+	MixShare        float64    `json:"mix_share"`     // Percent of items sold
+	RevenueShare    float64    `json:"revenue_share"` // Percent of revenue
+	CumulativeShare float64    `json:"cumulative_share"`
+	HighPopularity  bool       `json:"high_popularity"`
+	HighMargin      bool       `json:"high_margin"`
+	Class           string     `json:"class"` // star, plowhorse, puzzle, dog
+	ABC             string     `json:"abc"`   // A, B or C by revenue
+	Recommendation  string     `json:"recommendation"`
+}
+
+type MenuEngineeringReport struct {
+	StartDate           string         `json:"start_date"`
+	EndDate             string         `json:"end_date"`
+	TotalQuantity       int            `json:"total_quantity"`
+	TotalRevenue        float64        `json:"total_revenue"`
+	TotalMargin         float64        `json:"total_margin"`
+	PopularityThreshold float64        `json:"popularity_threshold"` // Mix share at or above which a product is popular
+	MarginThreshold     float64        `json:"margin_threshold"`     // Average unit margin, weighted by quantity
+	Classes             map[string]int `json:"classes"`              // Products per class
+	ABC                 map[string]int `json:"abc"`                  // Products per ABC class
+	Items               []MenuItem     `json:"items"`
+}
+
+// menuEngineering classifies the products sold in a period
+func (h *Handler) menuEngineering(tenantID string, startTime, endTime time.Time, req SalesReportRequest) MenuEngineeringReport {
+	report := MenuEngineeringReport{
+		Classes: map[string]int{MenuStar: 0, MenuPlowhorse: 0, MenuPuzzle: 0, MenuDog: 0},
+		ABC:     map[string]int{"A": 0, "B": 0, "C": 0},
+		Items:   []MenuItem{},
+	}
+
+	calc := recipe.NewCalculator(h.db)
+	for _, p := range h.productItems(tenantID, startTime, endTime, req) {
+		if p.TotalQty <= 0 {
+			continue
+		}
+		cost := itemCost(calc, p.ProductID, p.CapturedCost, p.UncapturedQty, p.Cost, p.UseMaterialStock)
+		item := MenuItem{
+			ProductID:    p.ProductID,
+			ProductName:  p.ProductName,
+			CategoryID:   p.CategoryID,
+			CategoryName: p.CategoryName,
+			Quantity:     p.TotalQty,
+			Revenue:      p.TotalSales,
+			Cost:         cost,
+			Margin:       p.TotalSales - cost,
+			UnitPrice:    p.TotalSales / float64(p.TotalQty),
+			UnitMargin:   (p.TotalSales - cost) / float64(p.TotalQty),
+		}
+		if item.Revenue != 0 {
+			item.MarginPercent = item.Margin / item.Revenue * 100
+		}
+		report.Items = append(report.Items, item)
+		report.TotalQuantity += item.Quantity
+		report.TotalRevenue += item.Revenue
+		report.TotalMargin += item.Margin
+	}
+	if len(report.Items) == 0 {
+		return report
+	}
+
+	report.PopularityThreshold = 100 / float64(len(report.Items)) * popularityFactor
+	report.MarginThreshold = report.TotalMargin / float64(report.TotalQuantity)
+
+	// productItems lists the best sellers by revenue first, as ABC needs
+	var cumulative float64
+	for i := range report.Items {
+		item := &report.Items[i]
+		item.MixShare = float64(item.Quantity) / float64(report.TotalQuantity) * 100
+		if report.TotalRevenue > 0 {
+			item.RevenueShare = item.Revenue / report.TotalRevenue * 100
+		}
+
+		// A product is in the class its revenue starts in
+		switch {
+		case cumulative < abcLimitA:
+			item.ABC = "A"
+		case cumulative < abcLimitB:
+			item.ABC = "B"
+		default:
+			item.ABC = "C"
+		}
+		cumulative += item.RevenueShare
+		item.CumulativeShare = cumulative
+
+		item.HighPopularity = item.MixShare >= report.PopularityThreshold
+		item.HighMargin = item.UnitMargin >= report.MarginThreshold
+		switch {
+		case item.HighPopularity && item.HighMargin:
+			item.Class = MenuStar
+		case item.HighPopularity:
+			item.Class = MenuPlowhorse
+		case item.HighMargin:
+			item.Class = MenuPuzzle
+		default:
+			item.Class = MenuDog
+		}
+		item.Recommendation = menuAdvice[item.Class]
+
+		report.Classes[item.Class]++
+		report.ABC[item.ABC]++
+	}
+	return report
+}
+
+// GetMenuEngineeringReport classifies products by popularity and margin
+// (stars, plowhorses, puzzles and dogs) and by share of revenue (ABC), for
+// owners and managers. Query parameters:
+//
+//	start_date, end_date  YYYY-MM-DD in the tenant's time zone, default the current month
+//	outlet_id      one outlet's sales
+//	category_id    compare products within a category (and its subcategories); "none" = uncategorized
+//	class          only star, plowhorse, puzzle or dog products
+//	format         csv, xlsx or pdf to download the report
+func (h *Handler) GetMenuEngineeringReport(c *gin.Context) {
+	if !middleware.IsManager(c.GetString("role")) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Hanya owner dan manager yang dapat melihat analisis menu"})
+		return
+	}
+	tenantID := c.GetString("tenant_id")
+
+	var req SalesReportRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	format, ok := export.Requested(c)
+	if !ok {
+		return
+	}
+	class := c.Query("class")
+	if _, ok := menuAdvice[class]; class != "" && !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "class must be star, plowhorse, puzzle or dog"})
+		return
+	}
+	first, last, err := localPeriod(req, h.tenantLocation(tenantID))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	report := h.menuEngineering(tenantID, first, last.AddDate(0, 0, 1).Add(-time.Microsecond), req)
+	report.StartDate = first.Format("2006-01-02")
+	report.EndDate = last.Format("2006-01-02")
+
+	// Thresholds and counts stay those of the whole menu
+	if class != "" {
+		items := []MenuItem{}
+		for _, item := range report.Items {
+			if item.Class == class {
+				items = append(items, item)
+			}
+		}
+		report.Items = items
+	}
+
+	if format != "" {
+		w, err := export.Start(c, format, "analisis_menu_"+report.StartDate+"_"+report.EndDate,
+			"Analisis Menu "+report.StartDate+" s/d "+report.EndDate, []export.Column{
+				{Header: "Produk", Width: 1.6}, {Header: "Kategori"}, {Header: "Terjual", Width: 0.6},
+				{Header: "Pendapatan"}, {Header: "HPP"}, {Header: "Margin/Item"}, {Header: "Porsi %", Width: 0.6},
+				{Header: "Kelas", Width: 0.7}, {Header: "ABC", Width: 0.4}, {Header: "Saran", Width: 2.2},
+			})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate file"})
+			return
+		}
+		for _, item := range report.Items {
+			w.Row(item.ProductName, item.CategoryName, item.Quantity, item.Revenue, item.Cost, item.UnitMargin,
+				item.MixShare, item.Class, item.ABC, item.Recommendation)
+		}
+		w.Close()
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": report})
+}
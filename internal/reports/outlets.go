@@ -0,0 +1,293 @@
+package reports
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/yuditriaji/warungin-backend/pkg/costing"
+	"github.com/yuditriaji/warungin-backend/pkg/database"
+	"github.com/yuditriaji/warungin-backend/pkg/expenses"
+	"github.com/yuditriaji/warungin-backend/pkg/export"
+	"github.com/yuditriaji/warungin-backend/pkg/middleware"
+)
+
+// OutletPeriod is an outlet's sales in one day, week or month
+type OutletPeriod struct {
+	Period       string  `json:"period"`
+	Sales        float64 `json:"sales"`
+	Transactions int     `json:"transactions"`
+}
+
+// OutletComparison is one outlet's figures in the consolidated report
+type OutletComparison struct {
+	Rank          int            `json:"rank"`
+	OutletID      *uuid.UUID     `json:"outlet_id"` // nil on the total
+	Name          string         `json:"name"`
+	Sales         float64        `json:"sales"` // Completed sale totals, as on the sales report
+	Transactions  int            `json:"transactions"`
+	AverageTicket float64        `json:"average_ticket"`
+	SalesShare    float64        `json:"sales_share"` // Percent of the business's sales
+	PreviousSales float64        `json:"previous_sales"`
+	Growth        Change         `json:"growth"` // Sales against the previous period
+	NetSales      float64        `json:"net_sales"`
+	GrossProfit   float64        `json:"gross_profit"`
+	NetProfit     float64        `json:"net_profit"` // After the outlet's own expenses
+	GrossMargin   float64        `json:"gross_margin"`
+	StockValue    float64        `json:"stock_value"` // Products and materials assigned to the outlet, at cost
+	Series        []OutletPeriod `json:"series"`
+}
+
+type OutletReport struct {
+	StartDate            string             `json:"start_date"`
+	EndDate              string             `json:"end_date"`
+	PreviousStartDate    string             `json:"previous_start_date"`
+	PreviousEndDate      string             `json:"previous_end_date"`
+	Interval             string             `json:"interval"`
+	Timezone             string             `json:"timezone"`
+	SortBy               string             `json:"sort_by"`
+	Periods              []string           `json:"periods"` // Labels of every outlet's series
+	Outlets              []OutletComparison `json:"outlets"`
+	Total                OutletComparison   `json:"total"`                  // The whole business, including expenses not tied to an outlet
+	UnassignedStockValue float64            `json:"unassigned_stock_value"` // Stock shared by every outlet
+}
+
+// outletSortKeys are the figures outlets can be ranked by
+var outletSortKeys = map[string]func(OutletComparison) float64{
+	"sales":        func(o OutletComparison) float64 { return o.Sales },
+	"profit":       func(o OutletComparison) float64 { return o.NetProfit },
+	"gross_profit": func(o OutletComparison) float64 { return o.GrossProfit },
+	"ticket":       func(o OutletComparison) float64 { return o.AverageTicket },
+	"growth": func(o OutletComparison) float64 {
+		if o.Growth.Percent != nil {
+			return *o.Growth.Percent
+		}
+		if o.Growth.Amount > 0 {
+			return math.Inf(1) // Sales where there were none
+		}
+		return 0
+	},
+	"stock_value": func(o OutletComparison) float64 { return o.StockValue },
+}
+
+// seriesLabel labels a period the way periodLabel does in SQL
+func seriesLabel(start time.Time, interval string) string {
+	switch interval {
+	case "week":
+		year, week := start.ISOWeek()
+		return fmt.Sprintf("%04d-W%02d", year, week)
+	case "month":
+		return start.Format("2006-01")
+	default:
+		return start.Format("2006-01-02")
+	}
+}
+
+// outletSales returns completed sales per outlet from first to last;
+// sales without an outlet are under uuid.Nil
+func (h *Handler) outletSales(tenantID string, first, last time.Time) (map[uuid.UUID]OutletPeriod, error) {
+	var rows []struct {
+		OutletID     *uuid.UUID
+		Sales        float64
+		Transactions int
+	}
+	err := h.db.Model(&database.Transaction{}).
+		Select("outlet_id, COALESCE(SUM(total), 0) AS sales, COUNT(*) AS transactions").
+		Where("tenant_id = ? AND created_at >= ? AND created_at <= ? AND status = ?",
+			tenantID, first, last.AddDate(0, 0, 1).Add(-time.Microsecond), "completed").
+		Group("outlet_id").
+		Scan(&rows).Error
+	sales := make(map[uuid.UUID]OutletPeriod, len(rows))
+	for _, row := range rows {
+		sales[outletKey(row.OutletID)] = OutletPeriod{Sales: row.Sales, Transactions: row.Transactions}
+	}
+	return sales, err
+}
+
+// GetOutletReport compares every outlet side by side and ranks them (owners
+// and managers only). Query parameters:
+//
+//	start_date, end_date  YYYY-MM-DD in the tenant's time zone, default the current month
+//	interval       day (default), week or month for the series
+//	sort           sales (default), profit, gross_profit, ticket, growth or stock_value
+//	format         csv, xlsx or pdf to download the ranking
+func (h *Handler) GetOutletReport(c *gin.Context) {
+	if !middleware.IsManager(c.GetString("role")) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Hanya owner dan manager yang dapat melihat laporan gabungan outlet"})
+		return
+	}
+	tenantID := c.GetString("tenant_id")
+
+	var req SalesReportRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	format, ok := export.Requested(c)
+	if !ok {
+		return
+	}
+	sortBy := c.DefaultQuery("sort", "sales")
+	sortKey, ok := outletSortKeys[sortBy]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "sort must be sales, profit, gross_profit, ticket, growth or stock_value"})
+		return
+	}
+	interval := c.DefaultQuery("interval", "day")
+
+	settings := h.tenantSettings(tenantID)
+	zone := settings.TimezoneName()
+	first, last, err := localPeriod(req, settings.Location())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	periods, err := splitPeriod(first, last, interval)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	label, _ := periodLabel("created_at", interval)
+	prevFirst, prevLast := previousPeriod(first, last)
+
+	// Recurring expenses may not have been recorded yet today
+	expenses.Generate(h.db, tenantID, expenses.Today(h.db, tenantID))
+
+	var outlets []database.Outlet
+	h.db.Where("tenant_id = ? AND is_active = ?", tenantID, true).Order("name ASC").Find(&outlets)
+
+	current, err := h.outletSales(tenantID, first, last)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch outlet report"})
+		return
+	}
+	previous, err := h.outletSales(tenantID, prevFirst, prevLast)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch outlet report"})
+		return
+	}
+
+	var seriesRows []struct {
+		OutletID     *uuid.UUID
+		Period       string
+		Sales        float64
+		Transactions int
+	}
+	if err := h.db.Model(&database.Transaction{}).
+		Select("outlet_id, "+label+" AS period, COALESCE(SUM(total), 0) AS sales, COUNT(*) AS transactions", zone).
+		Where("tenant_id = ? AND created_at >= ? AND created_at <= ? AND status = ? AND outlet_id IS NOT NULL",
+			tenantID, first, last.AddDate(0, 0, 1).Add(-time.Microsecond), "completed").
+		Group("outlet_id, period").
+		Scan(&seriesRows).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch outlet report"})
+		return
+	}
+	series := make(map[uuid.UUID]map[string]OutletPeriod)
+	for _, row := range seriesRows {
+		key := outletKey(row.OutletID)
+		if series[key] == nil {
+			series[key] = make(map[string]OutletPeriod)
+		}
+		series[key][row.Period] = OutletPeriod{Period: row.Period, Sales: row.Sales, Transactions: row.Transactions}
+	}
+
+	report := OutletReport{
+		StartDate:         first.Format("2006-01-02"),
+		EndDate:           last.Format("2006-01-02"),
+		PreviousStartDate: prevFirst.Format("2006-01-02"),
+		PreviousEndDate:   prevLast.Format("2006-01-02"),
+		Interval:          interval,
+		Timezone:          zone,
+		SortBy:            sortBy,
+		Outlets:           make([]OutletComparison, 0, len(outlets)),
+	}
+	for _, period := range periods {
+		report.Periods = append(report.Periods, seriesLabel(period[0], interval))
+	}
+
+	// Fill in an outlet's or the whole business's figures
+	fill := func(o *OutletComparison, now, before OutletPeriod, pl ProfitLoss) {
+		o.Sales, o.Transactions, o.PreviousSales = now.Sales, now.Transactions, before.Sales
+		if o.Transactions > 0 {
+			o.AverageTicket = o.Sales / float64(o.Transactions)
+		}
+		o.Growth = change(o.Sales, o.PreviousSales)
+		o.NetSales, o.GrossProfit, o.NetProfit, o.GrossMargin = pl.NetSales, pl.GrossProfit, pl.NetProfit, pl.GrossMargin
+	}
+
+	method := costing.LoadMethod(h.db, tenantID)
+	var total, totalBefore OutletPeriod
+	for _, sales := range current {
+		total.Sales += sales.Sales
+		total.Transactions += sales.Transactions
+	}
+	for _, sales := range previous {
+		totalBefore.Sales += sales.Sales
+	}
+
+	var assignedStock float64
+	for _, outlet := range outlets {
+		id := outlet.ID
+		o := OutletComparison{OutletID: &id, Name: outlet.Name, Series: make([]OutletPeriod, 0, len(periods))}
+		fill(&o, current[id], previous[id], h.profitLoss(tenantID, id.String(), first, last))
+		if total.Sales > 0 {
+			o.SalesShare = o.Sales / total.Sales * 100
+		}
+		o.StockValue = costing.Valuation(h.db, tenantID, method, costing.ItemProduct, id.String()) +
+			costing.Valuation(h.db, tenantID, method, costing.ItemMaterial, id.String())
+		assignedStock += o.StockValue
+		for _, period := range report.Periods {
+			point := series[id][period]
+			point.Period = period
+			o.Series = append(o.Series, point)
+		}
+		report.Outlets = append(report.Outlets, o)
+	}
+
+	report.Total = OutletComparison{Name: "Semua Outlet"}
+	fill(&report.Total, total, totalBefore, h.profitLoss(tenantID, "", first, last))
+	if total.Sales > 0 {
+		report.Total.SalesShare = 100
+	}
+	report.Total.StockValue = costing.Valuation(h.db, tenantID, method, costing.ItemProduct, "") +
+		costing.Valuation(h.db, tenantID, method, costing.ItemMaterial, "")
+	report.UnassignedStockValue = math.Max(report.Total.StockValue-assignedStock, 0)
+
+	sort.SliceStable(report.Outlets, func(i, j int) bool {
+		return sortKey(report.Outlets[i]) > sortKey(report.Outlets[j])
+	})
+	for i := range report.Outlets {
+		report.Outlets[i].Rank = i + 1
+	}
+
+	if format != "" {
+		w, err := export.Start(c, format, "perbandingan_outlet_"+report.StartDate+"_"+report.EndDate,
+			"Perbandingan Outlet "+report.StartDate+" s/d "+report.EndDate, []export.Column{
+				{Header: "#", Width: 0.3}, {Header: "Outlet", Width: 1.5}, {Header: "Penjualan"},
+				{Header: "Transaksi", Width: 0.7}, {Header: "Rata-rata"}, {Header: "Pertumbuhan %", Width: 0.8},
+				{Header: "Laba Kotor"}, {Header: "Laba Bersih"}, {Header: "Nilai Stok"},
+			})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate file"})
+			return
+		}
+		for _, o := range append(report.Outlets, report.Total) {
+			var rank, growth interface{}
+			if o.Rank > 0 {
+				rank = o.Rank
+			}
+			if o.Growth.Percent != nil {
+				growth = math.Round(*o.Growth.Percent*10) / 10
+			}
+			w.Row(rank, o.Name, o.Sales, o.Transactions, o.AverageTicket, growth, o.GrossProfit, o.NetProfit, o.StockValue)
+		}
+		w.Close()
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": report})
+}
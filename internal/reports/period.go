@@ -0,0 +1,106 @@
+package reports
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/yuditriaji/warungin-backend/pkg/database"
+)
+
+// maxPeriods bounds an ?interval breakdown
+const maxPeriods = 92
+
+// tenantSettings returns the tenant's settings
+func (h *Handler) tenantSettings(tenantID string) database.TenantSettings {
+	var tenant database.Tenant
+	h.db.Select("settings").Where("id = ?", tenantID).First(&tenant)
+	var settings database.TenantSettings
+	if tenant.Settings != "" && tenant.Settings != "{}" {
+		json.Unmarshal([]byte(tenant.Settings), &settings)
+	}
+	return settings
+}
+
+// tenantLocation returns the tenant's time zone
+func (h *Handler) tenantLocation(tenantID string) *time.Location {
+	return h.tenantSettings(tenantID).Location()
+}
+
+// localPeriod returns the first and last day of the report period in loc,
+// defaulting to the current month
+func localPeriod(req SalesReportRequest, loc *time.Location) (time.Time, time.Time, error) {
+	now := time.Now().In(loc)
+	first := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, loc)
+	last := first.AddDate(0, 1, -1)
+
+	var err error
+	if req.StartDate != "" {
+		if first, err = time.ParseInLocation("2006-01-02", req.StartDate, loc); err != nil {
+			return first, last, fmt.Errorf("start_date must be YYYY-MM-DD")
+		}
+	}
+	if req.EndDate != "" {
+		if last, err = time.ParseInLocation("2006-01-02", req.EndDate, loc); err != nil {
+			return first, last, fmt.Errorf("end_date must be YYYY-MM-DD")
+		}
+	}
+	if last.Before(first) {
+		return first, last, fmt.Errorf("end_date must not be before start_date")
+	}
+	return first, last, nil
+}
+
+// splitPeriod breaks a period into days, ISO weeks or calendar months,
+// trimming the first and last to the period
+func splitPeriod(first, last time.Time, interval string) ([][2]time.Time, error) {
+	var periods [][2]time.Time
+	for start := first; !start.After(last); {
+		var next time.Time
+		switch interval {
+		case "day":
+			next = start.AddDate(0, 0, 1)
+		case "week":
+			next = start.AddDate(0, 0, 8-isoWeekday(start))
+		case "month":
+			next = time.Date(start.Year(), start.Month()+1, 1, 0, 0, 0, 0, start.Location())
+		default:
+			return nil, fmt.Errorf("interval must be day, week or month")
+		}
+		end := next.AddDate(0, 0, -1)
+		if end.After(last) {
+			end = last
+		}
+		periods = append(periods, [2]time.Time{start, end})
+		if len(periods) > maxPeriods {
+			return nil, fmt.Errorf("too many periods; use a longer interval or a shorter date range")
+		}
+		start = next
+	}
+	return periods, nil
+}
+
+// isoWeekday numbers Monday 1 to Sunday 7
+func isoWeekday(t time.Time) int {
+	if t.Weekday() == time.Sunday {
+		return 7
+	}
+	return int(t.Weekday())
+}
+
+// periodFormats are the to_char patterns that label ?interval periods in SQL
+var periodFormats = map[string]string{
+	"day":   "YYYY-MM-DD",
+	"week":  `IYYY-"W"IW`,
+	"month": "YYYY-MM",
+}
+
+// periodLabel returns SQL labelling column's local date by interval, with
+// the time zone name as its one parameter
+func periodLabel(column, interval string) (string, error) {
+	format, ok := periodFormats[interval]
+	if !ok {
+		return "", fmt.Errorf("interval must be day, week or month")
+	}
+	return fmt.Sprintf("to_char(%s AT TIME ZONE ?, '%s')", column, format), nil
+}
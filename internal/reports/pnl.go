@@ -0,0 +1,215 @@
+package reports
+
+import (
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yuditriaji/warungin-backend/pkg/database"
+	"github.com/yuditriaji/warungin-backend/pkg/expenses"
+	"github.com/yuditriaji/warungin-backend/pkg/middleware"
+)
+
+// ExpenseTotal is one category's expenses in a period
+type ExpenseTotal struct {
+	Category string  `json:"category"`
+	Amount   float64 `json:"amount"`
+}
+
+// ProfitLoss is a profit and loss statement for one period. Sales exclude
+// tax but include service charges. Voided sales count as refunds, since their stock is returned and
+// there is no separate refund flow.
+type ProfitLoss struct {
+	StartDate          string         `json:"start_date"`
+	EndDate            string         `json:"end_date"`
+	GrossSales         float64        `json:"gross_sales"` // Subtotals of completed and voided sales
+	Discounts          float64        `json:"discounts"`
+	Refunds            float64        `json:"refunds"`         // Voided sales after their discounts
+	ServiceCharges     float64        `json:"service_charges"` // On completed sales
+	NetSales           float64        `json:"net_sales"`
+	COGS               float64        `json:"cogs"`
+	Waste              float64        `json:"waste"`
+	GrossProfit        float64        `json:"gross_profit"`
+	Expenses           float64        `json:"expenses"`
+	ExpensesByCategory []ExpenseTotal `json:"expenses_by_category"`
+	NetProfit          float64        `json:"net_profit"`
+	GrossMargin        float64        `json:"gross_margin"` // Percent of net sales
+	NetMargin          float64        `json:"net_margin"`
+	Transactions       int            `json:"transactions"` // Completed sales
+}
+
+// Change compares a figure with the previous period
+type Change struct {
+	Amount  float64  `json:"amount"`
+	Percent *float64 `json:"percent"` // nil when the previous figure was zero
+}
+
+type ProfitLossReport struct {
+	ProfitLoss
+	Previous ProfitLoss        `json:"previous"`
+	Change   map[string]Change `json:"change"`
+	Periods  []ProfitLoss      `json:"periods,omitempty"` // Set with ?interval
+}
+
+// previousPeriod returns the period of the same length just before first.
+// Whole months compare with the same number of months before them.
+func previousPeriod(first, last time.Time) (time.Time, time.Time) {
+	dayBefore := first.AddDate(0, 0, -1)
+	if first.Day() == 1 && last.AddDate(0, 0, 1).Day() == 1 {
+		months := (last.Year()-first.Year())*12 + int(last.Month()-first.Month()) + 1
+		return first.AddDate(0, -months, 0), dayBefore
+	}
+	days := int(math.Round(last.Sub(first).Hours()/24)) + 1
+	return first.AddDate(0, 0, -days), dayBefore
+}
+
+// profitLoss builds the statement for the days first to last in the tenant's
+// time zone. With an outlet only that outlet's expenses count; costs of the
+// whole business are not shared out between outlets.
+func (h *Handler) profitLoss(tenantID, outletID string, first, last time.Time) ProfitLoss {
+	pl := ProfitLoss{
+		StartDate:          first.Format("2006-01-02"),
+		EndDate:            last.Format("2006-01-02"),
+		ExpensesByCategory: []ExpenseTotal{},
+	}
+	// Timestamps are compared to the last microsecond of the last day
+	startTime := first
+	endTime := last.AddDate(0, 0, 1).Add(-time.Microsecond)
+
+	var sales struct {
+		GrossSales     float64
+		Discounts      float64
+		Refunds        float64
+		ServiceCharges float64
+		Transactions   int64
+	}
+	salesQuery := h.db.Model(&database.Transaction{}).
+		Select(`COALESCE(SUM(subtotal), 0) AS gross_sales,
+			COALESCE(SUM(discount), 0) AS discounts,
+			COALESCE(SUM(CASE WHEN status = 'voided' THEN subtotal - discount ELSE 0 END), 0) AS refunds,
+			COALESCE(SUM(CASE WHEN status = 'completed' THEN service_charge ELSE 0 END), 0) AS service_charges,
+			COUNT(CASE WHEN status = 'completed' THEN 1 END) AS transactions`).
+		Where("tenant_id = ? AND created_at >= ? AND created_at <= ? AND status IN ?",
+			tenantID, startTime, endTime, []string{"completed", "voided"})
+	if outletID != "" {
+		salesQuery = salesQuery.Where("outlet_id = ?", outletID)
+	}
+	salesQuery.Scan(&sales)
+
+	pl.GrossSales = sales.GrossSales
+	pl.Discounts = sales.Discounts
+	pl.Refunds = sales.Refunds
+	pl.ServiceCharges = sales.ServiceCharges
+	pl.NetSales = pl.GrossSales - pl.Discounts - pl.Refunds + pl.ServiceCharges
+	pl.Transactions = int(sales.Transactions)
+
+	pl.COGS = h.calculateTotalCOGS(tenantID, startTime, endTime, SalesReportRequest{OutletID: outletID})
+
+	wasteQuery := h.db.Model(&database.WasteRecord{}).
+		Select("COALESCE(SUM(total_cost), 0)").
+		Where("tenant_id = ? AND created_at >= ? AND created_at <= ?", tenantID, startTime, endTime)
+	if outletID != "" {
+		wasteQuery = wasteQuery.Where("outlet_id = ?", outletID)
+	}
+	wasteQuery.Scan(&pl.Waste)
+
+	pl.GrossProfit = pl.NetSales - pl.COGS - pl.Waste
+
+	expenseQuery := h.db.Model(&database.Expense{}).
+		Select("category, COALESCE(SUM(amount), 0) AS amount").
+		Where("tenant_id = ? AND date >= ? AND date <= ?", tenantID, pl.StartDate, pl.EndDate)
+	if outletID != "" {
+		expenseQuery = expenseQuery.Where("outlet_id = ?", outletID)
+	}
+	expenseQuery.Group("category").Order("amount DESC, category ASC").Scan(&pl.ExpensesByCategory)
+	for _, total := range pl.ExpensesByCategory {
+		pl.Expenses += total.Amount
+	}
+
+	pl.NetProfit = pl.GrossProfit - pl.Expenses
+	if pl.NetSales != 0 {
+		pl.GrossMargin = pl.GrossProfit / pl.NetSales * 100
+		pl.NetMargin = pl.NetProfit / pl.NetSales * 100
+	}
+	return pl
+}
+
+// compare returns the change of each headline figure from previous
+func compare(current, previous ProfitLoss) map[string]Change {
+	figures := map[string][2]float64{
+		"gross_sales":     {current.GrossSales, previous.GrossSales},
+		"discounts":       {current.Discounts, previous.Discounts},
+		"refunds":         {current.Refunds, previous.Refunds},
+		"service_charges": {current.ServiceCharges, previous.ServiceCharges},
+		"net_sales":       {current.NetSales, previous.NetSales},
+		"cogs":            {current.COGS, previous.COGS},
+		"waste":           {current.Waste, previous.Waste},
+		"gross_profit":    {current.GrossProfit, previous.GrossProfit},
+		"expenses":        {current.Expenses, previous.Expenses},
+		"net_profit":      {current.NetProfit, previous.NetProfit},
+	}
+	changes := make(map[string]Change, len(figures))
+	for name, values := range figures {
+		changes[name] = change(values[0], values[1])
+	}
+	return changes
+}
+
+// change compares a figure with its previous value
+func change(current, previous float64) Change {
+	c := Change{Amount: current - previous}
+	if previous != 0 {
+		percent := c.Amount / math.Abs(previous) * 100
+		c.Percent = &percent
+	}
+	return c
+}
+
+// GetProfitLossReport returns revenue, costs, expenses and profit for a
+// period, compared with the period before it (owners and managers only).
+// Query parameters:
+//
+//	start_date, end_date  YYYY-MM-DD in the tenant's time zone, default the current month
+//	outlet_id      one outlet's sales, costs and expenses
+//	interval       day, week or month to also break the period down
+func (h *Handler) GetProfitLossReport(c *gin.Context) {
+	if !middleware.IsManager(c.GetString("role")) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Hanya owner dan manager yang dapat melihat laporan laba rugi"})
+		return
+	}
+	tenantID := c.GetString("tenant_id")
+
+	var req SalesReportRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	first, last, err := localPeriod(req, h.tenantLocation(tenantID))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var periods [][2]time.Time
+	if interval := c.Query("interval"); interval != "" {
+		if periods, err = splitPeriod(first, last, interval); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	// Recurring expenses may not have been recorded yet today
+	expenses.Generate(h.db, tenantID, expenses.Today(h.db, tenantID))
+
+	var report ProfitLossReport
+	report.ProfitLoss = h.profitLoss(tenantID, req.OutletID, first, last)
+	prevFirst, prevLast := previousPeriod(first, last)
+	report.Previous = h.profitLoss(tenantID, req.OutletID, prevFirst, prevLast)
+	report.Change = compare(report.ProfitLoss, report.Previous)
+	for _, period := range periods {
+		report.Periods = append(report.Periods, h.profitLoss(tenantID, req.OutletID, period[0], period[1]))
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": report})
+}
@@ -0,0 +1,75 @@
+package reports
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/yuditriaji/warungin-backend/pkg/database"
+	"github.com/yuditriaji/warungin-backend/pkg/email"
+	"gorm.io/gorm"
+)
+
+// Scheduler emails subscribed reports as they fall due
+type Scheduler struct {
+	db *gorm.DB
+	h  *Handler
+}
+
+// NewScheduler creates a new report email scheduler
+func NewScheduler(db *gorm.DB) *Scheduler {
+	return &Scheduler{db: db, h: NewHandler(db)}
+}
+
+// Start begins the scheduler loop (runs every 15 minutes, so reports go out
+// soon after each subscription's send time)
+func (s *Scheduler) Start() {
+	ticker := time.NewTicker(15 * time.Minute)
+	go func() {
+		// Run immediately on startup
+		s.Run()
+
+		for range ticker.C {
+			s.Run()
+		}
+	}()
+	fmt.Println("Report scheduler started (runs every 15 minutes)")
+}
+
+// Run sends every report that is due. After downtime only the latest missed
+// report of each subscription is sent.
+func (s *Scheduler) Run() {
+	if !email.NewEmailService().IsConfigured() {
+		return
+	}
+
+	now := time.Now()
+	var due []database.ReportSubscription
+	s.db.Where("is_active = ? AND next_run_at <= ?", true, now).Find(&due)
+
+	sent := 0
+	for _, sub := range due {
+		loc := s.h.subscriptionLocation(sub)
+		next := nextRun(sub, now, loc)
+		end := shiftPeriod(sub.Frequency, next, -1)
+
+		// Claim the run so another instance doesn't send it too
+		claim := s.db.Model(&database.ReportSubscription{}).
+			Where("id = ? AND next_run_at = ?", sub.ID, sub.NextRunAt).
+			Update("next_run_at", next)
+		if claim.Error != nil || claim.RowsAffected == 0 {
+			continue
+		}
+
+		if err := s.h.sendDigest(sub, end); err != nil {
+			fmt.Printf("Report scheduler: failed to send report %s: %v\n", sub.ID, err)
+			s.db.Model(&database.ReportSubscription{}).Where("id = ?", sub.ID).Update("last_error", err.Error())
+			continue
+		}
+		s.db.Model(&database.ReportSubscription{}).Where("id = ?", sub.ID).
+			Updates(map[string]interface{}{"last_sent_at": now, "last_error": ""})
+		sent++
+	}
+	if sent > 0 {
+		fmt.Printf("Report scheduler: sent %d reports\n", sent)
+	}
+}
@@ -0,0 +1,243 @@
+package reports
+
+import (
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/yuditriaji/warungin-backend/pkg/database"
+	"github.com/yuditriaji/warungin-backend/pkg/export"
+	"github.com/yuditriaji/warungin-backend/pkg/middleware"
+)
+
+// StaffPerformance is one user's sales and corrections in a period
+type StaffPerformance struct {
+	UserID         uuid.UUID `json:"user_id"`
+	Name           string    `json:"name"`
+	Role           string    `json:"role"`
+	Sales          float64   `json:"sales"`
+	Transactions   int       `json:"transactions"`
+	AverageTicket  float64   `json:"average_ticket"`
+	Discounts      float64   `json:"discounts"`
+	CashSales      float64   `json:"cash_sales"`    // Cash expected from completed sales
+	CashCounts     int       `json:"cash_counts"`   // Shifts closed with a cash count
+	ExpectedCash   float64   `json:"expected_cash"` // Of the counted shifts, opening floats included
+	CountedCash    float64   `json:"counted_cash"`
+	CashVariance   float64   `json:"cash_variance"` // Expected minus counted; positive = cash short
+	SalesShare     float64   `json:"sales_share"`   // Percent of the period's sales
+	VoidedSales    int       `json:"voided_sales"`  // The user's sales voided in the period
+	VoidedAmount   float64   `json:"voided_amount"`
+	VoidsPerformed int       `json:"voids_performed"` // Voids the user carried out, of anyone's sales
+	VoidsAmount    float64   `json:"voids_amount"`
+	Refunds        int       `json:"refunds"` // Refunds the user carried out
+	RefundAmount   float64   `json:"refund_amount"`
+	VoidRate       float64   `json:"void_rate"` // Voided sales per 100 sales
+}
+
+// staffAudit counts audit log entries by action
+type staffAudit struct {
+	UserID uuid.UUID
+	Action string
+	Count  int
+	Amount float64
+}
+
+// GetStaffReport compares users' sales, discounts, cash variance, voids and
+// refunds in a period (owners and managers only). Voids and refunds are
+// dated by the audit log, cash counts by when the shift closed. Query
+// parameters:
+//
+//	start_date, end_date  YYYY-MM-DD in the tenant's time zone, default the current month
+//	outlet_id      one outlet's sales
+//	format         csv, xlsx or pdf to download the report
+func (h *Handler) GetStaffReport(c *gin.Context) {
+	if !middleware.IsManager(c.GetString("role")) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Hanya manager dan owner yang dapat melihat laporan kinerja staff"})
+		return
+	}
+	tenantID := c.GetString("tenant_id")
+
+	var req SalesReportRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	format, ok := export.Requested(c)
+	if !ok {
+		return
+	}
+	first, last, err := localPeriod(req, h.tenantLocation(tenantID))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	startTime := first
+	endTime := last.AddDate(0, 0, 1).Add(-time.Microsecond)
+
+	byUser := make(map[uuid.UUID]*StaffPerformance)
+	staff := func(userID uuid.UUID) *StaffPerformance {
+		if s, ok := byUser[userID]; ok {
+			return s
+		}
+		s := &StaffPerformance{UserID: userID}
+		byUser[userID] = s
+		return s
+	}
+
+	var sales []struct {
+		UserID       uuid.UUID
+		Sales        float64
+		Transactions int
+		Discounts    float64
+		CashSales    float64
+	}
+	salesQuery := h.db.Model(&database.Transaction{}).
+		Select(`user_id, COALESCE(SUM(total), 0) AS sales, COUNT(*) AS transactions, COALESCE(SUM(discount), 0) AS discounts,
+			COALESCE(SUM(CASE WHEN payment_method = 'cash' THEN total ELSE 0 END), 0) AS cash_sales`).
+		Where("tenant_id = ? AND created_at >= ? AND created_at <= ? AND status = ?", tenantID, startTime, endTime, "completed")
+	if req.OutletID != "" {
+		salesQuery = salesQuery.Where("outlet_id = ?", req.OutletID)
+	}
+	if err := salesQuery.Group("user_id").Scan(&sales).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch staff report"})
+		return
+	}
+	var totalSales float64
+	for _, row := range sales {
+		s := staff(row.UserID)
+		s.Sales, s.Transactions, s.Discounts, s.CashSales = row.Sales, row.Transactions, row.Discounts, row.CashSales
+		totalSales += row.Sales
+	}
+
+	// Drawer counts of shifts that closed in the period
+	var counts []struct {
+		UserID       uuid.UUID
+		CashCounts   int
+		ExpectedCash float64
+		CountedCash  float64
+		CashVariance float64
+	}
+	countQuery := h.db.Model(&database.CashCount{}).
+		Select(`user_id, COUNT(*) AS cash_counts, COALESCE(SUM(expected_cash), 0) AS expected_cash,
+			COALESCE(SUM(counted_cash), 0) AS counted_cash, COALESCE(SUM(variance), 0) AS cash_variance`).
+		Where("tenant_id = ? AND closed_at >= ? AND closed_at <= ?", tenantID, startTime, endTime)
+	if req.OutletID != "" {
+		countQuery = countQuery.Where("outlet_id = ?", req.OutletID)
+	}
+	if err := countQuery.Group("user_id").Scan(&counts).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch staff report"})
+		return
+	}
+	for _, row := range counts {
+		s := staff(row.UserID)
+		s.CashCounts, s.ExpectedCash, s.CountedCash, s.CashVariance = row.CashCounts, row.ExpectedCash, row.CountedCash, row.CashVariance
+	}
+
+	// Audit entries, each with the sale it corrected
+	audits := func(userColumn string) ([]staffAudit, error) {
+		var rows []staffAudit
+		query := h.db.Model(&database.TransactionAuditLog{}).
+			Select(userColumn+" AS user_id, transaction_audit_logs.action, COUNT(*) AS count, COALESCE(SUM(transactions.total), 0) AS amount").
+			Joins("JOIN transactions ON transactions.id = transaction_audit_logs.transaction_id").
+			Where("transaction_audit_logs.tenant_id = ? AND transaction_audit_logs.created_at >= ? AND transaction_audit_logs.created_at <= ? AND transaction_audit_logs.action IN ?",
+				tenantID, startTime, endTime, []string{"void", "refund"})
+		if req.OutletID != "" {
+			query = query.Where("transactions.outlet_id = ?", req.OutletID)
+		}
+		err := query.Group(userColumn + ", transaction_audit_logs.action").Scan(&rows).Error
+		return rows, err
+	}
+
+	performed, err := audits("transaction_audit_logs.user_id")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch staff report"})
+		return
+	}
+	for _, row := range performed {
+		s := staff(row.UserID)
+		switch row.Action {
+		case "void":
+			s.VoidsPerformed, s.VoidsAmount = row.Count, row.Amount
+		case "refund":
+			s.Refunds, s.RefundAmount = row.Count, row.Amount
+		}
+	}
+
+	// Voids are also held against whoever made the sale
+	voided, err := audits("transactions.user_id")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch staff report"})
+		return
+	}
+	for _, row := range voided {
+		if row.Action == "void" {
+			s := staff(row.UserID)
+			s.VoidedSales, s.VoidedAmount = row.Count, row.Amount
+		}
+	}
+
+	// Names of everyone in the report, including staff since removed
+	ids := make([]uuid.UUID, 0, len(byUser))
+	for id := range byUser {
+		ids = append(ids, id)
+	}
+	var users []database.User
+	if len(ids) > 0 {
+		h.db.Unscoped().Where("id IN ?", ids).Find(&users)
+	}
+	for _, user := range users {
+		s := byUser[user.ID]
+		s.Name, s.Role = user.Name, user.Role
+	}
+
+	report := make([]StaffPerformance, 0, len(byUser))
+	for _, s := range byUser {
+		if s.Transactions > 0 {
+			s.AverageTicket = s.Sales / float64(s.Transactions)
+		}
+		if totalSales > 0 {
+			s.SalesShare = s.Sales / totalSales * 100
+		}
+		// Completed sales plus voided ones, so the rate stays within 100
+		if made := s.Transactions + s.VoidedSales; made > 0 {
+			s.VoidRate = float64(s.VoidedSales) / float64(made) * 100
+		}
+		report = append(report, *s)
+	}
+	sort.Slice(report, func(i, j int) bool {
+		if report[i].Sales != report[j].Sales {
+			return report[i].Sales > report[j].Sales
+		}
+		return report[i].Name < report[j].Name
+	})
+
+	startDate, endDate := first.Format("2006-01-02"), last.Format("2006-01-02")
+	if format != "" {
+		w, err := export.Start(c, format, "kinerja_staff_"+startDate+"_"+endDate,
+			"Kinerja Staff "+startDate+" s/d "+endDate, []export.Column{
+				{Header: "Nama", Width: 1.5}, {Header: "Peran", Width: 0.8}, {Header: "Penjualan"},
+				{Header: "Transaksi", Width: 0.7}, {Header: "Rata-rata"}, {Header: "Diskon"}, {Header: "Kas Tunai"},
+				{Header: "Hitung Kas", Width: 0.6}, {Header: "Selisih Kas"},
+				{Header: "Penjualan Di-void", Width: 0.8}, {Header: "Nilai Di-void"}, {Header: "Void Dilakukan", Width: 0.8},
+				{Header: "Refund", Width: 0.6}, {Header: "Nilai Refund"},
+			})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate file"})
+			return
+		}
+		for _, s := range report {
+			w.Row(s.Name, s.Role, s.Sales, s.Transactions, s.AverageTicket, s.Discounts, s.CashSales, s.CashCounts, s.CashVariance,
+				s.VoidedSales, s.VoidedAmount, s.VoidsPerformed, s.Refunds, s.RefundAmount)
+		}
+		w.Close()
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":       report,
+		"start_date": startDate,
+		"end_date":   endDate,
+	})
+}
@@ -0,0 +1,286 @@
+package reports
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/yuditriaji/warungin-backend/pkg/database"
+	"github.com/yuditriaji/warungin-backend/pkg/export"
+	"github.com/yuditriaji/warungin-backend/pkg/middleware"
+)
+
+// Report subscription frequencies
+const (
+	Daily   = "daily"
+	Weekly  = "weekly"
+	Monthly = "monthly"
+)
+
+// defaultSendTime is when reports go out unless the user picks a time
+const defaultSendTime = "22:00"
+
+// SubscriptionRequest creates or changes a report subscription
+type SubscriptionRequest struct {
+	OutletID   *uuid.UUID `json:"outlet_id"`
+	Frequency  string     `json:"frequency" binding:"required"`
+	SendTime   string     `json:"send_time"`  // HH:MM, default 22:00
+	Weekday    int        `json:"weekday"`    // Weekly: 1 = Monday ... 7 = Sunday, default Sunday
+	MonthDay   int        `json:"month_day"`  // Monthly: 1-28, default 1
+	Timezone   string     `json:"timezone"`   // Default the tenant's
+	Attachment string     `json:"attachment"` // csv, xlsx or pdf
+	IsActive   *bool      `json:"is_active"`
+}
+
+// shiftPeriod moves t by n days, weeks or months
+func shiftPeriod(frequency string, t time.Time, n int) time.Time {
+	switch frequency {
+	case Weekly:
+		return t.AddDate(0, 0, 7*n)
+	case Monthly:
+		return t.AddDate(0, n, 0)
+	default:
+		return t.AddDate(0, 0, n)
+	}
+}
+
+// nextRun returns the subscription's first send time after after. The
+// report sent then covers the period since the send time before it.
+func nextRun(sub database.ReportSubscription, after time.Time, loc *time.Location) time.Time {
+	clock, _ := time.Parse("15:04", sub.SendTime)
+	t := after.In(loc)
+	run := time.Date(t.Year(), t.Month(), t.Day(), clock.Hour(), clock.Minute(), 0, 0, loc)
+	switch sub.Frequency {
+	case Weekly:
+		run = run.AddDate(0, 0, (sub.Weekday-isoWeekday(run)+7)%7)
+	case Monthly:
+		run = time.Date(t.Year(), t.Month(), sub.MonthDay, clock.Hour(), clock.Minute(), 0, 0, loc)
+	}
+	for !run.After(after) {
+		run = shiftPeriod(sub.Frequency, run, 1)
+	}
+	return run
+}
+
+// subscriptionLocation returns the time zone a subscription is scheduled in
+func (h *Handler) subscriptionLocation(sub database.ReportSubscription) *time.Location {
+	if sub.Timezone != "" {
+		if loc, err := time.LoadLocation(sub.Timezone); err == nil {
+			return loc
+		}
+	}
+	return h.tenantLocation(sub.TenantID.String())
+}
+
+// validateSubscription fills in defaults and checks a request, returning an
+// error message
+func (h *Handler) validateSubscription(tenantID string, req *SubscriptionRequest) string {
+	switch req.Frequency {
+	case Daily:
+	case Weekly:
+		if req.Weekday == 0 {
+			req.Weekday = 7
+		}
+		if req.Weekday < 1 || req.Weekday > 7 {
+			return "weekday must be 1 (Monday) to 7 (Sunday)"
+		}
+	case Monthly:
+		if req.MonthDay == 0 {
+			req.MonthDay = 1
+		}
+		if req.MonthDay < 1 || req.MonthDay > 28 {
+			return "month_day must be 1 to 28"
+		}
+	default:
+		return "frequency must be daily, weekly or monthly"
+	}
+	if req.Frequency != Weekly {
+		req.Weekday = 0
+	}
+	if req.Frequency != Monthly {
+		req.MonthDay = 0
+	}
+
+	if req.SendTime == "" {
+		req.SendTime = defaultSendTime
+	}
+	if _, err := time.Parse("15:04", req.SendTime); err != nil {
+		return "send_time must be HH:MM"
+	}
+	if req.Timezone != "" {
+		if _, err := time.LoadLocation(req.Timezone); err != nil {
+			return "Unknown timezone"
+		}
+	}
+	if req.Attachment != "" && !export.Valid(req.Attachment) {
+		return "attachment must be csv, xlsx or pdf"
+	}
+	if req.OutletID != nil {
+		var count int64
+		h.db.Model(&database.Outlet{}).Where("id = ? AND tenant_id = ?", *req.OutletID, tenantID).Count(&count)
+		if count == 0 {
+			return "Outlet not found"
+		}
+	}
+	return ""
+}
+
+// applySubscription copies a validated request onto sub and schedules its
+// next report
+func (h *Handler) applySubscription(sub *database.ReportSubscription, req SubscriptionRequest) {
+	sub.OutletID = req.OutletID
+	sub.Frequency = req.Frequency
+	sub.SendTime = req.SendTime
+	sub.Weekday = req.Weekday
+	sub.MonthDay = req.MonthDay
+	sub.Timezone = req.Timezone
+	sub.Attachment = req.Attachment
+	if req.IsActive != nil {
+		sub.IsActive = *req.IsActive
+	}
+	sub.NextRunAt = nextRun(*sub, time.Now(), h.subscriptionLocation(*sub))
+}
+
+// ownSubscription loads one of the user's subscriptions, replying 404 if
+// there is none
+func (h *Handler) ownSubscription(c *gin.Context) (database.ReportSubscription, bool) {
+	var sub database.ReportSubscription
+	err := h.db.Where("id = ? AND tenant_id = ? AND user_id = ?",
+		c.Param("id"), c.GetString("tenant_id"), c.GetString("user_id")).
+		Preload("Outlet").
+		First(&sub).Error
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Report subscription not found"})
+		return sub, false
+	}
+	return sub, true
+}
+
+// subscriptionAllowed replies 403 unless the user may receive reports
+func subscriptionAllowed(c *gin.Context) bool {
+	if !middleware.IsManager(c.GetString("role")) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Hanya owner dan manager yang dapat berlangganan laporan"})
+		return false
+	}
+	return true
+}
+
+// ListSubscriptions returns the user's report subscriptions
+func (h *Handler) ListSubscriptions(c *gin.Context) {
+	if !subscriptionAllowed(c) {
+		return
+	}
+
+	subs := []database.ReportSubscription{}
+	if err := h.db.Where("tenant_id = ? AND user_id = ?", c.GetString("tenant_id"), c.GetString("user_id")).
+		Preload("Outlet").
+		Order("created_at ASC").
+		Find(&subs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch report subscriptions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": subs})
+}
+
+// CreateSubscription subscribes the user to a daily, weekly or monthly
+// summary email
+func (h *Handler) CreateSubscription(c *gin.Context) {
+	if !subscriptionAllowed(c) {
+		return
+	}
+	tenantID := c.GetString("tenant_id")
+	tenantUUID, _ := uuid.Parse(tenantID)
+	userUUID, _ := uuid.Parse(c.GetString("user_id"))
+
+	var req SubscriptionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if msg := h.validateSubscription(tenantID, &req); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	sub := database.ReportSubscription{TenantID: tenantUUID, UserID: userUUID, IsActive: true}
+	h.applySubscription(&sub, req)
+	if err := h.db.Create(&sub).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create report subscription"})
+		return
+	}
+
+	h.db.Preload("Outlet").First(&sub, sub.ID)
+	c.JSON(http.StatusCreated, gin.H{"data": sub})
+}
+
+// UpdateSubscription changes a subscription's schedule, outlet or attachment
+func (h *Handler) UpdateSubscription(c *gin.Context) {
+	if !subscriptionAllowed(c) {
+		return
+	}
+	sub, ok := h.ownSubscription(c)
+	if !ok {
+		return
+	}
+
+	var req SubscriptionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if msg := h.validateSubscription(c.GetString("tenant_id"), &req); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	h.applySubscription(&sub, req)
+	sub.Outlet = nil
+	if err := h.db.Save(&sub).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update report subscription"})
+		return
+	}
+
+	h.db.Preload("Outlet").First(&sub, sub.ID)
+	c.JSON(http.StatusOK, gin.H{"data": sub})
+}
+
+// DeleteSubscription stops a subscription
+func (h *Handler) DeleteSubscription(c *gin.Context) {
+	if !subscriptionAllowed(c) {
+		return
+	}
+	sub, ok := h.ownSubscription(c)
+	if !ok {
+		return
+	}
+
+	if err := h.db.Delete(&sub).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete report subscription"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Report subscription deleted"})
+}
+
+// SendSubscription emails the subscription's most recent report now, e.g.
+// to check it. The schedule is unchanged.
+func (h *Handler) SendSubscription(c *gin.Context) {
+	if !subscriptionAllowed(c) {
+		return
+	}
+	sub, ok := h.ownSubscription(c)
+	if !ok {
+		return
+	}
+
+	loc := h.subscriptionLocation(sub)
+	end := shiftPeriod(sub.Frequency, nextRun(sub, time.Now(), loc), -1)
+	if err := h.sendDigest(sub, end); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send report: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Report sent"})
+}
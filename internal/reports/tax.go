@@ -0,0 +1,377 @@
+package reports
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yuditriaji/warungin-backend/pkg/database"
+	"github.com/yuditriaji/warungin-backend/pkg/export"
+	"gorm.io/gorm"
+)
+
+// TaxLine is the tax at one rate in one period. Sales count in the period
+// they were made and voids in the period they were voided, so a closed
+// month never changes.
+type TaxLine struct {
+	Period       string  `json:"period,omitempty"`
+	TaxRate      float64 `json:"tax_rate"`
+	Transactions int     `json:"transactions"`
+	TaxBase      float64 `json:"tax_base"` // DPP of sales made
+	Tax          float64 `json:"tax"`
+	VoidedBase   float64 `json:"voided_base"` // DPP of sales voided
+	VoidedTax    float64 `json:"voided_tax"`
+	NetBase      float64 `json:"net_base"`
+	NetTax       float64 `json:"net_tax"`
+}
+
+// ServiceChargeLine is the service charge collected in one period
+type ServiceChargeLine struct {
+	Period        string  `json:"period"`
+	ServiceCharge float64 `json:"service_charge"`
+	Voided        float64 `json:"voided"`
+	Net           float64 `json:"net"`
+}
+
+type TaxReport struct {
+	StartDate        string              `json:"start_date"`
+	EndDate          string              `json:"end_date"`
+	Interval         string              `json:"interval"`
+	Timezone         string              `json:"timezone"`
+	Lines            []TaxLine           `json:"lines"`
+	ByRate           []TaxLine           `json:"by_rate"` // The whole period
+	ServiceCharges   []ServiceChargeLine `json:"service_charges"`
+	NetBase          float64             `json:"net_base"`
+	NetTax           float64             `json:"net_tax"`
+	NetServiceCharge float64             `json:"net_service_charge"`
+}
+
+// TaxInvoice is one sale, or the reversal of a voided one, in the invoice view
+type TaxInvoice struct {
+	Date          time.Time `json:"date"`
+	Entry         string    `json:"entry"` // sale, void
+	InvoiceNumber string    `json:"invoice_number"`
+	OutletName    string    `json:"outlet_name"`
+	TaxBase       float64   `json:"tax_base"`
+	TaxRate       float64   `json:"tax_rate"`
+	Tax           float64   `json:"tax"`
+	ServiceCharge float64   `json:"service_charge"`
+	Total         float64   `json:"total"`
+}
+
+// taxRow is a grouped sum read from the database
+type taxRow struct {
+	Period       string
+	Rate         float64
+	Transactions int
+	Base         float64
+	Tax          float64
+}
+
+// taxPeriod holds a tax report request's period in the tenant's time zone
+type taxPeriod struct {
+	zone      string
+	loc       *time.Location
+	first     time.Time
+	last      time.Time
+	startTime time.Time
+	endTime   time.Time
+	outletID  string
+}
+
+// itemsWithSale returns transaction items joined with their sale
+func (h *Handler) itemsWithSale() *gorm.DB {
+	return h.db.Model(&database.TransactionItem{}).
+		Joins("JOIN transactions ON transaction_items.transaction_id = transactions.id")
+}
+
+// salesIn narrows a query over transactions to sales made in the period,
+// voided or not
+func salesIn(query *gorm.DB, tenantID string, p taxPeriod) *gorm.DB {
+	query = query.Where("transactions.tenant_id = ? AND transactions.created_at >= ? AND transactions.created_at <= ? AND transactions.status IN ?",
+		tenantID, p.startTime, p.endTime, []string{"completed", "voided"})
+	if p.outletID != "" {
+		query = query.Where("transactions.outlet_id = ?", p.outletID)
+	}
+	return query
+}
+
+// voidsIn narrows a query over transactions to sales voided in the period
+func voidsIn(query *gorm.DB, tenantID string, p taxPeriod) *gorm.DB {
+	query = query.
+		Joins("JOIN transaction_audit_logs ON transaction_audit_logs.transaction_id = transactions.id AND transaction_audit_logs.action = 'void'").
+		Where("transactions.tenant_id = ? AND transaction_audit_logs.created_at >= ? AND transaction_audit_logs.created_at <= ?",
+			tenantID, p.startTime, p.endTime)
+	if p.outletID != "" {
+		query = query.Where("transactions.outlet_id = ?", p.outletID)
+	}
+	return query
+}
+
+// sumTax groups items by period and tax rate
+func sumTax(query *gorm.DB, column, interval, zone string) ([]taxRow, error) {
+	label, err := periodLabel(column, interval)
+	if err != nil {
+		return nil, err
+	}
+	var rows []taxRow
+	err = query.Select(label+` AS period, COALESCE(transaction_items.tax_rate, 0) AS rate,
+			COUNT(DISTINCT transactions.id) AS transactions,
+			COALESCE(SUM(transaction_items.subtotal), 0) AS base,
+			COALESCE(SUM(transaction_items.tax_amount), 0) AS tax`, zone).
+		Group("period, rate").
+		Scan(&rows).Error
+	return rows, err
+}
+
+// sumServiceCharge groups transactions' service charge by period
+func sumServiceCharge(query *gorm.DB, column, interval, zone string) (map[string]float64, error) {
+	label, err := periodLabel(column, interval)
+	if err != nil {
+		return nil, err
+	}
+	var rows []struct {
+		Period        string
+		ServiceCharge float64
+	}
+	err = query.Select(label+" AS period, COALESCE(SUM(transactions.service_charge), 0) AS service_charge", zone).
+		Group("period").
+		Scan(&rows).Error
+	totals := make(map[string]float64)
+	for _, row := range rows {
+		totals[row.Period] = row.ServiceCharge
+	}
+	return totals, err
+}
+
+// buildTaxReport nets voids against sales per period and rate
+func (h *Handler) buildTaxReport(tenantID, interval string, p taxPeriod) (TaxReport, error) {
+	report := TaxReport{
+		StartDate:      p.first.Format("2006-01-02"),
+		EndDate:        p.last.Format("2006-01-02"),
+		Interval:       interval,
+		Timezone:       p.zone,
+		Lines:          []TaxLine{},
+		ByRate:         []TaxLine{},
+		ServiceCharges: []ServiceChargeLine{},
+	}
+
+	sales, err := sumTax(salesIn(h.itemsWithSale(), tenantID, p), "transactions.created_at", interval, p.zone)
+	if err != nil {
+		return report, err
+	}
+	voids, err := sumTax(voidsIn(h.itemsWithSale(), tenantID, p), "transaction_audit_logs.created_at", interval, p.zone)
+	if err != nil {
+		return report, err
+	}
+
+	type key struct {
+		period string
+		rate   float64
+	}
+	lines := make(map[key]*TaxLine)
+	line := func(k key) *TaxLine {
+		if lines[k] == nil {
+			lines[k] = &TaxLine{Period: k.period, TaxRate: k.rate}
+		}
+		return lines[k]
+	}
+	for _, row := range sales {
+		l := line(key{row.Period, row.Rate})
+		l.Transactions, l.TaxBase, l.Tax = row.Transactions, row.Base, row.Tax
+	}
+	for _, row := range voids {
+		l := line(key{row.Period, row.Rate})
+		l.VoidedBase, l.VoidedTax = row.Base, row.Tax
+	}
+
+	byRate := make(map[float64]*TaxLine)
+	for _, l := range lines {
+		l.NetBase = l.TaxBase - l.VoidedBase
+		l.NetTax = l.Tax - l.VoidedTax
+		report.Lines = append(report.Lines, *l)
+
+		total := byRate[l.TaxRate]
+		if total == nil {
+			total = &TaxLine{TaxRate: l.TaxRate}
+			byRate[l.TaxRate] = total
+		}
+		total.Transactions += l.Transactions
+		total.TaxBase += l.TaxBase
+		total.Tax += l.Tax
+		total.VoidedBase += l.VoidedBase
+		total.VoidedTax += l.VoidedTax
+		total.NetBase += l.NetBase
+		total.NetTax += l.NetTax
+
+		report.NetBase += l.NetBase
+		report.NetTax += l.NetTax
+	}
+	sort.Slice(report.Lines, func(i, j int) bool {
+		if report.Lines[i].Period != report.Lines[j].Period {
+			return report.Lines[i].Period < report.Lines[j].Period
+		}
+		return report.Lines[i].TaxRate < report.Lines[j].TaxRate
+	})
+	for _, total := range byRate {
+		report.ByRate = append(report.ByRate, *total)
+	}
+	sort.Slice(report.ByRate, func(i, j int) bool { return report.ByRate[i].TaxRate < report.ByRate[j].TaxRate })
+
+	charged, err := sumServiceCharge(salesIn(h.db.Model(&database.Transaction{}), tenantID, p), "transactions.created_at", interval, p.zone)
+	if err != nil {
+		return report, err
+	}
+	voided, err := sumServiceCharge(voidsIn(h.db.Model(&database.Transaction{}), tenantID, p), "transaction_audit_logs.created_at", interval, p.zone)
+	if err != nil {
+		return report, err
+	}
+	periods := make(map[string]bool)
+	for period := range charged {
+		periods[period] = true
+	}
+	for period := range voided {
+		periods[period] = true
+	}
+	for period := range periods {
+		sc := ServiceChargeLine{Period: period, ServiceCharge: charged[period], Voided: voided[period]}
+		sc.Net = sc.ServiceCharge - sc.Voided
+		if sc.ServiceCharge == 0 && sc.Voided == 0 {
+			continue
+		}
+		report.ServiceCharges = append(report.ServiceCharges, sc)
+		report.NetServiceCharge += sc.Net
+	}
+	sort.Slice(report.ServiceCharges, func(i, j int) bool {
+		return report.ServiceCharges[i].Period < report.ServiceCharges[j].Period
+	})
+	return report, nil
+}
+
+// taxInvoices lists every sale in the period and a negative entry for every
+// void, in time order
+func (h *Handler) taxInvoices(tenantID string, p taxPeriod) ([]TaxInvoice, error) {
+	outletCond := ""
+	args := map[string]interface{}{"tenant": tenantID, "start": p.startTime, "end": p.endTime}
+	if p.outletID != "" {
+		outletCond = " AND t.outlet_id = @outlet"
+		args["outlet"] = p.outletID
+	}
+
+	invoices := []TaxInvoice{}
+	err := h.db.Raw(`
+		SELECT t.created_at AS date, 'sale' AS entry, t.invoice_number, COALESCE(o.name, '') AS outlet_name,
+			t.tax_base, t.tax_rate, t.tax, t.service_charge, t.total
+		FROM transactions t LEFT JOIN outlets o ON o.id = t.outlet_id
+		WHERE t.tenant_id = @tenant AND t.created_at >= @start AND t.created_at <= @end
+			AND t.status IN ('completed', 'voided') AND t.deleted_at IS NULL`+outletCond+`
+		UNION ALL
+		SELECT al.created_at, 'void', t.invoice_number, COALESCE(o.name, ''),
+			-t.tax_base, t.tax_rate, -t.tax, -t.service_charge, -t.total
+		FROM transaction_audit_logs al
+		JOIN transactions t ON t.id = al.transaction_id
+		LEFT JOIN outlets o ON o.id = t.outlet_id
+		WHERE al.action = 'void' AND t.tenant_id = @tenant AND al.created_at >= @start AND al.created_at <= @end`+outletCond+`
+		ORDER BY date, invoice_number`, args).
+		Scan(&invoices).Error
+	for i := range invoices {
+		invoices[i].Date = invoices[i].Date.In(p.loc)
+	}
+	return invoices, err
+}
+
+// GetTaxReport returns tax (PPN) and service charge by period and rate for
+// filing, with voids netted. Query parameters:
+//
+//	start_date, end_date  YYYY-MM-DD in the tenant's time zone, default the current month
+//	outlet_id
+//	interval       month (default), week or day
+//	view=invoices  every sale and void instead of totals
+//	format         csv, xlsx or pdf to download
+func (h *Handler) GetTaxReport(c *gin.Context) {
+	tenantID := c.GetString("tenant_id")
+
+	var req SalesReportRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	settings := h.tenantSettings(tenantID)
+	p := taxPeriod{zone: settings.TimezoneName(), loc: settings.Location(), outletID: req.OutletID}
+	var err error
+	if p.first, p.last, err = localPeriod(req, p.loc); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	p.startTime = p.first
+	p.endTime = p.last.AddDate(0, 0, 1).Add(-time.Microsecond)
+
+	format, ok := export.Requested(c)
+	if !ok {
+		return
+	}
+	filename := fmt.Sprintf("pajak_%s_%s", p.first.Format("20060102"), p.last.Format("20060102"))
+	title := fmt.Sprintf("Laporan Pajak %s s/d %s", p.first.Format("2006-01-02"), p.last.Format("2006-01-02"))
+
+	if c.Query("view") == "invoices" {
+		invoices, err := h.taxInvoices(tenantID, p)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build tax report"})
+			return
+		}
+		if format == "" {
+			c.JSON(http.StatusOK, gin.H{"data": invoices})
+			return
+		}
+		w, err := export.Start(c, format, filename+"_faktur", title, []export.Column{
+			{Header: "Tanggal", Width: 1.3}, {Header: "No. Invoice", Width: 1.6}, {Header: "Jenis", Width: 0.6},
+			{Header: "Outlet", Width: 1.2}, {Header: "DPP"}, {Header: "Tarif (%)", Width: 0.6}, {Header: "PPN"},
+			{Header: "Service Charge"}, {Header: "Total"},
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate file"})
+			return
+		}
+		for _, inv := range invoices {
+			if err := w.Row(inv.Date, inv.InvoiceNumber, inv.Entry, inv.OutletName,
+				inv.TaxBase, inv.TaxRate, inv.Tax, inv.ServiceCharge, inv.Total); err != nil {
+				break
+			}
+		}
+		w.Close()
+		return
+	}
+
+	interval := c.DefaultQuery("interval", "month")
+	report, err := h.buildTaxReport(tenantID, interval, p)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if format == "" {
+		c.JSON(http.StatusOK, gin.H{"data": report})
+		return
+	}
+
+	w, err := export.Start(c, format, filename, title, []export.Column{
+		{Header: "Masa"}, {Header: "Tarif (%)", Width: 0.7}, {Header: "Transaksi", Width: 0.7},
+		{Header: "DPP Penjualan"}, {Header: "PPN Penjualan"}, {Header: "DPP Batal"}, {Header: "PPN Batal"},
+		{Header: "DPP Bersih"}, {Header: "PPN Bersih"},
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate file"})
+		return
+	}
+	for _, l := range report.Lines {
+		w.Row(l.Period, l.TaxRate, l.Transactions, l.TaxBase, l.Tax, l.VoidedBase, l.VoidedTax, l.NetBase, l.NetTax)
+	}
+	for _, l := range report.ByRate {
+		w.Row("Total", l.TaxRate, l.Transactions, l.TaxBase, l.Tax, l.VoidedBase, l.VoidedTax, l.NetBase, l.NetTax)
+	}
+	for _, sc := range report.ServiceCharges {
+		w.Row(sc.Period, "Service Charge", nil, nil, nil, nil, nil, sc.Net, nil)
+	}
+	w.Close()
+}
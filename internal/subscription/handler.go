@@ -161,7 +161,7 @@ func (h *Handler) GetUsage(c *gin.Context) {
 	h.db.Model(&database.User{}).Where("tenant_id = ?", tenantID).Count(&userCount)
 
 	var productCount int64
-	h.db.Model(&database.Product{}).Where("tenant_id = ? AND is_active = ?", tenantID, true).Count(&productCount)
+	h.db.Model(&database.Product{}).Where("tenant_id = ? AND is_active = ? AND parent_id IS NULL", tenantID, true).Count(&productCount)
 
 	// Today's transactions
 	today := time.Now().Truncate(24 * time.Hour)
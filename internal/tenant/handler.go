@@ -1,24 +1,25 @@
 package tenant
 
 import (
-	"encoding/base64"
 	"encoding/json"
-	"io"
 	"net/http"
-	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/yuditriaji/warungin-backend/pkg/costing"
 	"github.com/yuditriaji/warungin-backend/pkg/database"
+	"github.com/yuditriaji/warungin-backend/pkg/storage"
 	"gorm.io/gorm"
 )
 
 type Handler struct {
-	db *gorm.DB
+	db    *gorm.DB
+	store storage.Storage
 }
 
-func NewHandler(db *gorm.DB) *Handler {
-	return &Handler{db: db}
+func NewHandler(db *gorm.DB, store storage.Storage) *Handler {
+	return &Handler{db: db, store: store}
 }
 
 // GetSettings returns the tenant's settings
@@ -52,6 +53,8 @@ type UpdateSettingsRequest struct {
 	ServiceChargeEnabled *bool    `json:"service_charge_enabled"`
 	ServiceChargeRate    *float64 `json:"service_charge_rate"`
 	ServiceChargeLabel   *string  `json:"service_charge_label"`
+	CostingMethod        *string  `json:"costing_method"`
+	Timezone             *string  `json:"timezone"`
 }
 
 // UpdateSettings updates the tenant's settings
@@ -80,8 +83,13 @@ func (h *Handler) UpdateSettings(c *gin.Context) {
 	if req.QRISEnabled != nil {
 		settings.QRISEnabled = *req.QRISEnabled
 	}
-	if req.QRISImageURL != nil {
+	var replacedKey string
+	if req.QRISImageURL != nil && *req.QRISImageURL != settings.QRISImageURL {
+		// An external image URL replaces any uploaded image
+		replacedKey = settings.QRISImageKey
 		settings.QRISImageURL = *req.QRISImageURL
+		settings.QRISThumbnailURL = ""
+		settings.QRISImageKey = ""
 	}
 	if req.QRISLabel != nil {
 		settings.QRISLabel = *req.QRISLabel
@@ -109,86 +117,37 @@ func (h *Handler) UpdateSettings(c *gin.Context) {
 		settings.ServiceChargeLabel = *req.ServiceChargeLabel
 	}
 
-	// Save settings back to JSON
-	settingsJSON, _ := json.Marshal(settings)
-	tenant.Settings = string(settingsJSON)
-
-	if err := h.db.Save(&tenant).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save settings"})
-		return
-	}
-
-	c.JSON(http.StatusOK, gin.H{
-		"data":    settings,
-		"message": "Settings updated successfully",
-	})
-}
-
-// UploadQRIS handles QRIS image file upload and stores as base64
-func (h *Handler) UploadQRIS(c *gin.Context) {
-	tenantID := c.GetString("tenant_id")
-
-	// Get uploaded file (max 500KB)
-	file, header, err := c.Request.FormFile("qris_image")
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "No file uploaded"})
-		return
-	}
-	defer file.Close()
-
-	// Validate file size (max 500KB)
-	if header.Size > 500*1024 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "File too large. Maximum 500KB allowed"})
-		return
-	}
-
-	// Validate content type
-	contentType := header.Header.Get("Content-Type")
-	if !strings.HasPrefix(contentType, "image/") {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Only image files are allowed"})
-		return
-	}
-
-	// Read file content
-	fileBytes, err := io.ReadAll(file)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read file"})
-		return
-	}
-
-	// Convert to base64 data URI
-	base64Data := base64.StdEncoding.EncodeToString(fileBytes)
-	dataURI := "data:" + contentType + ";base64," + base64Data
-
-	// Get tenant
-	var tenant database.Tenant
-	if err := h.db.Where("id = ?", tenantID).First(&tenant).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Tenant not found"})
-		return
+	// Update inventory costing method if provided
+	if req.CostingMethod != nil {
+		if !costing.ValidMethod(*req.CostingMethod) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "costing_method must be manual, average or fifo"})
+			return
+		}
+		settings.CostingMethod = *req.CostingMethod
 	}
 
-	// Parse existing settings
-	var settings database.TenantSettings
-	if tenant.Settings != "" && tenant.Settings != "{}" {
-		json.Unmarshal([]byte(tenant.Settings), &settings)
+	// Update time zone if provided
+	if req.Timezone != nil {
+		if _, err := time.LoadLocation(*req.Timezone); err != nil || *req.Timezone == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "timezone must be an IANA name like Asia/Jakarta"})
+			return
+		}
+		settings.Timezone = *req.Timezone
 	}
 
-	// Update QRIS image URL with base64 data URI
-	settings.QRISImageURL = dataURI
-	settings.QRISEnabled = true
-
-	// Save settings
+	// Save settings back to JSON
 	settingsJSON, _ := json.Marshal(settings)
 	tenant.Settings = string(settingsJSON)
 
 	if err := h.db.Save(&tenant).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save QRIS image"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save settings"})
 		return
 	}
+	h.removeImage(c.Request.Context(), replacedKey)
 
 	c.JSON(http.StatusOK, gin.H{
 		"data":    settings,
-		"message": "QRIS image uploaded successfully",
+		"message": "Settings updated successfully",
 	})
 }
 
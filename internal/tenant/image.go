@@ -0,0 +1,143 @@
+package tenant
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yuditriaji/warungin-backend/pkg/database"
+	"github.com/yuditriaji/warungin-backend/pkg/images"
+	"github.com/yuditriaji/warungin-backend/pkg/storage"
+)
+
+// removeImage deletes an uploaded image that settings no longer point at
+func (h *Handler) removeImage(ctx context.Context, key string) {
+	if err := images.Remove(ctx, h.store, key); err != nil {
+		log.Printf("Failed to delete image %s: %v", key, err)
+	}
+}
+
+// uploadImage stores the image in a form field under the tenant's folder
+func (h *Handler) uploadImage(c *gin.Context, field, folder string, preset images.Preset) (images.Stored, bool) {
+	header, err := c.FormFile(field)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No file uploaded"})
+		return images.Stored{}, false
+	}
+	data, err := images.Read(header)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return images.Stored{}, false
+	}
+	img, err := images.Process(data, preset)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return images.Stored{}, false
+	}
+
+	stored, err := images.Save(c.Request.Context(), h.store, storage.TenantKey(c.GetString("tenant_id"), folder), img)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store image"})
+		return images.Stored{}, false
+	}
+	return stored, true
+}
+
+// updateSettings applies change to the tenant's settings and saves them
+func (h *Handler) updateSettings(c *gin.Context, change func(*database.TenantSettings)) (database.TenantSettings, bool) {
+	var settings database.TenantSettings
+
+	var tenant database.Tenant
+	if err := h.db.Where("id = ?", c.GetString("tenant_id")).First(&tenant).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Tenant not found"})
+		return settings, false
+	}
+	if tenant.Settings != "" && tenant.Settings != "{}" {
+		json.Unmarshal([]byte(tenant.Settings), &settings)
+	}
+
+	change(&settings)
+
+	settingsJSON, _ := json.Marshal(settings)
+	if err := h.db.Model(&tenant).Update("settings", string(settingsJSON)).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save settings"})
+		return settings, false
+	}
+	return settings, true
+}
+
+// UploadQRIS stores the merchant's static QRIS image (form field
+// "qris_image") and enables QRIS payments
+func (h *Handler) UploadQRIS(c *gin.Context) {
+	stored, ok := h.uploadImage(c, "qris_image", "qris", images.QRIS)
+	if !ok {
+		return
+	}
+
+	var oldKey string
+	settings, ok := h.updateSettings(c, func(s *database.TenantSettings) {
+		oldKey = s.QRISImageKey
+		s.QRISImageURL = stored.URL
+		s.QRISThumbnailURL = stored.ThumbnailURL
+		s.QRISImageKey = stored.Key
+		s.QRISEnabled = true
+	})
+	if !ok {
+		h.removeImage(c.Request.Context(), stored.Key)
+		return
+	}
+	h.removeImage(c.Request.Context(), oldKey)
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":    settings,
+		"message": "QRIS image uploaded successfully",
+	})
+}
+
+// UploadLogo stores the business logo (form field "logo")
+func (h *Handler) UploadLogo(c *gin.Context) {
+	stored, ok := h.uploadImage(c, "logo", "logo", images.Logo)
+	if !ok {
+		return
+	}
+
+	var oldKey string
+	settings, ok := h.updateSettings(c, func(s *database.TenantSettings) {
+		oldKey = s.LogoKey
+		s.LogoURL = stored.URL
+		s.LogoThumbnailURL = stored.ThumbnailURL
+		s.LogoKey = stored.Key
+	})
+	if !ok {
+		h.removeImage(c.Request.Context(), stored.Key)
+		return
+	}
+	h.removeImage(c.Request.Context(), oldKey)
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":    settings,
+		"message": "Logo uploaded successfully",
+	})
+}
+
+// DeleteLogo removes the business logo
+func (h *Handler) DeleteLogo(c *gin.Context) {
+	var oldKey string
+	settings, ok := h.updateSettings(c, func(s *database.TenantSettings) {
+		oldKey = s.LogoKey
+		s.LogoURL = ""
+		s.LogoThumbnailURL = ""
+		s.LogoKey = ""
+	})
+	if !ok {
+		return
+	}
+	h.removeImage(c.Request.Context(), oldKey)
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":    settings,
+		"message": "Logo removed",
+	})
+}
@@ -0,0 +1,107 @@
+package transaction
+
+import (
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/yuditriaji/warungin-backend/pkg/availability"
+	"github.com/yuditriaji/warungin-backend/pkg/costing"
+	"github.com/yuditriaji/warungin-backend/pkg/database"
+	"github.com/yuditriaji/warungin-backend/pkg/recipe"
+	"gorm.io/gorm"
+)
+
+// bundleSale is a bundle line expanded into the components it sold
+type bundleSale struct {
+	unitPrice  float64 // Bundle price plus the surcharges of chosen options
+	unitCost   float64
+	components []database.TransactionItemComponent
+}
+
+// sellBundle checks the item's choices against the bundle's groups and
+// deducts the stock or recipe of every included component. Components must
+// be available just as if they were sold on their own.
+func sellBundle(tx *gorm.DB, ledger *costing.Ledger, calc *recipe.Calculator, avail *availability.Checker, bundle database.Product, item TransactionItemRequest) (bundleSale, error) {
+	var sale bundleSale
+
+	var parts []database.BundleComponent
+	tx.Where("bundle_id = ? AND group_id IS NULL", bundle.ID).Preload("Product").Order("sort_order ASC").Find(&parts)
+
+	var groups []database.BundleGroup
+	tx.Where("bundle_id = ?", bundle.ID).Preload("Options.Product").Order("sort_order ASC").Find(&groups)
+
+	options := make(map[uuid.UUID]database.BundleComponent)
+	for _, group := range groups {
+		for _, option := range group.Options {
+			options[option.ID] = option
+		}
+	}
+
+	// Each entry in Choices is one selection; an option can be picked twice
+	chosen := make(map[uuid.UUID]int)
+	for _, choiceID := range item.Choices {
+		option, ok := options[choiceID]
+		if !ok {
+			return sale, saleError{fmt.Sprintf("Pilihan %s tidak ada di paket %s", choiceID, bundle.Name)}
+		}
+		chosen[*option.GroupID]++
+		parts = append(parts, option)
+		sale.unitPrice += option.PriceDelta
+	}
+	for _, group := range groups {
+		if n := chosen[group.ID]; n < group.MinSelect || n > group.MaxSelect {
+			if group.MinSelect == group.MaxSelect {
+				return sale, saleError{fmt.Sprintf("%s: pilih %d untuk paket %s", group.Name, group.MinSelect, bundle.Name)}
+			}
+			return sale, saleError{fmt.Sprintf("%s: pilih %d sampai %d untuk paket %s", group.Name, group.MinSelect, group.MaxSelect, bundle.Name)}
+		}
+	}
+	if len(parts) == 0 {
+		return sale, saleError{fmt.Sprintf("Paket %s belum memiliki isi", bundle.Name)}
+	}
+	sale.unitPrice += bundle.Price
+
+	// Revenue is split across components by their own prices
+	var weightTotal float64
+	for _, part := range parts {
+		weightTotal += part.Product.Price * float64(part.Quantity)
+	}
+	lineTotal := sale.unitPrice * float64(item.Quantity)
+
+	var totalCost float64
+	for _, part := range parts {
+		if part.Product.ID == uuid.Nil {
+			return sale, saleError{fmt.Sprintf("Isi paket %s sudah tidak tersedia", bundle.Name)}
+		}
+		if reason := avail.Reason(part.Product); reason != "" {
+			return sale, saleError{fmt.Sprintf("Paket %s: %s", bundle.Name, reason)}
+		}
+		qty := part.Quantity * item.Quantity
+		unitCost, err := consumeStock(tx, ledger, calc, part.Product, qty)
+		if err != nil {
+			return sale, err
+		}
+		totalCost += unitCost * float64(qty)
+
+		revenue := lineTotal / float64(len(parts))
+		if weightTotal > 0 {
+			revenue = lineTotal * part.Product.Price * float64(part.Quantity) / weightTotal
+		}
+
+		comp := database.TransactionItemComponent{
+			ProductID: part.Product.ID,
+			Name:      part.Product.Name,
+			Quantity:  qty,
+			UnitCost:  &unitCost,
+			Revenue:   revenue,
+		}
+		if part.Product.ParentID != nil {
+			comp.ProductID = *part.Product.ParentID
+			comp.VariantID = &part.Product.ID
+		}
+		sale.components = append(sale.components, comp)
+	}
+	sale.unitCost = totalCost / float64(item.Quantity)
+
+	return sale, nil
+}
@@ -0,0 +1,168 @@
+package transaction
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/yuditriaji/warungin-backend/pkg/database"
+	"github.com/yuditriaji/warungin-backend/pkg/middleware"
+	"github.com/yuditriaji/warungin-backend/pkg/pagination"
+	"gorm.io/gorm"
+)
+
+// CashCountRequest closes a cashier's shift with the cash counted in the
+// drawer
+type CashCountRequest struct {
+	UserID       *uuid.UUID `json:"user_id"`   // Default the current user; others need an owner or manager
+	OutletID     *uuid.UUID `json:"outlet_id"` // Default the cashier's outlet
+	OpenedAt     time.Time  `json:"opened_at" binding:"required"`
+	ClosedAt     *time.Time `json:"closed_at"` // Default now
+	OpeningFloat float64    `json:"opening_float" binding:"min=0"`
+	CountedCash  *float64   `json:"counted_cash" binding:"required,min=0"`
+	Notes        string     `json:"notes"`
+}
+
+// CreateCashCount records a drawer count and the cash the shift's sales
+// should have left in it
+func (h *Handler) CreateCashCount(c *gin.Context) {
+	tenantID := c.GetString("tenant_id")
+	tenantUUID, _ := uuid.Parse(tenantID)
+	currentUser, _ := uuid.Parse(c.GetString("user_id"))
+	role := c.GetString("role")
+
+	var req CashCountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	cashierID := currentUser
+	if req.UserID != nil && *req.UserID != currentUser {
+		if !middleware.IsManager(role) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Hanya manager dan owner yang dapat menghitung kas kasir lain"})
+			return
+		}
+		cashierID = *req.UserID
+	}
+	var cashier database.User
+	if err := h.db.Where("id = ? AND tenant_id = ?", cashierID, tenantID).First(&cashier).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	outletID := cashier.OutletID
+	if req.OutletID != nil {
+		var count int64
+		h.db.Model(&database.Outlet{}).Where("id = ? AND tenant_id = ?", *req.OutletID, tenantID).Count(&count)
+		if count == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Outlet not found"})
+			return
+		}
+		outletID = req.OutletID
+	}
+
+	closedAt := time.Now()
+	if req.ClosedAt != nil {
+		closedAt = *req.ClosedAt
+	}
+	if !closedAt.After(req.OpenedAt) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "closed_at must be after opened_at"})
+		return
+	}
+	if closedAt.After(time.Now().Add(time.Minute)) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "closed_at cannot be in the future"})
+		return
+	}
+
+	// Voided sales are left out: their cash went back to the customer
+	salesQuery := h.db.Model(&database.Transaction{}).
+		Where("tenant_id = ? AND user_id = ? AND status = ? AND payment_method = ? AND created_at >= ? AND created_at < ?",
+			tenantID, cashierID, "completed", "cash", req.OpenedAt, closedAt)
+	if outletID != nil {
+		salesQuery = salesQuery.Where("outlet_id = ?", *outletID)
+	} else {
+		salesQuery = salesQuery.Where("outlet_id IS NULL")
+	}
+	var cashSales float64
+	if err := salesQuery.Select("COALESCE(SUM(total), 0)").Scan(&cashSales).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to calculate cash sales"})
+		return
+	}
+
+	count := database.CashCount{
+		TenantID:     tenantUUID,
+		OutletID:     outletID,
+		UserID:       cashierID,
+		CountedBy:    currentUser,
+		OpenedAt:     req.OpenedAt,
+		ClosedAt:     closedAt,
+		OpeningFloat: req.OpeningFloat,
+		CashSales:    cashSales,
+		ExpectedCash: req.OpeningFloat + cashSales,
+		CountedCash:  *req.CountedCash,
+		Notes:        req.Notes,
+	}
+	count.Variance = count.ExpectedCash - count.CountedCash
+	if err := h.db.Create(&count).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save cash count"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"data": count})
+}
+
+// ListCashCounts returns drawer counts, newest first. Cashiers see their
+// own; owners and managers everyone's. Query parameters:
+//
+//	start_date, end_date  YYYY-MM-DD in the tenant's time zone, by closing time
+//	outlet_id, user_id    one outlet or cashier
+//	page, per_page        default 1 and 50
+func (h *Handler) ListCashCounts(c *gin.Context) {
+	tenantID := c.GetString("tenant_id")
+	role := c.GetString("role")
+
+	query := h.db.Model(&database.CashCount{}).Where("tenant_id = ?", tenantID).Preload("User")
+	if !middleware.IsManager(role) {
+		query = query.Where("user_id = ?", c.GetString("user_id"))
+	} else if userID := c.Query("user_id"); userID != "" {
+		query = query.Where("user_id = ?", userID)
+	}
+	if outletID := c.Query("outlet_id"); outletID != "" {
+		query = query.Where("outlet_id = ?", outletID)
+	}
+
+	loc := h.tenantLocation(tenantID)
+	if start := c.Query("start_date"); start != "" {
+		day, err := time.ParseInLocation("2006-01-02", start, loc)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "start_date must be YYYY-MM-DD"})
+			return
+		}
+		query = query.Where("closed_at >= ?", day)
+	}
+	if end := c.Query("end_date"); end != "" {
+		day, err := time.ParseInLocation("2006-01-02", end, loc)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "end_date must be YYYY-MM-DD"})
+			return
+		}
+		query = query.Where("closed_at < ?", day.AddDate(0, 0, 1))
+	}
+
+	query = query.Session(&gorm.Session{})
+
+	page, _ := pagination.FromQuery(c)
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch cash counts"})
+		return
+	}
+	counts := []database.CashCount{}
+	if err := page.Apply(query.Order("closed_at DESC")).Find(&counts).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch cash counts"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": counts, "pagination": page.Meta(total)})
+}
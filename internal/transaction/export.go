@@ -0,0 +1,57 @@
+package transaction
+
+import (
+	"database/sql"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yuditriaji/warungin-backend/pkg/export"
+	"gorm.io/gorm"
+)
+
+// exportAuditLogs streams the audit logs query matches as a download
+func (h *Handler) exportAuditLogs(c *gin.Context, query *gorm.DB, format, tenantID string) {
+	loc := h.tenantLocation(tenantID)
+	rows, err := query.
+		Select(`transaction_audit_logs.created_at, transaction_audit_logs.action, transactions.invoice_number,
+			transactions.total, transaction_audit_logs.reason, users.name, transaction_audit_logs.ip_address`).
+		Joins("LEFT JOIN transactions ON transactions.id = transaction_audit_logs.transaction_id").
+		Joins("LEFT JOIN users ON users.id = transaction_audit_logs.user_id").
+		Rows()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil audit log"})
+		return
+	}
+	defer rows.Close()
+
+	w, err := export.Start(c, format, "audit_log_"+time.Now().In(loc).Format("20060102"), "Audit Log Transaksi", []export.Column{
+		{Header: "Waktu", Width: 1.2}, {Header: "Aksi", Width: 0.7}, {Header: "No. Invoice", Width: 1.5},
+		{Header: "Total"}, {Header: "Alasan", Width: 2.5}, {Header: "Oleh", Width: 1.2}, {Header: "IP"},
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate file"})
+		return
+	}
+	for rows.Next() {
+		var createdAt time.Time
+		var action, reason, ip string
+		var invoice, userName sql.NullString
+		var total sql.NullFloat64
+		if err := rows.Scan(&createdAt, &action, &invoice, &total, &reason, &userName, &ip); err != nil {
+			export.Fail(c, err)
+			return
+		}
+		if err := w.Row(createdAt.In(loc), action, invoice.String, total.Float64, reason, userName.String, ip); err != nil {
+			export.Fail(c, err)
+			return
+		}
+	}
+	if err := rows.Err(); err != nil {
+		export.Fail(c, err)
+		return
+	}
+	if err := w.Close(); err != nil {
+		export.Fail(c, err)
+	}
+}
@@ -8,7 +8,12 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/yuditriaji/warungin-backend/pkg/availability"
+	"github.com/yuditriaji/warungin-backend/pkg/costing"
 	"github.com/yuditriaji/warungin-backend/pkg/database"
+	"github.com/yuditriaji/warungin-backend/pkg/export"
+	"github.com/yuditriaji/warungin-backend/pkg/pricing"
+	"github.com/yuditriaji/warungin-backend/pkg/recipe"
 	"gorm.io/gorm"
 )
 
@@ -21,8 +26,10 @@ func NewHandler(db *gorm.DB) *Handler {
 }
 
 type TransactionItemRequest struct {
-	ProductID uuid.UUID `json:"product_id" binding:"required"`
-	Quantity  int       `json:"quantity" binding:"required,min=1"`
+	ProductID uuid.UUID   `json:"product_id" binding:"required"`
+	VariantID *uuid.UUID  `json:"variant_id"` // Required when the product has variants
+	Choices   []uuid.UUID `json:"choices"`    // Bundle options chosen, one entry per selection
+	Quantity  int         `json:"quantity" binding:"required,min=1"`
 }
 
 type CreateTransactionRequest struct {
@@ -33,33 +40,6 @@ type CreateTransactionRequest struct {
 	PaymentMethod string                   `json:"payment_method"`
 }
 
-// List returns all transactions for the tenant
-func (h *Handler) List(c *gin.Context) {
-	tenantID := c.GetString("tenant_id")
-	outletID := c.Query("outlet_id")
-
-	query := h.db.Where("tenant_id = ?", tenantID)
-	
-	// Filter by outlet if specified
-	if outletID != "" {
-		query = query.Where("outlet_id = ?", outletID)
-	}
-
-	var transactions []database.Transaction
-	if err := query.
-		Preload("Items").
-		Preload("Items.Product").
-		Preload("Customer").
-		Preload("Outlet").
-		Order("created_at DESC").
-		Find(&transactions).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch transactions"})
-		return
-	}
-
-	c.JSON(http.StatusOK, gin.H{"data": transactions})
-}
-
 // Create processes a new sale transaction
 func (h *Handler) Create(c *gin.Context) {
 	var req CreateTransactionRequest
@@ -85,6 +65,18 @@ func (h *Handler) Create(c *gin.Context) {
 		json.Unmarshal([]byte(tenant.Settings), &tenantSettings)
 	}
 
+	// Price lists are matched on the cashier's outlet, the customer's group and the local time
+	priceCtx := pricing.Context{OutletID: user.OutletID, At: time.Now().In(tenantSettings.Location())}
+	if req.CustomerID != nil {
+		var customer database.Customer
+		if err := h.db.Where("id = ? AND tenant_id = ?", *req.CustomerID, tenantID).First(&customer).Error; err == nil {
+			priceCtx.CustomerGroup = customer.Group
+		}
+	}
+	prices := pricing.Load(h.db, tenantIDStr, priceCtx)
+	// Items must be active, inside their sale hours and not sold out at the outlet
+	avail := availability.Load(h.db, tenantIDStr, user.OutletID, priceCtx.At)
+
 	// Start transaction
 	tx := h.db.Begin()
 
@@ -99,8 +91,10 @@ func (h *Handler) Create(c *gin.Context) {
 
 	// Calculate totals with per-product tax or global tax
 	var items []database.TransactionItem
+	var lineRates []float64
 	var subtotal float64
-	var totalTax float64
+	costCalc := recipe.NewCalculator(h.db)
+	ledger := costing.NewLedger(tx, tenantID, costing.Method(tenantSettings))
 
 	for _, item := range req.Items {
 		var product database.Product
@@ -109,86 +103,92 @@ func (h *Handler) Create(c *gin.Context) {
 			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Product %s not found", item.ProductID)})
 			return
 		}
+		if reason := avail.Reason(product); reason != "" {
+			tx.Rollback()
+			c.JSON(http.StatusBadRequest, gin.H{"error": reason})
+			return
+		}
 
-		itemSubtotal := product.Price * float64(item.Quantity)
-		// Calculate tax per product
-		itemTax := itemSubtotal * (product.TaxRate / 100)
-		totalTax += itemTax
-
-		items = append(items, database.TransactionItem{
-			ProductID: item.ProductID,
-			Quantity:  item.Quantity,
-			UnitPrice: product.Price,
-			Subtotal:  itemSubtotal,
-		})
-		subtotal += itemSubtotal
-
-		// Get linked materials for this product
-		var productMaterials []database.ProductMaterial
-		tx.Where("product_id = ?", item.ProductID).Preload("Material").Find(&productMaterials)
-
-		// If using material stock, validate materials are available and skip product stock deduction
-		if product.UseMaterialStock {
-			// Check if all materials have enough stock
-			for _, pm := range productMaterials {
-				// Apply conversion rate (recipe_qty × conversion = actual material usage)
-				convRate := pm.ConversionRate
-				if convRate <= 0 {
-					convRate = 1
-				}
-				required := pm.QuantityUsed * convRate * float64(item.Quantity)
-				if pm.Material.StockQty < required {
-					tx.Rollback()
-					c.JSON(http.StatusBadRequest, gin.H{
-						"error": fmt.Sprintf("Insufficient material: %s (need %.2f %s, have %.2f %s)", 
-							pm.Material.Name, required, pm.Material.Unit, pm.Material.StockQty, pm.Material.Unit),
-					})
-					return
-				}
+		// Variants carry their own price, cost, stock and recipe; the line is
+		// recorded against the parent so reports roll up
+		parentID := product.ID
+		if item.VariantID != nil {
+			var variant database.Product
+			if err := tx.Where("id = ? AND parent_id = ?", *item.VariantID, product.ID).First(&variant).Error; err != nil {
+				tx.Rollback()
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Variant %s not found for %s", *item.VariantID, product.Name)})
+				return
 			}
-		} else {
-			// Reduce product stock only if NOT using material stock
-			if err := tx.Model(&product).Update("stock_qty", gorm.Expr("stock_qty - ?", item.Quantity)).Error; err != nil {
+			if reason := avail.Reason(variant); reason != "" {
 				tx.Rollback()
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update stock"})
+				c.JSON(http.StatusBadRequest, gin.H{"error": reason})
 				return
 			}
+			product = variant
+		} else if product.HasVariants {
+			tx.Rollback()
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Pilih varian untuk %s", product.Name)})
+			return
 		}
 
-		// Always deduct raw materials if linked
-		for _, pm := range productMaterials {
-			// Apply conversion rate for deduction
-			convRate := pm.ConversionRate
-			if convRate <= 0 {
-				convRate = 1
+		price, priceList := prices.Resolve(product)
+		product.Price = price
+
+		// Bundles hold no stock; their components are deducted instead
+		unitPrice := product.Price
+		var unitCost float64
+		var components []database.TransactionItemComponent
+		if product.IsBundle {
+			sale, err := sellBundle(tx, ledger, costCalc, avail, product, item)
+			if err != nil {
+				tx.Rollback()
+				c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+				return
 			}
-			deduction := pm.QuantityUsed * convRate * float64(item.Quantity)
-			tx.Model(&database.RawMaterial{}).
-				Where("id = ?", pm.MaterialID).
-				Update("stock_qty", gorm.Expr("stock_qty - ?", deduction))
+			unitPrice, unitCost, components = sale.unitPrice, sale.unitCost, sale.components
+		} else {
+			cost, err := consumeStock(tx, ledger, costCalc, product, item.Quantity)
+			if err != nil {
+				tx.Rollback()
+				c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+				return
+			}
+			unitCost = cost
 		}
-	}
 
-	// Calculate final tax:
-	// Priority: 1) Request-provided tax, 2) Global tenant tax, 3) Per-product tax sum
-	finalTax := req.Tax
-	if finalTax == 0 {
-		if tenantSettings.TaxEnabled && tenantSettings.TaxRate > 0 {
-			// Use global tenant PPN rate on subtotal
-			finalTax = subtotal * (tenantSettings.TaxRate / 100)
-		} else {
-			// Use accumulated per-product tax
-			finalTax = totalTax
+		itemSubtotal := unitPrice * float64(item.Quantity)
+
+		transactionItem := database.TransactionItem{
+			ProductID:  parentID,
+			Quantity:   item.Quantity,
+			UnitPrice:  unitPrice,
+			UnitCost:   &unitCost,
+			Subtotal:   itemSubtotal,
+			Components: components,
+		}
+		if product.ParentID != nil {
+			transactionItem.VariantID = &product.ID
+			transactionItem.VariantName = product.VariantName
+		}
+		if priceList != nil {
+			transactionItem.PriceListID = &priceList.ID
 		}
+		items = append(items, transactionItem)
+		lineRates = append(lineRates, product.TaxRate)
+		subtotal += itemSubtotal
 	}
 
+	// Calculate final tax, itemised per line
+	tax := applyTax(items, lineRates, subtotal, req.Tax, tenantSettings)
+
 	// Calculate service charge if enabled
-	var serviceCharge float64
+	var serviceCharge, serviceChargeRate float64
 	if tenantSettings.ServiceChargeEnabled && tenantSettings.ServiceChargeRate > 0 {
-		serviceCharge = subtotal * (tenantSettings.ServiceChargeRate / 100)
+		serviceChargeRate = tenantSettings.ServiceChargeRate
+		serviceCharge = subtotal * (serviceChargeRate / 100)
 	}
 
-	total := subtotal - req.Discount + finalTax + serviceCharge
+	total := subtotal - req.Discount + tax.amount + serviceCharge
 	paymentMethod := req.PaymentMethod
 	if paymentMethod == "" {
 		paymentMethod = "cash"
@@ -207,7 +207,11 @@ func (h *Handler) Create(c *gin.Context) {
 		Items:         items,
 		Subtotal:      subtotal,
 		Discount:      req.Discount,
-		Tax:           finalTax,
+		Tax:           tax.amount,
+		TaxBase:       tax.base,
+		TaxRate:       tax.rate,
+		ServiceCharge: serviceCharge,
+		ServiceChargeRate: serviceChargeRate,
 		Total:         total,
 		Status:        "completed",
 		PaymentMethod: paymentMethod,
@@ -222,7 +226,7 @@ func (h *Handler) Create(c *gin.Context) {
 	tx.Commit()
 
 	// Reload with associations
-	h.db.Preload("Items").Preload("Items.Product").Preload("Customer").First(&transaction, transaction.ID)
+	h.db.Preload("Items").Preload("Items.Product").Preload("Items.Components").Preload("Customer").First(&transaction, transaction.ID)
 
 	c.JSON(http.StatusCreated, gin.H{"data": transaction})
 }
@@ -236,6 +240,7 @@ func (h *Handler) Get(c *gin.Context) {
 	if err := h.db.Where("id = ? AND tenant_id = ?", transactionID, tenantID).
 		Preload("Items").
 		Preload("Items.Product").
+		Preload("Items.Components").
 		Preload("Customer").
 		First(&transaction).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Transaction not found"})
@@ -273,7 +278,7 @@ func (h *Handler) Void(c *gin.Context) {
 	// Get the transaction
 	var transaction database.Transaction
 	if err := h.db.Where("id = ? AND tenant_id = ?", transactionID, tenantID).
-		Preload("Items").First(&transaction).Error; err != nil {
+		Preload("Items").Preload("Items.Components").First(&transaction).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Transaksi tidak ditemukan"})
 		return
 	}
@@ -309,6 +314,7 @@ func (h *Handler) Void(c *gin.Context) {
 
 	// Start database transaction
 	tx := h.db.Begin()
+	ledger := costing.NewLedger(tx, tenantID, costing.LoadMethod(h.db, tenantIDStr))
 
 	// Store old values as JSON for audit
 	oldValuesJSON, _ := json.Marshal(map[string]interface{}{
@@ -317,6 +323,7 @@ func (h *Handler) Void(c *gin.Context) {
 		"subtotal":  transaction.Subtotal,
 		"discount":  transaction.Discount,
 		"tax":       transaction.Tax,
+		"service_charge": transaction.ServiceCharge,
 		"items":     transaction.Items,
 	})
 
@@ -329,34 +336,10 @@ func (h *Handler) Void(c *gin.Context) {
 
 	// Restore stock for each item
 	for _, item := range transaction.Items {
-		// Get product to check UseMaterialStock flag
-		var product database.Product
-		h.db.Where("id = ?", item.ProductID).First(&product)
-
-		// Only restore product stock if NOT using material stock
-		if !product.UseMaterialStock {
-			if err := tx.Model(&database.Product{}).
-				Where("id = ?", item.ProductID).
-				Update("stock_qty", gorm.Expr("stock_qty + ?", item.Quantity)).Error; err != nil {
-				tx.Rollback()
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengembalikan stok"})
-				return
-			}
-		}
-
-		// Always restore raw materials
-		var productMaterials []database.ProductMaterial
-		h.db.Where("product_id = ?", item.ProductID).Find(&productMaterials)
-		for _, pm := range productMaterials {
-			// Apply conversion rate for restoration
-			convRate := pm.ConversionRate
-			if convRate <= 0 {
-				convRate = 1
-			}
-			restoration := pm.QuantityUsed * convRate * float64(item.Quantity)
-			tx.Model(&database.RawMaterial{}).
-				Where("id = ?", pm.MaterialID).
-				Update("stock_qty", gorm.Expr("stock_qty + ?", restoration))
+		if err := reverseItem(tx, ledger, item); err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengembalikan stok"})
+			return
 		}
 	}
 
@@ -408,24 +391,32 @@ func (h *Handler) ListAuditLogs(c *gin.Context) {
 	startDate := c.Query("start_date")
 	endDate := c.Query("end_date")
 
+	// Columns are qualified so exports can join users and transactions
 	query := h.db.Model(&database.TransactionAuditLog{}).
-		Where("tenant_id = ?", tenantID).
+		Where("transaction_audit_logs.tenant_id = ?", tenantID).
 		Preload("User").
 		Preload("Transaction").
-		Order("created_at DESC")
+		Order("transaction_audit_logs.created_at DESC")
 
 	if startDate != "" {
 		if parsed, err := time.Parse("2006-01-02", startDate); err == nil {
-			query = query.Where("created_at >= ?", parsed)
+			query = query.Where("transaction_audit_logs.created_at >= ?", parsed)
 		}
 	}
 	if endDate != "" {
 		if parsed, err := time.Parse("2006-01-02", endDate); err == nil {
 			endOfDay := time.Date(parsed.Year(), parsed.Month(), parsed.Day(), 23, 59, 59, 0, parsed.Location())
-			query = query.Where("created_at <= ?", endOfDay)
+			query = query.Where("transaction_audit_logs.created_at <= ?", endOfDay)
 		}
 	}
 
+	if format, ok := export.Requested(c); !ok {
+		return
+	} else if format != "" {
+		h.exportAuditLogs(c, query, format, tenantIDStr)
+		return
+	}
+
 	var logs []database.TransactionAuditLog
 	if err := query.Find(&logs).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil audit log"})
@@ -0,0 +1,200 @@
+package transaction
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/yuditriaji/warungin-backend/pkg/database"
+	"github.com/yuditriaji/warungin-backend/pkg/pagination"
+	"github.com/yuditriaji/warungin-backend/pkg/search"
+	"gorm.io/gorm"
+)
+
+// TransactionSummary is the light list row returned with ?view=summary
+type TransactionSummary struct {
+	ID            uuid.UUID  `json:"id"`
+	CreatedAt     time.Time  `json:"created_at"`
+	InvoiceNumber string     `json:"invoice_number"`
+	OrderNumber   int        `json:"order_number"`
+	Status        string     `json:"status"`
+	PaymentMethod string     `json:"payment_method"`
+	Subtotal      float64    `json:"subtotal"`
+	Discount      float64    `json:"discount"`
+	Tax           float64    `json:"tax"`
+	ServiceCharge float64    `json:"service_charge"`
+	Total         float64    `json:"total"`
+	ItemCount     int        `json:"item_count"` // Units sold
+	OutletID      *uuid.UUID `json:"outlet_id"`
+	OutletName    string     `json:"outlet_name"`
+	UserID        uuid.UUID  `json:"user_id"`
+	CashierName   string     `json:"cashier_name"`
+	CustomerID    *uuid.UUID `json:"customer_id"`
+	CustomerName  string     `json:"customer_name"`
+}
+
+// Columns ?sort accepts
+var transactionSorts = map[string]string{
+	"created_at": "transactions.created_at",
+	"total":      "transactions.total",
+}
+
+// tenantLocation returns the tenant's time zone
+func (h *Handler) tenantLocation(tenantID string) *time.Location {
+	var tenant database.Tenant
+	h.db.Select("settings").Where("id = ?", tenantID).First(&tenant)
+	var settings database.TenantSettings
+	if tenant.Settings != "" && tenant.Settings != "{}" {
+		json.Unmarshal([]byte(tenant.Settings), &settings)
+	}
+	return settings.Location()
+}
+
+// filterTransactions applies the List filters
+func (h *Handler) filterTransactions(query *gorm.DB, c *gin.Context, tenantID string) (*gorm.DB, error) {
+	// Dates are whole days in the tenant's time zone
+	loc := h.tenantLocation(tenantID)
+	if start := c.Query("start_date"); start != "" {
+		day, err := time.ParseInLocation("2006-01-02", start, loc)
+		if err != nil {
+			return nil, fmt.Errorf("start_date must be YYYY-MM-DD")
+		}
+		query = query.Where("transactions.created_at >= ?", day)
+	}
+	if end := c.Query("end_date"); end != "" {
+		day, err := time.ParseInLocation("2006-01-02", end, loc)
+		if err != nil {
+			return nil, fmt.Errorf("end_date must be YYYY-MM-DD")
+		}
+		query = query.Where("transactions.created_at < ?", day.AddDate(0, 0, 1))
+	}
+
+	for _, param := range []struct{ name, column string }{
+		{"outlet_id", "transactions.outlet_id"},
+		{"user_id", "transactions.user_id"},
+		{"customer_id", "transactions.customer_id"},
+	} {
+		value := c.Query(param.name)
+		if value == "" {
+			continue
+		}
+		id, err := uuid.Parse(value)
+		if err != nil {
+			return nil, fmt.Errorf("%s must be a UUID", param.name)
+		}
+		query = query.Where(param.column+" = ?", id)
+	}
+
+	// Comma-separated lists, e.g. ?status=completed,pending
+	for _, param := range []struct{ name, column string }{
+		{"status", "transactions.status"},
+		{"payment_method", "transactions.payment_method"},
+	} {
+		if value := c.Query(param.name); value != "" {
+			query = query.Where(param.column+" IN ?", strings.Split(value, ","))
+		}
+	}
+
+	for _, bound := range []struct{ param, op string }{{"min_total", ">="}, {"max_total", "<="}} {
+		value := c.Query(bound.param)
+		if value == "" {
+			continue
+		}
+		total, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s must be a number", bound.param)
+		}
+		query = query.Where("transactions.total "+bound.op+" ?", total)
+	}
+
+	if q := strings.TrimSpace(c.Query("q")); q != "" {
+		query = query.Where("transactions.invoice_number ILIKE ?", search.Contains(q))
+	}
+	return query, nil
+}
+
+// List returns the tenant's transactions, newest first, a page at a time.
+// Query parameters:
+//
+//	start_date, end_date  YYYY-MM-DD in the tenant's time zone, inclusive
+//	outlet_id, user_id, customer_id
+//	status, payment_method  one value or a comma-separated list
+//	min_total, max_total
+//	q              part of the invoice number
+//	sort, order    created_at (default) or total; desc (default) or asc
+//	page, per_page default 1 and 50
+//	view=summary   flat rows with names and an item count instead of
+//	               transactions with their items
+func (h *Handler) List(c *gin.Context) {
+	tenantID := c.GetString("tenant_id")
+
+	query := h.db.Model(&database.Transaction{}).Where("transactions.tenant_id = ?", tenantID)
+	query, err := h.filterTransactions(query, c, tenantID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	query = query.Session(&gorm.Session{})
+
+	sort := c.DefaultQuery("sort", "created_at")
+	column, ok := transactionSorts[sort]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "sort must be created_at or total"})
+		return
+	}
+	switch c.DefaultQuery("order", "desc") {
+	case "desc":
+		column += " DESC"
+	case "asc":
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "order must be asc or desc"})
+		return
+	}
+
+	// Always paged: the full history is too large to return at once
+	page, _ := pagination.FromQuery(c)
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch transactions"})
+		return
+	}
+	paged := page.Apply(query.Order(column).Order("transactions.id DESC"))
+
+	if c.Query("view") == "summary" {
+		summaries := []TransactionSummary{}
+		if err := paged.
+			Select(`transactions.id, transactions.created_at, transactions.invoice_number, transactions.order_number,
+				transactions.status, transactions.payment_method, transactions.subtotal, transactions.discount,
+				transactions.tax, transactions.service_charge, transactions.total, transactions.outlet_id, transactions.user_id, transactions.customer_id,
+				COALESCE(outlets.name, '') AS outlet_name, COALESCE(users.name, '') AS cashier_name,
+				COALESCE(customers.name, '') AS customer_name,
+				(SELECT COALESCE(SUM(ti.quantity), 0) FROM transaction_items ti WHERE ti.transaction_id = transactions.id) AS item_count`).
+			Joins("LEFT JOIN outlets ON outlets.id = transactions.outlet_id").
+			Joins("LEFT JOIN users ON users.id = transactions.user_id").
+			Joins("LEFT JOIN customers ON customers.id = transactions.customer_id").
+			Scan(&summaries).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch transactions"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"data": summaries, "pagination": page.Meta(total)})
+		return
+	}
+
+	var transactions []database.Transaction
+	if err := paged.
+		Preload("Items").
+		Preload("Items.Product").
+		Preload("Customer").
+		Preload("Outlet").
+		Find(&transactions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch transactions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": transactions, "pagination": page.Meta(total)})
+}
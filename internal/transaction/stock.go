@@ -0,0 +1,154 @@
+package transaction
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/yuditriaji/warungin-backend/pkg/costing"
+	"github.com/yuditriaji/warungin-backend/pkg/database"
+	"github.com/yuditriaji/warungin-backend/pkg/recipe"
+	"gorm.io/gorm"
+)
+
+// saleError is a problem with the order itself (not enough stock, a missing
+// bundle choice); its message is shown to the cashier
+type saleError struct {
+	msg string
+}
+
+func (e saleError) Error() string {
+	return e.msg
+}
+
+// errorStatus returns the response status for an error from selling an item
+func errorStatus(err error) int {
+	var se saleError
+	if errors.As(err, &se) {
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+// consumeStock deducts a sold product's stock and linked materials and
+// returns the unit cost to capture on the sale
+func consumeStock(tx *gorm.DB, ledger *costing.Ledger, calc *recipe.Calculator, product database.Product, qty int) (float64, error) {
+	// Get linked materials for this product
+	var productMaterials []database.ProductMaterial
+	tx.Where("product_id = ?", product.ID).Preload("Material").Find(&productMaterials)
+
+	// If using material stock, validate materials are available and skip product stock deduction
+	if product.UseMaterialStock {
+		// Check if all materials have enough stock
+		for _, pm := range productMaterials {
+			// Apply conversion rate (recipe_qty × conversion = actual material usage)
+			convRate := pm.ConversionRate
+			if convRate <= 0 {
+				convRate = 1
+			}
+			required := pm.QuantityUsed * convRate * float64(qty)
+			if pm.Material.StockQty < required {
+				return 0, saleError{fmt.Sprintf("Insufficient material: %s (need %.2f %s, have %.2f %s)",
+					pm.Material.Name, required, pm.Material.Unit, pm.Material.StockQty, pm.Material.Unit)}
+			}
+		}
+	}
+
+	// Reduce product stock only if NOT using material stock
+	var productCost float64
+	if !product.UseMaterialStock {
+		consumed, err := ledger.Consume(costing.ItemProduct, product.ID, float64(qty))
+		if err != nil {
+			return 0, errors.New("Failed to update stock")
+		}
+		productCost = consumed
+	}
+
+	// Always deduct raw materials if linked
+	var materialCost float64
+	for _, pm := range productMaterials {
+		// Apply conversion rate for deduction
+		convRate := pm.ConversionRate
+		if convRate <= 0 {
+			convRate = 1
+		}
+		deduction := pm.QuantityUsed * convRate * float64(qty)
+		consumed, err := ledger.Consume(costing.ItemMaterial, pm.MaterialID, deduction)
+		if err != nil {
+			return 0, errors.New("Failed to update material stock")
+		}
+		materialCost += consumed
+	}
+
+	// Snapshot the unit cost so later cost changes don't rewrite historical COGS.
+	// Manual costing uses the current recipe/product cost; average and fifo use
+	// the cost of the stock actually consumed.
+	unitCost := calc.ProductUnitCost(product)
+	if ledger.Method() != costing.MethodManual {
+		unitCost = productCost / float64(qty)
+		if product.UseMaterialStock || unitCost <= 0 {
+			unitCost = materialCost / float64(qty)
+		}
+	}
+	return unitCost, nil
+}
+
+// restoreStock puts back the stock and materials taken by consumeStock
+func restoreStock(tx *gorm.DB, ledger *costing.Ledger, productID uuid.UUID, qty int, unitCost *float64) error {
+	// Get product to check UseMaterialStock flag
+	var product database.Product
+	tx.Unscoped().Where("id = ?", productID).First(&product)
+
+	// Only restore product stock if NOT using material stock
+	if !product.UseMaterialStock {
+		// Put stock back at the cost it was sold at
+		var cost float64
+		if unitCost != nil {
+			cost = *unitCost
+		}
+		if err := ledger.Restore(costing.ItemProduct, productID, float64(qty), cost); err != nil {
+			return err
+		}
+	}
+
+	// Always restore raw materials
+	var productMaterials []database.ProductMaterial
+	tx.Where("product_id = ?", productID).Find(&productMaterials)
+	for _, pm := range productMaterials {
+		// Apply conversion rate for restoration
+		convRate := pm.ConversionRate
+		if convRate <= 0 {
+			convRate = 1
+		}
+		restoration := pm.QuantityUsed * convRate * float64(qty)
+		if err := ledger.Restore(costing.ItemMaterial, pm.MaterialID, restoration, 0); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// reverseItem returns a sold line's stock: a bundle's components, a variant's
+// own stock, or the product's
+func reverseItem(tx *gorm.DB, ledger *costing.Ledger, item database.TransactionItem) error {
+	if len(item.Components) > 0 {
+		for _, comp := range item.Components {
+			stockID := comp.ProductID
+			if comp.VariantID != nil {
+				stockID = *comp.VariantID
+			}
+			if err := restoreStock(tx, ledger, stockID, comp.Quantity, comp.UnitCost); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	// Stock was taken from the variant when one was sold
+	stockID := item.ProductID
+	if item.VariantID != nil {
+		stockID = *item.VariantID
+	}
+	return restoreStock(tx, ledger, stockID, item.Quantity, item.UnitCost)
+}
@@ -0,0 +1,67 @@
+package transaction
+
+import (
+	"math"
+
+	"github.com/yuditriaji/warungin-backend/pkg/database"
+)
+
+// saleTax is the tax on a sale, itemised on its lines
+type saleTax struct {
+	amount float64
+	base   float64 // DPP: the amount taxed
+	rate   float64
+}
+
+// roundRate rounds a derived rate to two decimals
+func roundRate(rate float64) float64 {
+	return math.Round(rate*100) / 100
+}
+
+// applyTax works out the sale's tax and records each line's rate and amount
+// so the tax report can group them. lineRates holds each product's own rate.
+// Priority: 1) Request-provided tax, 2) Global tenant tax, 3) Per-product tax sum
+func applyTax(items []database.TransactionItem, lineRates []float64, subtotal, requested float64, settings database.TenantSettings) saleTax {
+	var tax saleTax
+	setLine := func(i int, rate, amount float64) {
+		items[i].TaxRate = &rate
+		items[i].TaxAmount = amount
+	}
+
+	switch {
+	case requested != 0:
+		// The POS worked the tax out; spread it over the lines at its rate
+		tax.amount, tax.base = requested, subtotal
+		if subtotal > 0 {
+			tax.rate = roundRate(requested / subtotal * 100)
+		}
+		for i := range items {
+			var amount float64
+			if subtotal > 0 {
+				amount = requested * items[i].Subtotal / subtotal
+			}
+			setLine(i, tax.rate, amount)
+		}
+	case settings.TaxEnabled && settings.TaxRate > 0:
+		// Global tenant PPN rate on subtotal
+		tax.rate, tax.base = settings.TaxRate, subtotal
+		tax.amount = subtotal * (settings.TaxRate / 100)
+		for i := range items {
+			setLine(i, tax.rate, items[i].Subtotal*(tax.rate/100))
+		}
+	default:
+		// Each product's own rate; only taxed lines count towards the base
+		for i := range items {
+			amount := items[i].Subtotal * (lineRates[i] / 100)
+			setLine(i, lineRates[i], amount)
+			tax.amount += amount
+			if lineRates[i] > 0 {
+				tax.base += items[i].Subtotal
+			}
+		}
+		if tax.base > 0 {
+			tax.rate = roundRate(tax.amount / tax.base * 100)
+		}
+	}
+	return tax
+}
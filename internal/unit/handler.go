@@ -0,0 +1,160 @@
+package unit
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/yuditriaji/warungin-backend/pkg/database"
+	"github.com/yuditriaji/warungin-backend/pkg/units"
+	"gorm.io/gorm"
+)
+
+type Handler struct {
+	db *gorm.DB
+}
+
+func NewHandler(db *gorm.DB) *Handler {
+	return &Handler{db: db}
+}
+
+type CreateUnitInput struct {
+	Code     string  `json:"code" binding:"required"`          // e.g. "dus"
+	Name     string  `json:"name"`                             // e.g. "Dus isi 24"
+	BaseQty  float64 `json:"base_qty" binding:"required,gt=0"` // e.g. 24
+	BaseUnit string  `json:"base_unit" binding:"required"`     // e.g. "pcs"
+}
+
+type ConvertInput struct {
+	Quantity float64 `form:"quantity"`
+	From     string  `form:"from" binding:"required"`
+	To       string  `form:"to" binding:"required"`
+}
+
+// List returns standard and tenant-defined units
+func (h *Handler) List(c *gin.Context) {
+	tenantID := c.GetString("tenant_id")
+
+	catalog := units.LoadCatalog(h.db, tenantID)
+
+	var custom []database.CustomUnit
+	h.db.Where("tenant_id = ?", tenantID).Order("code ASC").Find(&custom)
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":   catalog.All(),
+		"custom": custom,
+	})
+}
+
+// Create defines a custom pack unit in terms of an existing unit
+func (h *Handler) Create(c *gin.Context) {
+	tenantID := c.GetString("tenant_id")
+	tenantUUID, _ := uuid.Parse(tenantID)
+
+	var input CreateUnitInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	code := units.Normalize(input.Code)
+	if _, exists := units.Lookup(code); exists {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("%s is a standard unit", input.Code)})
+		return
+	}
+
+	catalog := units.LoadCatalog(h.db, tenantID)
+	if _, exists := catalog.Lookup(code); exists {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Unit %s already exists", input.Code)})
+		return
+	}
+
+	base, ok := catalog.Lookup(input.BaseUnit)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Unknown base unit: %s", input.BaseUnit)})
+		return
+	}
+
+	name := strings.TrimSpace(input.Name)
+	if name == "" {
+		name = fmt.Sprintf("%s (%g %s)", code, input.BaseQty, base.Code)
+	}
+
+	unit := database.CustomUnit{
+		TenantID:  tenantUUID,
+		Code:      code,
+		Name:      name,
+		BaseQty:   input.BaseQty,
+		BaseUnit:  base.Code,
+		Dimension: string(base.Dimension),
+		Factor:    input.BaseQty * base.Factor,
+	}
+
+	if err := h.db.Create(&unit).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create unit"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"data": unit})
+}
+
+// Delete removes a custom unit
+func (h *Handler) Delete(c *gin.Context) {
+	tenantID := c.GetString("tenant_id")
+	id := c.Param("id")
+
+	var unit database.CustomUnit
+	if err := h.db.Where("id = ? AND tenant_id = ?", id, tenantID).First(&unit).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Unit not found"})
+		return
+	}
+
+	// Other custom units may be defined in terms of this one
+	var dependents int64
+	h.db.Model(&database.CustomUnit{}).
+		Where("tenant_id = ? AND base_unit = ?", tenantID, unit.Code).
+		Count(&dependents)
+	if dependents > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unit is used as the base of other units"})
+		return
+	}
+
+	// Hard delete so the code can be reused
+	h.db.Unscoped().Delete(&unit)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Unit deleted"})
+}
+
+// Convert converts a quantity between two units
+func (h *Handler) Convert(c *gin.Context) {
+	tenantID := c.GetString("tenant_id")
+
+	var input ConvertInput
+	if err := c.ShouldBindQuery(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	catalog := units.LoadCatalog(h.db, tenantID)
+	rate, known, err := catalog.ConversionRate(input.From, input.To)
+	if !known {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown unit"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": gin.H{
+			"from":            units.Normalize(input.From),
+			"to":              units.Normalize(input.To),
+			"conversion_rate": rate,
+			"quantity":        input.Quantity,
+			"result":          input.Quantity * rate,
+		},
+	})
+}
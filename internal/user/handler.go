@@ -7,6 +7,7 @@ import (
 	"github.com/google/uuid"
 	"github.com/yuditriaji/warungin-backend/pkg/activitylog"
 	"github.com/yuditriaji/warungin-backend/pkg/database"
+	"github.com/yuditriaji/warungin-backend/pkg/export"
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 )
@@ -236,14 +237,27 @@ func (h *Handler) DeleteStaff(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "Staff deleted"})
 }
 
-// GetActivityLogs retrieves logs
+// GetActivityLogs retrieves the latest 100 logs. With ?format it downloads
+// every matching log instead. Filters: start_date and end_date (YYYY-MM-DD
+// in the tenant's time zone), user_id and action.
 func (h *Handler) GetActivityLogs(c *gin.Context) {
 	tenantID := c.GetString("tenant_id")
 
+	query, err := h.filterActivityLogs(c, tenantID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if format, ok := export.Requested(c); !ok {
+		return
+	} else if format != "" {
+		h.exportActivityLogs(c, query, format, tenantID)
+		return
+	}
+
 	var logs []database.ActivityLog
-	if err := h.db.Preload("User").
-		Where("tenant_id = ?", tenantID).
-		Order("created_at DESC").
+	if err := query.Preload("User").
+		Order("activity_logs.created_at DESC").
 		Limit(100).
 		Find(&logs).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -0,0 +1,78 @@
+// Package availability decides whether a product can be sold right now: it
+// must be active, inside its sale hours and not marked sold out today.
+package availability
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/yuditriaji/warungin-backend/pkg/database"
+	"github.com/yuditriaji/warungin-backend/pkg/schedule"
+	"gorm.io/gorm"
+)
+
+// DateFormat is the layout of ProductSoldOut.Date
+const DateFormat = "2006-01-02"
+
+// Window returns a product's sale hours
+func Window(product database.Product) schedule.Window {
+	return schedule.Window{Days: product.AvailableDays, Start: product.AvailableFrom, End: product.AvailableUntil}
+}
+
+// Checker answers for one outlet at one moment
+type Checker struct {
+	now     time.Time
+	soldOut map[uuid.UUID]bool
+}
+
+// Load reads today's sold-out marks for an outlet, including those for every
+// outlet; with no outlet only the latter apply. now must be in the tenant's
+// time zone so "today" is the local date.
+func Load(db *gorm.DB, tenantID string, outletID *uuid.UUID, now time.Time) *Checker {
+	query := db.Model(&database.ProductSoldOut{}).
+		Where("tenant_id = ? AND date = ?", tenantID, now.Format(DateFormat))
+	if outletID != nil {
+		query = query.Where("outlet_id IS NULL OR outlet_id = ?", *outletID)
+	} else {
+		query = query.Where("outlet_id IS NULL")
+	}
+
+	var ids []uuid.UUID
+	query.Pluck("product_id", &ids)
+
+	c := &Checker{now: now, soldOut: make(map[uuid.UUID]bool)}
+	for _, id := range ids {
+		c.soldOut[id] = true
+	}
+	return c
+}
+
+// SoldOut reports whether a product, or the product a variant belongs to, is
+// sold out today
+func (c *Checker) SoldOut(product database.Product) bool {
+	if c.soldOut[product.ID] {
+		return true
+	}
+	return product.ParentID != nil && c.soldOut[*product.ParentID]
+}
+
+// Reason explains why a product cannot be sold, or returns "" when it can
+func (c *Checker) Reason(product database.Product) string {
+	name := product.Name // Variant names already include their parent's
+	if !product.IsActive {
+		return fmt.Sprintf("%s tidak aktif", name)
+	}
+	if window := Window(product); !window.Contains(c.now) {
+		return fmt.Sprintf("%s hanya tersedia %s", name, window)
+	}
+	if c.SoldOut(product) {
+		return fmt.Sprintf("%s sudah habis hari ini", name)
+	}
+	return ""
+}
+
+// Available reports whether a product can be sold
+func (c *Checker) Available(product database.Product) bool {
+	return c.Reason(product) == ""
+}
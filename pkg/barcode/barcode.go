@@ -0,0 +1,215 @@
+// Package barcode validates product barcodes and encodes them into bar
+// patterns for printing.
+package barcode
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// Supported formats
+const (
+	EAN13   = "ean13"
+	EAN8    = "ean8"
+	UPCA    = "upca"
+	Code128 = "code128"
+)
+
+// MaxLength is the longest free-form (Code 128) barcode accepted
+const MaxLength = 48
+
+var (
+	ErrEmpty    = errors.New("Barcode is required")
+	ErrChecksum = errors.New("Barcode check digit is invalid")
+	ErrInvalid  = fmt.Errorf("Barcode must be printable ASCII and at most %d characters", MaxLength)
+)
+
+// Normalize trims the whitespace scanners and spreadsheets tend to add
+func Normalize(code string) string {
+	return strings.TrimSpace(code)
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// CheckDigit returns the GS1 check digit for a code without its check digit
+func CheckDigit(digits string) int {
+	sum := 0
+	// Weights alternate 3, 1 starting from the rightmost digit
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := int(digits[i] - '0')
+		if (len(digits)-1-i)%2 == 0 {
+			d *= 3
+		}
+		sum += d
+	}
+	return (10 - sum%10) % 10
+}
+
+func validCheckDigit(code string) bool {
+	return CheckDigit(code[:len(code)-1]) == int(code[len(code)-1]-'0')
+}
+
+// Detect returns the format of a code. Numeric codes of GS1 lengths must have
+// a valid check digit; anything else printable is treated as Code 128.
+func Detect(code string) (string, error) {
+	if code == "" {
+		return "", ErrEmpty
+	}
+	if isDigits(code) {
+		format := ""
+		switch len(code) {
+		case 8:
+			format = EAN8
+		case 12:
+			format = UPCA
+		case 13:
+			format = EAN13
+		}
+		if format != "" {
+			if !validCheckDigit(code) {
+				return "", ErrChecksum
+			}
+			return format, nil
+		}
+	}
+	if len(code) > MaxLength {
+		return "", ErrInvalid
+	}
+	for i := 0; i < len(code); i++ {
+		if code[i] < 32 || code[i] > 126 {
+			return "", ErrInvalid
+		}
+	}
+	return Code128, nil
+}
+
+// Alternates returns other spellings of a scanned code: scanners may report
+// a UPC-A code with or without the leading zero of its EAN-13 form
+func Alternates(code string) []string {
+	if !isDigits(code) {
+		return nil
+	}
+	switch {
+	case len(code) == 13 && code[0] == '0':
+		return []string{code[1:]}
+	case len(code) == 12:
+		return []string{"0" + code}
+	}
+	return nil
+}
+
+// Internal generates an EAN-13 in the GS1 restricted range 20-29, which is
+// reserved for in-store use and never clashes with manufacturer codes
+func Internal() (string, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(1e10))
+	if err != nil {
+		return "", err
+	}
+	code := fmt.Sprintf("20%010d", n.Int64())
+	return fmt.Sprintf("%s%d", code, CheckDigit(code)), nil
+}
+
+// EAN digit patterns; G is L reversed and inverted, R is L inverted
+var (
+	eanL = []string{"0001101", "0011001", "0010011", "0111101", "0100011", "0110001", "0101111", "0111011", "0110111", "0001011"}
+	eanG = []string{"0100111", "0110011", "0011011", "0100001", "0011101", "0111001", "0000101", "0010001", "0001001", "0010111"}
+	eanR = []string{"1110010", "1100110", "1101100", "1000010", "1011100", "1001110", "1010000", "1000100", "1001000", "1110100"}
+
+	// Parity of the EAN-13 left half, selected by the first digit
+	eanParity = []string{"LLLLLL", "LLGLGG", "LLGGLG", "LLGGGL", "LGLLGG", "LGGLLG", "LGGGLL", "LGLGLG", "LGLGGL", "LGGLGL"}
+)
+
+// Code 128 bar/space widths for values 0-106 (106 is the stop pattern)
+var code128 = []string{
+	"212222", "222122", "222221", "121223", "121322", "131222", "122213", "122312", "132212", "221213",
+	"221312", "231212", "112232", "122132", "122231", "113222", "123122", "123221", "223211", "221132",
+	"221231", "213212", "223112", "312131", "311222", "321122", "321221", "312212", "322112", "322211",
+	"212123", "212321", "232121", "111323", "131123", "131321", "112313", "132113", "132311", "211313",
+	"231113", "231311", "112133", "112331", "132131", "113123", "113321", "133121", "313121", "211331",
+	"231131", "213113", "213311", "213131", "311123", "311321", "331121", "312113", "312311", "332111",
+	"314111", "221411", "431111", "111224", "111422", "121124", "121421", "141122", "141221", "112214",
+	"112412", "122114", "122411", "142112", "142211", "241211", "221114", "413111", "241112", "134111",
+	"111242", "121142", "121241", "114212", "124112", "124211", "411212", "421112", "421211", "212141",
+	"214121", "412121", "111143", "111341", "131141", "114113", "114311", "411113", "411311", "113141",
+	"114131", "311141", "411131", "211412", "211214", "211232", "2331112",
+}
+
+const (
+	code128StartB = 104
+	code128Stop   = 106
+)
+
+// Modules encodes a code into bar modules, true for a dark module. Quiet
+// zones are not included.
+func Modules(code, format string) ([]bool, error) {
+	var pattern string
+	switch format {
+	case UPCA:
+		return Modules("0"+code, EAN13)
+	case EAN13:
+		parity := eanParity[code[0]-'0']
+		pattern = "101"
+		for i := 1; i <= 6; i++ {
+			d := code[i] - '0'
+			if parity[i-1] == 'G' {
+				pattern += eanG[d]
+			} else {
+				pattern += eanL[d]
+			}
+		}
+		pattern += "01010"
+		for i := 7; i <= 12; i++ {
+			pattern += eanR[code[i]-'0']
+		}
+		pattern += "101"
+	case EAN8:
+		pattern = "101"
+		for i := 0; i < 4; i++ {
+			pattern += eanL[code[i]-'0']
+		}
+		pattern += "01010"
+		for i := 4; i < 8; i++ {
+			pattern += eanR[code[i]-'0']
+		}
+		pattern += "101"
+	case Code128:
+		// Code set B covers all printable ASCII
+		values := []int{code128StartB}
+		checksum := code128StartB
+		for i := 0; i < len(code); i++ {
+			v := int(code[i]) - 32
+			values = append(values, v)
+			checksum += v * (i + 1)
+		}
+		values = append(values, checksum%103, code128Stop)
+		for _, v := range values {
+			dark := true
+			for _, w := range code128[v] {
+				bit := "0"
+				if dark {
+					bit = "1"
+				}
+				pattern += strings.Repeat(bit, int(w-'0'))
+				dark = !dark
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unsupported barcode format %q", format)
+	}
+
+	modules := make([]bool, len(pattern))
+	for i := range pattern {
+		modules[i] = pattern[i] == '1'
+	}
+	return modules, nil
+}
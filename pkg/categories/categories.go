@@ -0,0 +1,128 @@
+package categories
+
+import (
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/yuditriaji/warungin-backend/pkg/database"
+	"gorm.io/gorm"
+)
+
+// MaxDepth limits how deeply categories can be nested
+const MaxDepth = 5
+
+var (
+	ErrCycle    = errors.New("category cannot be nested under itself")
+	ErrTooDeep  = errors.New("categories cannot be nested that deep")
+	ErrNotFound = errors.New("parent category not found")
+)
+
+// Tree is a tenant's categories indexed for parent/child lookups
+type Tree struct {
+	parent   map[uuid.UUID]*uuid.UUID
+	children map[uuid.UUID][]uuid.UUID
+}
+
+// Load reads all of a tenant's categories
+func Load(db *gorm.DB, tenantID string) *Tree {
+	var rows []database.Category
+	db.Select("id, parent_id").Where("tenant_id = ?", tenantID).Find(&rows)
+
+	t := &Tree{
+		parent:   make(map[uuid.UUID]*uuid.UUID, len(rows)),
+		children: make(map[uuid.UUID][]uuid.UUID),
+	}
+	for _, cat := range rows {
+		t.parent[cat.ID] = cat.ParentID
+		if cat.ParentID != nil {
+			t.children[*cat.ParentID] = append(t.children[*cat.ParentID], cat.ID)
+		}
+	}
+	return t
+}
+
+// Exists reports whether the category belongs to the tenant
+func (t *Tree) Exists(id uuid.UUID) bool {
+	_, ok := t.parent[id]
+	return ok
+}
+
+// WithDescendants returns id and every category nested under it. Each
+// category is listed once, even if stored data loops.
+func (t *Tree) WithDescendants(id uuid.UUID) []uuid.UUID {
+	result := []uuid.UUID{id}
+	seen := map[uuid.UUID]bool{id: true}
+	for i := 0; i < len(result); i++ {
+		for _, child := range t.children[result[i]] {
+			if !seen[child] {
+				seen[child] = true
+				result = append(result, child)
+			}
+		}
+	}
+	return result
+}
+
+// Root returns the top-level category that id is nested under (id itself for top-level categories)
+func (t *Tree) Root(id uuid.UUID) uuid.UUID {
+	for depth := 0; depth < MaxDepth*2; depth++ {
+		parent := t.parent[id]
+		if parent == nil {
+			break
+		}
+		id = *parent
+	}
+	return id
+}
+
+// depth returns how many levels id is below the top level
+func (t *Tree) depth(id uuid.UUID) int {
+	depth := 0
+	for parent := t.parent[id]; parent != nil && depth <= MaxDepth; parent = t.parent[*parent] {
+		depth++
+	}
+	return depth
+}
+
+// height returns how many levels of children are nested under id, stopping
+// past MaxDepth so looping data can't recurse forever
+func (t *Tree) height(id uuid.UUID) int {
+	return t.heightFrom(id, 0)
+}
+
+func (t *Tree) heightFrom(id uuid.UUID, level int) int {
+	if level > MaxDepth {
+		return 0
+	}
+	max := 0
+	for _, child := range t.children[id] {
+		if h := t.heightFrom(child, level+1) + 1; h > max {
+			max = h
+		}
+	}
+	return max
+}
+
+// CheckParent validates moving category id (uuid.Nil for a new category)
+// under parentID without creating a cycle or exceeding MaxDepth
+func (t *Tree) CheckParent(id, parentID uuid.UUID) error {
+	if !t.Exists(parentID) {
+		return ErrNotFound
+	}
+	if id != uuid.Nil {
+		for _, descendant := range t.WithDescendants(id) {
+			if descendant == parentID {
+				return ErrCycle
+			}
+		}
+	}
+
+	levels := t.depth(parentID) + 2 // parent's level + the category itself
+	if id != uuid.Nil {
+		levels += t.height(id)
+	}
+	if levels > MaxDepth {
+		return ErrTooDeep
+	}
+	return nil
+}
@@ -0,0 +1,309 @@
+package costing
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+
+	"github.com/google/uuid"
+	"github.com/yuditriaji/warungin-backend/pkg/database"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Costing methods (per tenant, see TenantSettings.CostingMethod)
+const (
+	MethodManual  = "manual"  // Cost is whatever the user entered
+	MethodAverage = "average" // Moving weighted average, recomputed on every receipt
+	MethodFIFO    = "fifo"    // Oldest cost layers are consumed first
+)
+
+// Item types that carry stock
+const (
+	ItemMaterial = "material"
+	ItemProduct  = "product"
+)
+
+// ValidMethod reports whether method is a known costing method
+func ValidMethod(method string) bool {
+	return method == MethodManual || method == MethodAverage || method == MethodFIFO
+}
+
+// Method returns the tenant's costing method, defaulting to manual
+func Method(settings database.TenantSettings) string {
+	if ValidMethod(settings.CostingMethod) {
+		return settings.CostingMethod
+	}
+	return MethodManual
+}
+
+// LoadMethod reads the costing method from a tenant's settings
+func LoadMethod(db *gorm.DB, tenantID string) string {
+	var tenant database.Tenant
+	db.Where("id = ?", tenantID).First(&tenant)
+
+	var settings database.TenantSettings
+	if tenant.Settings != "" && tenant.Settings != "{}" {
+		json.Unmarshal([]byte(tenant.Settings), &settings)
+	}
+	return Method(settings)
+}
+
+// Ledger moves stock in and out while keeping item costs in line with the
+// tenant's costing method. Use it inside a database transaction.
+type Ledger struct {
+	tx       *gorm.DB
+	tenantID uuid.UUID
+	method   string
+}
+
+// NewLedger creates a ledger for one tenant
+func NewLedger(tx *gorm.DB, tenantID uuid.UUID, method string) *Ledger {
+	return &Ledger{tx: tx, tenantID: tenantID, method: method}
+}
+
+// Method returns the ledger's costing method
+func (l *Ledger) Method() string {
+	return l.method
+}
+
+// model returns the model and cost column for an item type
+func model(itemType string) (interface{}, string, error) {
+	switch itemType {
+	case ItemMaterial:
+		return &database.RawMaterial{}, "unit_price", nil
+	case ItemProduct:
+		return &database.Product{}, "cost", nil
+	}
+	return nil, "", fmt.Errorf("unknown item type: %s", itemType)
+}
+
+// current returns an item's stock quantity and stored unit cost. The item row
+// is locked until the transaction ends, so concurrent sales can't read the
+// same stock and cost or use the same fifo layers.
+func (l *Ledger) current(itemType string, itemID uuid.UUID) (stock, cost float64, err error) {
+	m, costCol, err := model(itemType)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var row struct {
+		StockQty float64
+		Cost     float64
+	}
+	if err := l.tx.Model(m).
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		Select("stock_qty, "+costCol+" as cost").
+		Where("id = ?", itemID).
+		Scan(&row).Error; err != nil {
+		return 0, 0, err
+	}
+	return row.StockQty, row.Cost, nil
+}
+
+// update applies a stock delta and sets the stored unit cost
+func (l *Ledger) update(itemType string, itemID uuid.UUID, delta, cost float64) error {
+	m, costCol, err := model(itemType)
+	if err != nil {
+		return err
+	}
+	return l.tx.Model(m).
+		Where("id = ?", itemID).
+		Updates(map[string]interface{}{
+			"stock_qty": gorm.Expr("stock_qty + ?", delta),
+			costCol:     cost,
+		}).Error
+}
+
+// layerAverage returns the average unit cost of an item's remaining FIFO layers
+func (l *Ledger) layerAverage(itemType string, itemID uuid.UUID, fallback float64) float64 {
+	var sums struct {
+		Qty   float64
+		Value float64
+	}
+	l.tx.Model(&database.CostLayer{}).
+		Select("COALESCE(SUM(remaining), 0) as qty, COALESCE(SUM(remaining * unit_cost), 0) as value").
+		Where("item_type = ? AND item_id = ? AND remaining > 0", itemType, itemID).
+		Scan(&sums)
+	if sums.Qty <= 0 {
+		return fallback
+	}
+	return sums.Value / sums.Qty
+}
+
+// Receive adds stock at the given unit cost and updates the item's cost:
+// average recomputes the moving average, fifo adds a cost layer, manual keeps
+// the entered cost (adopting unitCost only when none is set).
+func (l *Ledger) Receive(itemType string, itemID uuid.UUID, qty, unitCost float64, receiptID *uuid.UUID) error {
+	if qty <= 0 {
+		return nil
+	}
+
+	stock, cost, err := l.current(itemType, itemID)
+	if err != nil {
+		return err
+	}
+
+	newCost := cost
+	switch l.method {
+	case MethodAverage:
+		if stock <= 0 {
+			newCost = unitCost
+		} else {
+			newCost = (stock*cost + qty*unitCost) / (stock + qty)
+		}
+	case MethodFIFO:
+		layer := database.CostLayer{
+			TenantID:  l.tenantID,
+			ItemType:  itemType,
+			ItemID:    itemID,
+			ReceiptID: receiptID,
+			Quantity:  qty,
+			Remaining: qty,
+			UnitCost:  unitCost,
+		}
+		if err := l.tx.Create(&layer).Error; err != nil {
+			return err
+		}
+		newCost = l.layerAverage(itemType, itemID, unitCost)
+	default:
+		if cost <= 0 {
+			newCost = unitCost
+		}
+	}
+
+	return l.update(itemType, itemID, qty, newCost)
+}
+
+// Restore puts stock back (e.g. on void) at unitCost, or at the item's
+// current cost when unitCost is zero
+func (l *Ledger) Restore(itemType string, itemID uuid.UUID, qty, unitCost float64) error {
+	if unitCost <= 0 {
+		_, cost, err := l.current(itemType, itemID)
+		if err != nil {
+			return err
+		}
+		unitCost = cost
+	}
+	return l.Receive(itemType, itemID, qty, unitCost, nil)
+}
+
+// Adjust moves an item's stock by delta, as a stock count or manual edit does:
+// added stock comes in at the item's current cost, removed stock is consumed
+func (l *Ledger) Adjust(itemType string, itemID uuid.UUID, delta float64) error {
+	if delta > 0 {
+		return l.Restore(itemType, itemID, delta, 0)
+	}
+	_, err := l.Consume(itemType, itemID, -delta)
+	return err
+}
+
+// Consume removes stock and returns the total cost of what was consumed.
+// With fifo the oldest layers are used first; any quantity not covered by
+// layers (e.g. stock from before fifo was enabled) is costed at the stored cost.
+// The item row is locked before its layers are read.
+func (l *Ledger) Consume(itemType string, itemID uuid.UUID, qty float64) (float64, error) {
+	if qty <= 0 {
+		return 0, nil
+	}
+
+	_, cost, err := l.current(itemType, itemID)
+	if err != nil {
+		return 0, err
+	}
+
+	if l.method != MethodFIFO {
+		if err := l.update(itemType, itemID, -qty, cost); err != nil {
+			return 0, err
+		}
+		return qty * cost, nil
+	}
+
+	var layers []database.CostLayer
+	l.tx.Where("item_type = ? AND item_id = ? AND remaining > 0", itemType, itemID).
+		Order("created_at ASC").
+		Find(&layers)
+
+	var total float64
+	remaining := qty
+	for _, layer := range layers {
+		if remaining <= 0 {
+			break
+		}
+		take := math.Min(layer.Remaining, remaining)
+		if err := l.tx.Model(&database.CostLayer{}).
+			Where("id = ?", layer.ID).
+			Update("remaining", layer.Remaining-take).Error; err != nil {
+			return 0, err
+		}
+		total += take * layer.UnitCost
+		remaining -= take
+	}
+	if remaining > 0 {
+		total += remaining * cost
+	}
+
+	if err := l.update(itemType, itemID, -qty, l.layerAverage(itemType, itemID, cost)); err != nil {
+		return 0, err
+	}
+	return total, nil
+}
+
+// Valuation returns the value of stock on hand at cost for one item type.
+// manual and average use the stored unit cost; fifo sums remaining layers and
+// values any stock not covered by layers at the stored cost.
+func Valuation(db *gorm.DB, tenantID, method, itemType, outletID string) float64 {
+	m, costCol, err := model(itemType)
+	if err != nil {
+		return 0
+	}
+
+	var items []struct {
+		ID       uuid.UUID
+		StockQty float64
+		Cost     float64
+	}
+	query := db.Model(m).
+		Select("id, stock_qty, "+costCol+" as cost").
+		Where("tenant_id = ? AND stock_qty > 0", tenantID)
+	if itemType == ItemProduct {
+		query = query.Where("is_active = ?", true)
+	}
+	if outletID != "" {
+		query = query.Where("outlet_id = ?", outletID)
+	}
+	query.Scan(&items)
+
+	layerQty := make(map[uuid.UUID]float64)
+	layerValue := make(map[uuid.UUID]float64)
+	if method == MethodFIFO {
+		var layers []struct {
+			ItemID uuid.UUID
+			Qty    float64
+			Value  float64
+		}
+		db.Model(&database.CostLayer{}).
+			Select("item_id, SUM(remaining) as qty, SUM(remaining * unit_cost) as value").
+			Where("tenant_id = ? AND item_type = ? AND remaining > 0", tenantID, itemType).
+			Group("item_id").
+			Scan(&layers)
+		for _, layer := range layers {
+			layerQty[layer.ItemID] = layer.Qty
+			layerValue[layer.ItemID] = layer.Value
+		}
+	}
+
+	var total float64
+	for _, item := range items {
+		if method != MethodFIFO {
+			total += item.StockQty * item.Cost
+			continue
+		}
+		covered := math.Min(layerQty[item.ID], item.StockQty)
+		if covered > 0 {
+			total += layerValue[item.ID] / layerQty[item.ID] * covered
+		}
+		total += (item.StockQty - covered) * item.Cost
+	}
+	return total
+}
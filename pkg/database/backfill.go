@@ -0,0 +1,36 @@
+package database
+
+import (
+	"log"
+
+	"gorm.io/gorm"
+)
+
+// backfillTax fills in the tax and service charge breakdown of sales made
+// before it was stored. Their tax was charged on the subtotal, and any
+// service charge is what the total holds beyond subtotal, discount and tax.
+// Each statement only touches rows it hasn't filled yet, so this is cheap
+// once done.
+func backfillTax(db *gorm.DB) {
+	statements := []string{
+		`UPDATE transactions SET tax_base = subtotal, tax_rate = ROUND(CAST(tax / subtotal * 100 AS numeric), 2)
+			WHERE tax > 0 AND tax_base = 0 AND subtotal > 0`,
+		`UPDATE transactions SET service_charge = total - (subtotal - discount + tax),
+			service_charge_rate = ROUND(CAST((total - (subtotal - discount + tax)) / subtotal * 100 AS numeric), 2)
+			WHERE service_charge = 0 AND subtotal > 0 AND total - (subtotal - discount + tax) >= 1`,
+		`UPDATE transaction_items SET tax_rate = t.tax_rate,
+			tax_amount = CASE WHEN t.subtotal > 0 THEN transaction_items.subtotal * t.tax / t.subtotal ELSE 0 END
+			FROM transactions t
+			WHERE t.id = transaction_items.transaction_id AND transaction_items.tax_rate IS NULL`,
+	}
+	for _, stmt := range statements {
+		result := db.Exec(stmt)
+		if result.Error != nil {
+			log.Printf("Failed to backfill tax breakdown: %v", result.Error)
+			return
+		}
+		if result.RowsAffected > 0 {
+			log.Printf("Backfilled tax breakdown on %d rows", result.RowsAffected)
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package database
+
+import (
+	"log"
+
+	"gorm.io/gorm"
+)
+
+// createIndexes adds indexes AutoMigrate can't express: composite indexes
+// for list endpoints, and trigram indexes so substring and fuzzy searches
+// don't scan whole tables. Managed databases may not allow pg_trgm; search
+// then falls back to plain ILIKE.
+func createIndexes(db *gorm.DB) {
+	statements := []string{
+		"CREATE INDEX IF NOT EXISTS idx_transactions_tenant_created ON transactions (tenant_id, created_at DESC)",
+	}
+	if err := db.Exec("CREATE EXTENSION IF NOT EXISTS pg_trgm").Error; err != nil {
+		log.Printf("pg_trgm is not available, product search will not tolerate typos: %v", err)
+	} else {
+		statements = append(statements,
+			"CREATE INDEX IF NOT EXISTS idx_products_name_trgm ON products USING gin (name gin_trgm_ops)",
+			"CREATE INDEX IF NOT EXISTS idx_products_sku_trgm ON products USING gin (sku gin_trgm_ops)",
+			"CREATE INDEX IF NOT EXISTS idx_product_barcodes_code_trgm ON product_barcodes USING gin (code gin_trgm_ops)",
+			"CREATE INDEX IF NOT EXISTS idx_transactions_invoice_trgm ON transactions USING gin (invoice_number gin_trgm_ops)",
+		)
+	}
+	for _, stmt := range statements {
+		if err := db.Exec(stmt).Error; err != nil {
+			log.Printf("Failed to create index: %v", err)
+		}
+	}
+}
@@ -11,13 +11,41 @@ import (
 type TenantSettings struct {
 	QRISEnabled           bool    `json:"qris_enabled"`            // Whether QRIS payment is enabled
 	QRISImageURL          string  `json:"qris_image_url"`          // URL to merchant's static QRIS image
+	QRISThumbnailURL      string  `json:"qris_thumbnail_url"`      // Set for uploaded images
+	QRISImageKey          string  `json:"qris_image_key,omitempty"` // Storage key of an uploaded QRIS image
 	QRISLabel             string  `json:"qris_label"`              // Display name, e.g., "BCA QRIS"
+	LogoURL               string  `json:"logo_url"`                // Business logo for receipts and the POS
+	LogoThumbnailURL      string  `json:"logo_thumbnail_url"`
+	LogoKey               string  `json:"logo_key,omitempty"`      // Storage key of the uploaded logo
 	TaxEnabled            bool    `json:"tax_enabled"`             // Whether global tax/PPN is enabled
 	TaxRate               float64 `json:"tax_rate"`                // Tax percentage (e.g., 11 for PPN 11%)
 	TaxLabel              string  `json:"tax_label"`               // Label for tax, e.g., "PPN 11%"
 	ServiceChargeEnabled  bool    `json:"service_charge_enabled"`  // Whether service charge is enabled
 	ServiceChargeRate     float64 `json:"service_charge_rate"`     // Service charge percentage (e.g., 5 or 10)
 	ServiceChargeLabel    string  `json:"service_charge_label"`    // Label, e.g., "Service 10%"
+	CostingMethod         string  `json:"costing_method"`          // manual (default), average, fifo
+	Timezone              string  `json:"timezone"`                // IANA name used for time-based pricing, default Asia/Jakarta
+}
+
+// DefaultTimezone is used when a tenant hasn't set one
+const DefaultTimezone = "Asia/Jakarta"
+
+// TimezoneName returns the IANA name of the tenant's time zone, for use in
+// SQL as well as Go
+func (s TenantSettings) TimezoneName() string {
+	if s.Timezone == "" {
+		return DefaultTimezone
+	}
+	return s.Timezone
+}
+
+// Location returns the tenant's time zone
+func (s TenantSettings) Location() *time.Location {
+	loc, err := time.LoadLocation(s.TimezoneName())
+	if err != nil {
+		return time.FixedZone("WIB", 7*60*60)
+	}
+	return loc
 }
 
 // Base model for all entities
@@ -128,9 +156,13 @@ type StaffInvite struct {
 // Category for products
 type Category struct {
 	BaseModel
-	TenantID uuid.UUID `gorm:"type:uuid;not null" json:"tenant_id"`
-	Tenant   Tenant    `gorm:"foreignKey:TenantID" json:"-"`
-	Name     string    `gorm:"not null" json:"name"`
+	TenantID  uuid.UUID  `gorm:"type:uuid;not null" json:"tenant_id"`
+	Tenant    Tenant     `gorm:"foreignKey:TenantID" json:"-"`
+	ParentID  *uuid.UUID `gorm:"type:uuid;index" json:"parent_id"` // Nested under another category (nil = top level)
+	Name      string     `gorm:"not null" json:"name"`
+	Color     string     `json:"color"`                       // Hex color for POS buttons, e.g. #FF7043
+	Icon      string     `json:"icon"`                        // Icon name for POS buttons
+	SortOrder int        `gorm:"default:0" json:"sort_order"` // Display order among siblings
 }
 
 // Product represents a sellable item
@@ -148,10 +180,32 @@ type Product struct {
 	Cost             float64    `json:"cost"`
 	TaxRate          float64    `gorm:"default:0" json:"tax_rate"` // Tax percentage (e.g., 10 for 10%)
 	StockQty         int        `gorm:"default:0" json:"stock_qty"`
+	MinStockLevel    int        `gorm:"default:10" json:"min_stock_level"`        // Alert threshold; 0 = no alert. Existing rows get the default
+	ReorderQty       int        `gorm:"default:0" json:"reorder_qty"`             // Quantity to order when restocking (0 = not set)
+	AutoReorderPoint bool       `gorm:"default:false" json:"auto_reorder_point"`  // When true, threshold also considers recent sales velocity
+	LeadTimeDays     int        `gorm:"default:1" json:"lead_time_days"`          // Supplier lead time used by the auto reorder point
+	SalesWindowDays  int        `gorm:"default:30" json:"sales_window_days"`      // Days of sales history averaged for the auto reorder point
 	UseMaterialStock bool       `gorm:"default:false" json:"use_material_stock"` // When true, stock is calculated from linked materials
 	ImageURL         string     `json:"image_url"`
+	ThumbnailURL     string     `json:"thumbnail_url"`                                 // Set for uploaded images
+	ImageKey         string     `json:"-"`                                             // Storage key of an uploaded image
 	IsActive         bool       `gorm:"default:true" json:"is_active"`
+	Barcode          string     `gorm:"index" json:"barcode"`                          // Primary barcode, see ProductBarcode
+	ParentID         *uuid.UUID `gorm:"type:uuid;index" json:"parent_id"`              // Set on variants: the product they are a variant of
+	HasVariants      bool       `gorm:"default:false" json:"has_variants"`             // Parent products are sold through their variants
+	VariantAxes      string     `gorm:"type:text" json:"variant_axes,omitempty"`       // JSON on parents: [{"name":"Size","values":["S","M","L"]}]
+	VariantOptions   string     `gorm:"type:text" json:"variant_options,omitempty"`    // JSON on variants: {"Size":"M","Color":"Red"}
+	VariantName      string     `json:"variant_name,omitempty"`                        // Variant label, e.g. "M / Red"
+	IsBundle         bool       `gorm:"default:false" json:"is_bundle"`                // Sold as a set of other products; holds no stock itself
+	AvailableDays    string     `json:"available_days"`                                // ISO weekdays it can be sold, e.g. "1,2,3,4,5"; empty = every day
+	AvailableFrom    string     `json:"available_from"`                                // HH:MM; with AvailableUntil limits sale hours, e.g. a breakfast menu
+	AvailableUntil   string     `json:"available_until"`                               // HH:MM, exclusive
+	EffectivePrice   *float64   `gorm:"-" json:"effective_price,omitempty"`            // Price after price lists, when requested
+	PriceList        string     `gorm:"-" json:"price_list,omitempty"`                 // Name of the price list that set EffectivePrice
 	Modifiers        []ProductModifier `gorm:"foreignKey:ProductID" json:"modifiers,omitempty"`
+	Variants         []Product  `gorm:"foreignKey:ParentID" json:"variants,omitempty"`
+	Components       []BundleComponent `gorm:"foreignKey:BundleID" json:"components,omitempty"` // Fixed bundle components
+	BundleGroups     []BundleGroup     `gorm:"foreignKey:BundleID" json:"bundle_groups,omitempty"`
 }
 
 // ProductModifier represents add-ons/variations (e.g., sizes, toppings)
@@ -163,6 +217,82 @@ type ProductModifier struct {
 	IsRequired bool     `gorm:"default:false" json:"is_required"`
 }
 
+// PriceList overrides product prices when all its conditions match the sale:
+// outlet, customer group, day of week and time of day. Empty conditions match
+// everything.
+type PriceList struct {
+	BaseModel
+	TenantID      uuid.UUID       `gorm:"type:uuid;not null;index" json:"tenant_id"`
+	Name          string          `gorm:"not null" json:"name"`
+	OutletID      *uuid.UUID      `gorm:"type:uuid" json:"outlet_id"`
+	Outlet        *Outlet         `gorm:"foreignKey:OutletID" json:"outlet,omitempty"`
+	CustomerGroup string          `json:"customer_group"`
+	Days          string          `json:"days"`       // ISO weekdays, e.g. "1,2,3,4,5" for Monday to Friday
+	StartTime     string          `json:"start_time"` // HH:MM in the tenant's time zone
+	EndTime       string          `json:"end_time"`   // HH:MM, exclusive; earlier than StartTime spans midnight
+	Priority      int             `gorm:"default:0" json:"priority"`
+	IsActive      bool            `gorm:"default:true" json:"is_active"`
+	Items         []PriceListItem `gorm:"foreignKey:PriceListID" json:"items,omitempty"`
+}
+
+// PriceListItem is one product's price in a price list
+type PriceListItem struct {
+	ID          uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	PriceListID uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_price_list_items_product" json:"price_list_id"`
+	ProductID   uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_price_list_items_product" json:"product_id"` // A product or a variant
+	Price       float64   `gorm:"not null" json:"price"`
+}
+
+// ProductBarcode is a code that identifies a product at the till. A product
+// can have several; the primary one is mirrored to Product.Barcode.
+type ProductBarcode struct {
+	ID        uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	TenantID  uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_product_barcodes_tenant_code" json:"tenant_id"`
+	ProductID uuid.UUID `gorm:"type:uuid;not null;index" json:"product_id"`
+	Code      string    `gorm:"not null;uniqueIndex:idx_product_barcodes_tenant_code" json:"code"`
+	Format    string    `gorm:"not null" json:"format"` // ean13, ean8, upca, code128
+	IsPrimary bool      `gorm:"default:false" json:"is_primary"`
+	Internal  bool      `gorm:"default:false" json:"internal"` // Generated in-store code (GS1 prefix 20)
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// ProductSoldOut marks a product as sold out ("habis") for one business day.
+// It lapses by itself once the date in the tenant's time zone changes.
+type ProductSoldOut struct {
+	ID        uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	TenantID  uuid.UUID  `gorm:"type:uuid;not null;index:idx_product_sold_outs_date" json:"tenant_id"`
+	ProductID uuid.UUID  `gorm:"type:uuid;not null" json:"product_id"` // A product or a variant
+	Product   Product    `gorm:"foreignKey:ProductID" json:"product,omitempty"`
+	OutletID  *uuid.UUID `gorm:"type:uuid" json:"outlet_id"` // Nil = every outlet
+	Date      string     `gorm:"type:varchar(10);not null;index:idx_product_sold_outs_date" json:"date"` // YYYY-MM-DD
+	UserID    *uuid.UUID `gorm:"type:uuid" json:"user_id"`
+	CreatedAt time.Time  `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// BundleGroup is a choice within a bundle, e.g. "Pilih 1 minuman"
+type BundleGroup struct {
+	ID        uuid.UUID         `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	BundleID  uuid.UUID         `gorm:"type:uuid;not null;index" json:"bundle_id"`
+	Name      string            `gorm:"not null" json:"name"`
+	MinSelect int               `gorm:"not null" json:"min_select"` // 0 makes the choice optional
+	MaxSelect int               `gorm:"default:1" json:"max_select"`
+	SortOrder int               `gorm:"default:0" json:"sort_order"`
+	Options   []BundleComponent `gorm:"foreignKey:GroupID" json:"options"`
+}
+
+// BundleComponent is a product included in a bundle. Fixed components have no
+// group; options of a choice group are only included when chosen.
+type BundleComponent struct {
+	ID         uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	BundleID   uuid.UUID  `gorm:"type:uuid;not null;index" json:"bundle_id"`
+	GroupID    *uuid.UUID `gorm:"type:uuid;index" json:"group_id"`
+	ProductID  uuid.UUID  `gorm:"type:uuid;not null;index" json:"product_id"` // Can be a variant
+	Product    Product    `gorm:"foreignKey:ProductID" json:"product"`
+	Quantity   int        `gorm:"not null;default:1" json:"quantity"`
+	PriceDelta float64    `gorm:"default:0" json:"price_delta"` // Added to the bundle price when this option is chosen
+	SortOrder  int        `gorm:"default:0" json:"sort_order"`
+}
+
 // RawMaterial represents raw materials/ingredients
 type RawMaterial struct {
 	BaseModel
@@ -176,6 +306,36 @@ type RawMaterial struct {
 	StockQty      float64    `gorm:"default:0" json:"stock_qty"`
 	MinStockLevel float64    `gorm:"default:10" json:"min_stock_level"` // Alert threshold
 	Supplier      string     `json:"supplier"`
+	IsPrepared    bool       `gorm:"default:false" json:"is_prepared"` // Made in-house from other materials (sub-recipe)
+	YieldQty      float64    `gorm:"default:1" json:"yield_qty"`       // Units produced by one batch of the recipe
+	Components    []MaterialComponent `gorm:"foreignKey:MaterialID" json:"components,omitempty"`
+}
+
+// MaterialComponent links a prepared material to the materials used to make one batch of it
+type MaterialComponent struct {
+	ID             uuid.UUID   `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	MaterialID     uuid.UUID   `gorm:"type:uuid;not null;index" json:"material_id"` // Prepared material
+	ComponentID    uuid.UUID   `gorm:"type:uuid;not null" json:"component_id"`      // Input material
+	Component      RawMaterial `gorm:"foreignKey:ComponentID" json:"component"`
+	QuantityUsed   float64     `gorm:"not null" json:"quantity_used"`    // Per batch
+	UsedUnit       string      `json:"used_unit"`                        // Unit used in recipe (can differ from component unit)
+	ConversionRate float64     `gorm:"default:1" json:"conversion_rate"` // Multiply to convert to component unit
+}
+
+// ProductionRun records a batch of a prepared material being made
+type ProductionRun struct {
+	ID               uuid.UUID   `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	TenantID         uuid.UUID   `gorm:"type:uuid;not null;index" json:"tenant_id"`
+	MaterialID       uuid.UUID   `gorm:"type:uuid;not null;index" json:"material_id"`
+	Material         RawMaterial `gorm:"foreignKey:MaterialID" json:"material,omitempty"`
+	Batches          float64     `gorm:"not null" json:"batches"`
+	QuantityProduced float64     `gorm:"not null" json:"quantity_produced"`
+	UnitCost         float64     `json:"unit_cost"`          // Rolled-up cost per unit at production time
+	Inputs           string      `gorm:"type:jsonb" json:"inputs"` // Snapshot of consumed components
+	Note             string      `json:"note"`
+	UserID           uuid.UUID   `gorm:"type:uuid;not null" json:"user_id"`
+	User             User        `gorm:"foreignKey:UserID" json:"user,omitempty"`
+	CreatedAt        time.Time   `gorm:"autoCreateTime" json:"created_at"`
 }
 
 // ProductMaterial links products to raw materials
@@ -190,6 +350,151 @@ type ProductMaterial struct {
 	ConversionRate float64     `gorm:"default:1" json:"conversion_rate"` // Multiply to convert to material unit
 }
 
+// StockReceipt records stock purchased/received at a given cost
+type StockReceipt struct {
+	BaseModel
+	TenantID  uuid.UUID  `gorm:"type:uuid;not null;index" json:"tenant_id"`
+	OutletID  *uuid.UUID `gorm:"type:uuid" json:"outlet_id"`
+	ItemType  string     `gorm:"not null;index:idx_receipt_item" json:"item_type"` // material, product
+	ItemID    uuid.UUID  `gorm:"type:uuid;not null;index:idx_receipt_item" json:"item_id"`
+	ItemName  string     `json:"item_name"`
+	Quantity  float64    `gorm:"not null" json:"quantity"`
+	UnitCost  float64    `gorm:"not null" json:"unit_cost"`
+	TotalCost float64    `gorm:"not null" json:"total_cost"`
+	Supplier  string     `json:"supplier"`
+	Note      string     `json:"note"`
+	UserID    uuid.UUID  `gorm:"type:uuid;not null" json:"user_id"`
+	User      User       `gorm:"foreignKey:UserID" json:"user,omitempty"`
+}
+
+// CostLayer is a FIFO cost layer: a quantity of stock received at one unit cost
+type CostLayer struct {
+	ID        uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	TenantID  uuid.UUID  `gorm:"type:uuid;not null;index" json:"tenant_id"`
+	ItemType  string     `gorm:"not null;index:idx_cost_layer_item" json:"item_type"` // material, product
+	ItemID    uuid.UUID  `gorm:"type:uuid;not null;index:idx_cost_layer_item" json:"item_id"`
+	ReceiptID *uuid.UUID `gorm:"type:uuid" json:"receipt_id"` // NULL for layers from voids/adjustments
+	Quantity  float64    `gorm:"not null" json:"quantity"`
+	Remaining float64    `gorm:"not null" json:"remaining"`
+	UnitCost  float64    `gorm:"not null" json:"unit_cost"`
+	CreatedAt time.Time  `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// WasteRecord records spoiled or damaged stock written off
+type WasteRecord struct {
+	BaseModel
+	TenantID  uuid.UUID  `gorm:"type:uuid;not null;index" json:"tenant_id"`
+	OutletID  *uuid.UUID `gorm:"type:uuid" json:"outlet_id"`
+	ItemType  string     `gorm:"not null" json:"item_type"` // material, product
+	ItemID    uuid.UUID  `gorm:"type:uuid;not null" json:"item_id"`
+	ItemName  string     `json:"item_name"`
+	Quantity  float64    `gorm:"not null" json:"quantity"`
+	Unit      string     `json:"unit"`
+	UnitCost  float64    `json:"unit_cost"`
+	TotalCost float64    `json:"total_cost"`
+	Reason    string     `json:"reason"`
+	UserID    uuid.UUID  `gorm:"type:uuid;not null" json:"user_id"`
+	User      User       `gorm:"foreignKey:UserID" json:"user,omitempty"`
+}
+
+// Expense is an operating cost such as rent, salaries or utilities
+type Expense struct {
+	BaseModel
+	TenantID           uuid.UUID  `gorm:"type:uuid;not null;index" json:"tenant_id"`
+	OutletID           *uuid.UUID `gorm:"type:uuid;index" json:"outlet_id"` // nil = the whole business
+	Outlet             *Outlet    `gorm:"foreignKey:OutletID" json:"outlet,omitempty"`
+	Category           string     `gorm:"not null;index" json:"category"`
+	Description        string     `json:"description"`
+	Amount             float64    `gorm:"not null" json:"amount"`
+	Date               string     `gorm:"type:varchar(10);not null;index;uniqueIndex:idx_expense_recurrence" json:"date"` // YYYY-MM-DD
+	RecurringExpenseID *uuid.UUID `gorm:"type:uuid;uniqueIndex:idx_expense_recurrence" json:"recurring_expense_id"` // Set on entries recorded from a recurring expense
+	UserID             *uuid.UUID `gorm:"type:uuid" json:"user_id"` // nil for recurring entries
+}
+
+// RecurringExpense records an Expense every week or month
+type RecurringExpense struct {
+	BaseModel
+	TenantID    uuid.UUID  `gorm:"type:uuid;not null;index" json:"tenant_id"`
+	OutletID    *uuid.UUID `gorm:"type:uuid" json:"outlet_id"`
+	Outlet      *Outlet    `gorm:"foreignKey:OutletID" json:"outlet,omitempty"`
+	Category    string     `gorm:"not null" json:"category"`
+	Description string     `json:"description"`
+	Amount      float64    `gorm:"not null" json:"amount"`
+	Frequency   string     `gorm:"not null;default:'monthly'" json:"frequency"` // weekly, monthly
+	StartDate   string     `gorm:"type:varchar(10);not null" json:"start_date"` // First entry; later ones fall on the same weekday or day of month
+	EndDate     string     `gorm:"type:varchar(10)" json:"end_date"`            // Last possible entry, empty = no end
+	NextDate    string     `gorm:"type:varchar(10);not null;index" json:"next_date"` // Next entry not yet recorded
+	IsActive    bool       `gorm:"default:true" json:"is_active"`
+}
+
+// ReportSubscription emails a user a summary report on a schedule. Each
+// report covers the day, week or month ending at its send time.
+type ReportSubscription struct {
+	BaseModel
+	TenantID   uuid.UUID  `gorm:"type:uuid;not null;index" json:"tenant_id"`
+	UserID     uuid.UUID  `gorm:"type:uuid;not null;index" json:"user_id"`
+	User       User       `gorm:"foreignKey:UserID" json:"-"`
+	OutletID   *uuid.UUID `gorm:"type:uuid" json:"outlet_id"` // nil = every outlet
+	Outlet     *Outlet    `gorm:"foreignKey:OutletID" json:"outlet,omitempty"`
+	Frequency  string     `gorm:"not null" json:"frequency"`                  // daily, weekly, monthly
+	SendTime   string     `gorm:"type:varchar(5);not null" json:"send_time"` // HH:MM, usually closing time
+	Weekday    int        `json:"weekday"`                                    // Weekly: 1 = Monday ... 7 = Sunday
+	MonthDay   int        `json:"month_day"`                                  // Monthly: 1-28
+	Timezone   string     `json:"timezone"`                                   // IANA name, empty = the tenant's
+	Attachment string     `json:"attachment"`                                 // csv, xlsx or pdf; empty = none
+	IsActive   bool       `json:"is_active"`
+	NextRunAt  time.Time  `gorm:"not null;index" json:"next_run_at"`
+	LastSentAt *time.Time `json:"last_sent_at"`
+	LastError  string     `json:"last_error"` // Why the last report wasn't sent, if it wasn't
+}
+
+// CashCount records the cash counted in a cashier's drawer when a shift
+// closes. Expected cash is the opening float plus the cashier's completed
+// cash sales at the outlet during the shift, fixed when the count is saved.
+type CashCount struct {
+	BaseModel
+	TenantID     uuid.UUID  `gorm:"type:uuid;not null;index" json:"tenant_id"`
+	OutletID     *uuid.UUID `gorm:"type:uuid;index" json:"outlet_id"`
+	UserID       uuid.UUID  `gorm:"type:uuid;not null;index" json:"user_id"` // Cashier whose drawer was counted
+	User         User       `gorm:"foreignKey:UserID" json:"user,omitempty"`
+	CountedBy    uuid.UUID  `gorm:"type:uuid;not null" json:"counted_by"`
+	OpenedAt     time.Time  `gorm:"not null" json:"opened_at"`
+	ClosedAt     time.Time  `gorm:"not null;index" json:"closed_at"`
+	OpeningFloat float64    `json:"opening_float"`
+	CashSales    float64    `json:"cash_sales"`
+	ExpectedCash float64    `json:"expected_cash"`
+	CountedCash  float64    `json:"counted_cash"`
+	Variance     float64    `json:"variance"` // Expected minus counted; positive = cash short
+	Notes        string     `json:"notes"`
+}
+
+// ImportBatch holds a parsed spreadsheet between preview and commit
+type ImportBatch struct {
+	BaseModel
+	TenantID  uuid.UUID  `gorm:"type:uuid;not null;index" json:"tenant_id"`
+	UserID    uuid.UUID  `gorm:"type:uuid;not null" json:"user_id"`
+	OutletID  *uuid.UUID `gorm:"type:uuid" json:"outlet_id"`
+	Entity    string     `gorm:"not null;default:'product'" json:"entity"`
+	FileName  string     `json:"file_name"`
+	Mode      string     `gorm:"default:'set'" json:"mode"`       // set, add (stock handling)
+	Mapping   string     `gorm:"type:text" json:"mapping"`        // JSON: field -> column header
+	Rows      string     `gorm:"type:text" json:"-"`              // JSON: parsed rows
+	Status    string     `gorm:"default:'pending'" json:"status"` // pending, committed
+	ExpiresAt time.Time  `json:"expires_at"`
+}
+
+// CustomUnit is a tenant-defined pack unit, e.g. "1 dus = 24 pcs"
+type CustomUnit struct {
+	BaseModel
+	TenantID  uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_custom_unit_code" json:"tenant_id"`
+	Code      string    `gorm:"not null;uniqueIndex:idx_custom_unit_code" json:"code"` // e.g. "dus"
+	Name      string    `json:"name"`
+	BaseQty   float64   `gorm:"not null" json:"base_qty"`  // e.g. 24
+	BaseUnit  string    `gorm:"not null" json:"base_unit"` // e.g. "pcs" (standard or another custom unit)
+	Dimension string    `gorm:"not null" json:"dimension"` // mass, volume, count
+	Factor    float64   `gorm:"not null" json:"factor"`    // Resolved factor to the dimension's base unit
+}
+
 // Customer represents a buyer
 type Customer struct {
 	BaseModel
@@ -199,6 +504,7 @@ type Customer struct {
 	Phone    string    `json:"phone"`
 	Email    string    `json:"email"`
 	Address  string    `json:"address"`
+	Group    string    `gorm:"index" json:"group"` // Pricing group, e.g. "member" or "reseller"
 }
 
 // Transaction represents a sale
@@ -218,6 +524,10 @@ type Transaction struct {
 	Subtotal      float64           `gorm:"not null" json:"subtotal"`
 	Discount      float64           `gorm:"default:0" json:"discount"`
 	Tax           float64           `gorm:"default:0" json:"tax"`
+	TaxBase       float64           `gorm:"default:0" json:"tax_base"` // Amount the tax was charged on (DPP)
+	TaxRate       float64           `gorm:"default:0" json:"tax_rate"` // Percent of TaxBase; blended when items carry different rates
+	ServiceCharge float64           `gorm:"default:0" json:"service_charge"`
+	ServiceChargeRate float64       `gorm:"default:0" json:"service_charge_rate"` // Percent of Subtotal
 	Total         float64           `gorm:"not null" json:"total"`
 	Status        string            `gorm:"default:'completed'" json:"status"` // completed, voided, pending
 	PaymentMethod string            `gorm:"default:'cash'" json:"payment_method"` // cash, qris, gopay, ovo, dana
@@ -229,11 +539,30 @@ type Transaction struct {
 type TransactionItem struct {
 	ID            uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
 	TransactionID uuid.UUID `gorm:"type:uuid;not null" json:"transaction_id"`
-	ProductID     uuid.UUID `gorm:"type:uuid;not null" json:"product_id"`
+	ProductID     uuid.UUID `gorm:"type:uuid;not null" json:"product_id"` // Parent product for variants, so reports roll up
 	Product       Product   `gorm:"foreignKey:ProductID" json:"product"`
+	VariantID     *uuid.UUID `gorm:"type:uuid;index" json:"variant_id"`   // Variant sold (stock and cost are tracked on it)
+	VariantName   string    `json:"variant_name,omitempty"`
 	Quantity      int       `gorm:"not null" json:"quantity"`
 	UnitPrice     float64   `gorm:"not null" json:"unit_price"`
+	UnitCost      *float64  `json:"unit_cost"` // Cost per unit captured at sale time (NULL = not captured yet)
 	Subtotal      float64   `gorm:"not null" json:"subtotal"`
+	PriceListID   *uuid.UUID `gorm:"type:uuid" json:"price_list_id"` // Price list that set UnitPrice, if any
+	TaxRate       *float64  `json:"tax_rate"` // Tax percent charged on Subtotal (NULL = sold before tax was itemised)
+	TaxAmount     float64   `gorm:"default:0" json:"tax_amount"`
+	Components    []TransactionItemComponent `gorm:"foreignKey:TransactionItemID" json:"components,omitempty"` // Set on bundle lines
+}
+
+// TransactionItemComponent records a product sold as part of a bundle line
+type TransactionItemComponent struct {
+	ID                uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	TransactionItemID uuid.UUID  `gorm:"type:uuid;not null;index" json:"transaction_item_id"`
+	ProductID         uuid.UUID  `gorm:"type:uuid;not null;index" json:"product_id"` // Parent product for variants
+	VariantID         *uuid.UUID `gorm:"type:uuid" json:"variant_id"`
+	Name              string     `json:"name"`
+	Quantity          int        `gorm:"not null" json:"quantity"` // Units for the whole line (component quantity × line quantity)
+	UnitCost          *float64   `json:"unit_cost"`
+	Revenue           float64    `gorm:"default:0" json:"revenue"` // Share of the line subtotal, split by the components' own prices
 }
 
 // Invoice represents subscription billing invoices
@@ -353,7 +682,7 @@ type PortalInvite struct {
 
 // Migrate runs database migrations
 func Migrate(db *gorm.DB) error {
-	return db.AutoMigrate(
+	if err := db.AutoMigrate(
 		&Tenant{},
 		&Subscription{},
 		&UsageMetrics{},
@@ -363,11 +692,29 @@ func Migrate(db *gorm.DB) error {
 		&Category{},
 		&Product{},
 		&ProductModifier{},
+		&ProductBarcode{},
+		&ProductSoldOut{},
+		&PriceList{},
+		&PriceListItem{},
+		&BundleGroup{},
+		&BundleComponent{},
 		&RawMaterial{},
 		&ProductMaterial{},
+		&MaterialComponent{},
+		&ProductionRun{},
+		&CustomUnit{},
+		&StockReceipt{},
+		&CostLayer{},
+		&WasteRecord{},
+		&Expense{},
+		&RecurringExpense{},
+		&ReportSubscription{},
+		&CashCount{},
+		&ImportBatch{},
 		&Customer{},
 		&Transaction{},
 		&TransactionItem{},
+		&TransactionItemComponent{},
 		&Invoice{},
 		&EmployeeInvite{},
 		&ActivityLog{},
@@ -376,6 +723,12 @@ func Migrate(db *gorm.DB) error {
 		&AffiliateTenant{},
 		&AffiliateEarning{},
 		&PortalInvite{},
-	)
+	); err != nil {
+		return err
+	}
+
+	createIndexes(db)
+	backfillTax(db)
+	return nil
 }
 
@@ -0,0 +1,134 @@
+package email
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+)
+
+// ReportDigest is a scheduled summary of the business for one period
+type ReportDigest struct {
+	UserName       string
+	TenantName     string
+	Title          string        // e.g. "Ringkasan Harian"
+	Period         string        // e.g. "17/10/2026 22:00 - 18/10/2026 22:00"
+	Total          *OutletDigest // Every outlet together, when there is more than one
+	Outlets        []OutletDigest
+	SharedLowStock []LowStockItem // Products not assigned to an outlet
+}
+
+// OutletDigest is one outlet's part of a ReportDigest
+type OutletDigest struct {
+	Name         string
+	Sales        float64
+	Transactions int
+	AverageSale  float64
+	CashSales    float64 // Cash the drawer should hold from the period's sales
+	CashCounts   int     // Shifts closed with a cash count
+	ExpectedCash float64 // Of the counted shifts, opening floats included
+	CountedCash  float64
+	CashVariance float64 // Expected minus counted; positive = cash short
+	Voids        int
+	VoidAmount   float64
+	TopProducts  []TopProduct
+	LowStock     []LowStockItem
+}
+
+// TopProduct is a best seller in a ReportDigest
+type TopProduct struct {
+	Name     string
+	Quantity int
+	Revenue  float64
+}
+
+// LowStockItem is a product at or below its reorder point
+type LowStockItem struct {
+	Name         string
+	Stock        int
+	ReorderPoint int
+}
+
+var reportDigestTemplate = template.Must(template.New("digest").Funcs(template.FuncMap{
+	"rupiah": func(amount float64) string {
+		if amount < 0 {
+			return "-Rp " + formatCurrency(-amount)
+		}
+		return "Rp " + formatCurrency(amount)
+	},
+}).Parse(`
+{{define "figures"}}
+                <table style="width: 100%; border-collapse: collapse; font-size: 14px;">
+                    <tr><td style="padding: 4px 0; color: #6b7280;">Penjualan</td><td style="padding: 4px 0; color: #111827; font-weight: bold; text-align: right;">{{rupiah .Sales}}</td></tr>
+                    <tr><td style="padding: 4px 0; color: #6b7280;">Transaksi</td><td style="padding: 4px 0; color: #374151; text-align: right;">{{.Transactions}}</td></tr>
+                    <tr><td style="padding: 4px 0; color: #6b7280;">Rata-rata per transaksi</td><td style="padding: 4px 0; color: #374151; text-align: right;">{{rupiah .AverageSale}}</td></tr>
+                    <tr><td style="padding: 4px 0; color: #6b7280;">Void</td><td style="padding: 4px 0; color: #dc2626; text-align: right;">{{.Voids}} ({{rupiah .VoidAmount}})</td></tr>
+                    <tr><td style="padding: 4px 0; color: #6b7280;">Kas tunai seharusnya</td><td style="padding: 4px 0; color: #374151; text-align: right;">{{rupiah .CashSales}}</td></tr>
+                    {{if .CashCounts}}<tr><td style="padding: 4px 0; color: #6b7280;">Selisih kas ({{.CashCounts}} hitung kas)</td><td style="padding: 4px 0; color: {{if gt .CashVariance 0.0}}#dc2626{{else}}#374151{{end}}; text-align: right;">{{rupiah .CashVariance}}</td></tr>
+                    {{else}}<tr><td style="padding: 4px 0; color: #6b7280;">Selisih kas</td><td style="padding: 4px 0; color: #9ca3af; text-align: right;">Belum ada hitung kas</td></tr>
+                    {{end}}
+                </table>
+{{end}}
+{{define "lowstock"}}
+                <p style="color: #b45309; font-size: 14px; font-weight: bold; margin: 16px 0 4px;">Stok menipis</p>
+                <table style="width: 100%; border-collapse: collapse; font-size: 13px;">
+                    {{range .}}<tr><td style="padding: 3px 0; color: #374151;">{{.Name}}</td><td style="padding: 3px 0; color: #b45309; text-align: right;">{{.Stock}} / min. {{.ReorderPoint}}</td></tr>
+                    {{end}}
+                </table>
+{{end}}
+<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><meta name="viewport" content="width=device-width, initial-scale=1.0"></head>
+<body style="font-family: 'Segoe UI', Tahoma, Geneva, Verdana, sans-serif; margin: 0; padding: 0; background-color: #f5f5f5;">
+    <div style="max-width: 600px; margin: 0 auto; padding: 40px 20px;">
+        <div style="background: linear-gradient(135deg, #7c3aed 0%, #a855f7 100%); border-radius: 16px 16px 0 0; padding: 32px; text-align: center;">
+            <h1 style="color: white; margin: 0; font-size: 26px;">📊 {{.Title}}</h1>
+            <p style="color: #ede9fe; margin: 8px 0 0; font-size: 14px;">{{.TenantName}} · {{.Period}}</p>
+        </div>
+        <div style="background: white; padding: 32px; border-radius: 0 0 16px 16px; box-shadow: 0 4px 6px rgba(0,0,0,0.1);">
+            <p style="color: #374151; font-size: 16px;">Hai <strong>{{.UserName}}</strong>, berikut ringkasan usaha Anda.</p>
+            {{with .Total}}
+            <div style="background: #f5f3ff; border: 2px solid #a855f7; border-radius: 12px; padding: 20px; margin: 24px 0;">
+                <h3 style="color: #6d28d9; margin: 0 0 12px 0; font-size: 18px;">Semua Outlet</h3>
+                {{template "figures" .}}
+            </div>
+            {{end}}
+            {{range .Outlets}}
+            <div style="border: 1px solid #e5e7eb; border-radius: 12px; padding: 20px; margin: 24px 0;">
+                <h3 style="color: #111827; margin: 0 0 12px 0; font-size: 18px;">{{.Name}}</h3>
+                {{template "figures" .}}
+                {{if .TopProducts}}
+                <p style="color: #374151; font-size: 14px; font-weight: bold; margin: 16px 0 4px;">Produk terlaris</p>
+                <table style="width: 100%; border-collapse: collapse; font-size: 13px;">
+                    {{range .TopProducts}}<tr><td style="padding: 3px 0; color: #374151;">{{.Name}}</td><td style="padding: 3px 0; color: #6b7280; text-align: right;">{{.Quantity}} terjual</td><td style="padding: 3px 0; color: #374151; text-align: right;">{{rupiah .Revenue}}</td></tr>
+                    {{end}}
+                </table>
+                {{end}}
+                {{if .LowStock}}{{template "lowstock" .LowStock}}{{end}}
+            </div>
+            {{end}}
+            {{if .SharedLowStock}}
+            <div style="border: 1px solid #e5e7eb; border-radius: 12px; padding: 20px; margin: 24px 0;">
+                <h3 style="color: #111827; margin: 0; font-size: 18px;">Produk Semua Outlet</h3>
+                {{template "lowstock" .SharedLowStock}}
+            </div>
+            {{end}}
+            <p style="color: #6b7280; font-size: 13px;">Kas tunai seharusnya adalah total penjualan tunai yang selesai. Selisih kas adalah kas seharusnya dikurangi kas yang dihitung saat tutup shift; angka positif berarti kas kurang.</p>
+            <hr style="border: none; border-top: 1px solid #e5e7eb; margin: 24px 0;">
+            <p style="color: #9ca3af; font-size: 12px; text-align: center;">Anda menerima email ini karena berlangganan laporan terjadwal. Ubah atau hentikan langganan di menu Laporan.</p>
+            <p style="color: #9ca3af; font-size: 12px; text-align: center;">© 2024 Warungin. All rights reserved.</p>
+        </div>
+    </div>
+</body>
+</html>
+`))
+
+// SendReportDigest sends a scheduled report, with its attachments if any
+func (s *EmailService) SendReportDigest(toEmail string, digest ReportDigest, attachments []Attachment) error {
+	var body bytes.Buffer
+	if err := reportDigestTemplate.Execute(&body, digest); err != nil {
+		return fmt.Errorf("failed to render report: %v", err)
+	}
+
+	subject := fmt.Sprintf("📊 %s %s - %s", digest.Title, digest.TenantName, digest.Period)
+	return s.SendEmailWithAttachments(toEmail, subject, body.String(), attachments)
+}
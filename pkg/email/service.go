@@ -28,23 +28,36 @@ func (s *EmailService) IsConfigured() bool {
 }
 
 type sendEmailRequest struct {
-	From    string   `json:"from"`
-	To      []string `json:"to"`
-	Subject string   `json:"subject"`
-	HTML    string   `json:"html"`
+	From        string       `json:"from"`
+	To          []string     `json:"to"`
+	Subject     string       `json:"subject"`
+	HTML        string       `json:"html"`
+	Attachments []Attachment `json:"attachments,omitempty"`
+}
+
+// Attachment is a file sent with an email
+type Attachment struct {
+	Filename string `json:"filename"`
+	Content  []byte `json:"content"` // Sent base64 encoded
 }
 
 // SendEmail sends an email using Resend API
 func (s *EmailService) SendEmail(to, subject, htmlBody string) error {
+	return s.SendEmailWithAttachments(to, subject, htmlBody, nil)
+}
+
+// SendEmailWithAttachments sends an email with files attached
+func (s *EmailService) SendEmailWithAttachments(to, subject, htmlBody string, attachments []Attachment) error {
 	if !s.IsConfigured() {
 		return fmt.Errorf("email service not configured")
 	}
 
 	payload := sendEmailRequest{
-		From:    s.fromEmail,
-		To:      []string{to},
-		Subject: subject,
-		HTML:    htmlBody,
+		From:        s.fromEmail,
+		To:          []string{to},
+		Subject:     subject,
+		HTML:        htmlBody,
+		Attachments: attachments,
 	}
 
 	jsonData, err := json.Marshal(payload)
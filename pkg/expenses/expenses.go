@@ -0,0 +1,196 @@
+// Package expenses records the entries of recurring operating expenses.
+package expenses
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/yuditriaji/warungin-backend/pkg/database"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// DateFormat is how expense dates are stored
+const DateFormat = "2006-01-02"
+
+// Recurring expense frequencies
+const (
+	Weekly  = "weekly"
+	Monthly = "monthly"
+)
+
+// DefaultCategories are offered for new expenses; any other name is accepted
+var DefaultCategories = []string{
+	"Sewa",
+	"Gaji",
+	"Listrik",
+	"Air",
+	"Internet",
+	"Pemasaran",
+	"Perawatan",
+	"Transportasi",
+	"Lain-lain",
+}
+
+// maxCatchUp bounds the entries recorded for one recurring expense in a run
+const maxCatchUp = 500
+
+// ValidFrequency reports whether f is a known frequency
+func ValidFrequency(f string) bool {
+	return f == Weekly || f == Monthly
+}
+
+// Occurrence returns the nth entry date (from 0) of an expense starting on
+// start. Monthly entries keep the start's day of month, falling back to the
+// last day of shorter months.
+func Occurrence(frequency string, start time.Time, n int) time.Time {
+	if frequency == Weekly {
+		return start.AddDate(0, 0, 7*n)
+	}
+	first := time.Date(start.Year(), start.Month()+time.Month(n), 1, 0, 0, 0, 0, start.Location())
+	lastDay := first.AddDate(0, 1, -1).Day()
+	day := start.Day()
+	if day > lastDay {
+		day = lastDay
+	}
+	return time.Date(first.Year(), first.Month(), day, 0, 0, 0, 0, start.Location())
+}
+
+// FirstOnOrAfter returns the first entry date on or after day
+func FirstOnOrAfter(frequency string, start, day time.Time) time.Time {
+	if !day.After(start) {
+		return start
+	}
+	var n int
+	if frequency == Weekly {
+		n = int(day.Sub(start).Hours()/24) / 7
+	} else {
+		n = (day.Year()-start.Year())*12 + int(day.Month()-start.Month()) - 1
+	}
+	for next := Occurrence(frequency, start, n); ; next = Occurrence(frequency, start, n) {
+		if !next.Before(day) {
+			return next
+		}
+		n++
+	}
+}
+
+// Today returns the current date in the tenant's time zone
+func Today(db *gorm.DB, tenantID string) string {
+	var tenant database.Tenant
+	db.Select("settings").Where("id = ?", tenantID).First(&tenant)
+	var settings database.TenantSettings
+	if tenant.Settings != "" && tenant.Settings != "{}" {
+		json.Unmarshal([]byte(tenant.Settings), &settings)
+	}
+	return time.Now().In(settings.Location()).Format(DateFormat)
+}
+
+// Generate records the entries of the tenant's recurring expenses that are
+// due by today (YYYY-MM-DD) and returns how many were recorded. It is safe
+// to run concurrently: each recurring expense is locked while its entries
+// are recorded, and an entry is never recorded twice.
+func Generate(db *gorm.DB, tenantID, today string) (int, error) {
+	var ids []uuid.UUID
+	if err := db.Model(&database.RecurringExpense{}).
+		Where("tenant_id = ? AND is_active = ? AND next_date <= ?", tenantID, true, today).
+		Pluck("id", &ids).Error; err != nil {
+		return 0, err
+	}
+
+	recorded := 0
+	for _, id := range ids {
+		count, err := generateOne(db, id, today)
+		if err != nil {
+			return recorded, err
+		}
+		recorded += count
+	}
+	return recorded, nil
+}
+
+// generateOne records one recurring expense's due entries
+func generateOne(db *gorm.DB, id uuid.UUID, today string) (int, error) {
+	recorded := 0
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var recurring database.RecurringExpense
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND is_active = ? AND next_date <= ?", id, true, today).
+			First(&recurring).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return nil // Recorded by another run meanwhile
+			}
+			return err
+		}
+
+		start, err := time.Parse(DateFormat, recurring.StartDate)
+		if err != nil {
+			return fmt.Errorf("recurring expense %s: bad start date %q", recurring.ID, recurring.StartDate)
+		}
+		next, err := time.Parse(DateFormat, recurring.NextDate)
+		if err != nil {
+			return fmt.Errorf("recurring expense %s: bad next date %q", recurring.ID, recurring.NextDate)
+		}
+
+		for i := 0; i < maxCatchUp; i++ {
+			date := next.Format(DateFormat)
+			if date > today {
+				break
+			}
+			if recurring.EndDate != "" && date > recurring.EndDate {
+				recurring.IsActive = false
+				break
+			}
+
+			entry := database.Expense{
+				TenantID:           recurring.TenantID,
+				OutletID:           recurring.OutletID,
+				Category:           recurring.Category,
+				Description:        recurring.Description,
+				Amount:             recurring.Amount,
+				Date:               date,
+				RecurringExpenseID: &recurring.ID,
+			}
+			// An entry deleted by the owner stays deleted
+			result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&entry)
+			if result.Error != nil {
+				return result.Error
+			}
+			recorded += int(result.RowsAffected)
+			next = FirstOnOrAfter(recurring.Frequency, start, next.AddDate(0, 0, 1))
+		}
+
+		recurring.NextDate = next.Format(DateFormat)
+		if recurring.EndDate != "" && recurring.NextDate > recurring.EndDate {
+			recurring.IsActive = false
+		}
+		return tx.Model(&recurring).Updates(map[string]interface{}{
+			"next_date": recurring.NextDate,
+			"is_active": recurring.IsActive,
+		}).Error
+	})
+	return recorded, err
+}
+
+// GenerateAll records due entries for every tenant, each on its own date
+func GenerateAll(db *gorm.DB) (int, error) {
+	var tenantIDs []uuid.UUID
+	if err := db.Model(&database.RecurringExpense{}).
+		Where("is_active = ?", true).
+		Distinct("tenant_id").
+		Pluck("tenant_id", &tenantIDs).Error; err != nil {
+		return 0, err
+	}
+
+	recorded := 0
+	for _, tenantID := range tenantIDs {
+		count, err := Generate(db, tenantID.String(), Today(db, tenantID.String()))
+		if err != nil {
+			return recorded, err
+		}
+		recorded += count
+	}
+	return recorded, nil
+}
@@ -0,0 +1,423 @@
+// Package export writes report tables as CSV, XLSX or PDF downloads, a row
+// at a time. CSV goes straight to the client, XLSX rows are spooled to a
+// temporary file by excelize, and PDF, which has to be laid out in memory,
+// is capped at MaxPDFRows.
+package export
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/xuri/excelize/v2"
+	"github.com/yuditriaji/warungin-backend/pkg/pdf"
+)
+
+// Formats a report can be exported in
+const (
+	CSV  = "csv"
+	XLSX = "xlsx"
+	PDF  = "pdf"
+)
+
+// MaxPDFRows is the most rows a PDF export holds; larger reports should be
+// exported as CSV or XLSX
+const MaxPDFRows = 5000
+
+// Column describes one column of an export
+type Column struct {
+	Header string
+	Width  float64 // Relative width in the PDF, default 1
+}
+
+// Writer receives the rows of an export
+type Writer interface {
+	// Row writes one row, a value per column. Values may be strings,
+	// numbers, times or nil.
+	Row(values ...interface{}) error
+	// Close finishes the file
+	Close() error
+}
+
+// Valid reports whether format is one Start accepts
+func Valid(format string) bool {
+	return format == CSV || format == XLSX || format == PDF
+}
+
+// Requested reads ?format. ok is false, after replying 400, when the format
+// is unknown; an empty format means the client wants JSON.
+func Requested(c *gin.Context) (format string, ok bool) {
+	format = c.Query("format")
+	if format != "" && !Valid(format) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be csv, xlsx or pdf"})
+		return "", false
+	}
+	return format, true
+}
+
+// Start sends the download headers and returns a writer for the rows.
+// filename has no extension; title heads the PDF.
+func Start(c *gin.Context, format, filename, title string, columns []Column) (Writer, error) {
+	if !Valid(format) {
+		return nil, fmt.Errorf("unknown export format %q", format)
+	}
+	// Headers go first: the CSV writer starts the body straight away
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s.%s", filename, format))
+	c.Header("Content-Type", ContentType(format))
+	return New(c.Writer, format, title, columns)
+}
+
+// Fail reports an error that stopped an export part way. A JSON error
+// replaces the download while nothing has been sent; after that the error
+// can only be logged.
+func Fail(c *gin.Context, err error) {
+	log.Printf("Export %s failed: %v", c.Request.URL.Path, err)
+	if c.Writer.Written() {
+		return
+	}
+	c.Writer.Header().Del("Content-Disposition")
+	c.Writer.Header().Del("Content-Type")
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate file"})
+}
+
+// New returns a writer that writes the file to out, e.g. for an email
+// attachment
+func New(out io.Writer, format, title string, columns []Column) (Writer, error) {
+	switch format {
+	case CSV:
+		return newCSV(out, columns)
+	case XLSX:
+		return newXLSX(out, columns)
+	case PDF:
+		return newPDF(out, title, columns), nil
+	default:
+		return nil, fmt.Errorf("unknown export format %q", format)
+	}
+}
+
+// ContentType returns the MIME type of a format
+func ContentType(format string) string {
+	switch format {
+	case CSV:
+		return "text/csv; charset=utf-8"
+	case XLSX:
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	case PDF:
+		return "application/pdf"
+	default:
+		return "application/octet-stream"
+	}
+}
+
+// Text formats a value for CSV and PDF
+func Text(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case float32:
+		return strconv.FormatFloat(float64(v), 'f', -1, 32)
+	case time.Time:
+		return v.Format("2006-01-02 15:04")
+	case *time.Time:
+		if v == nil {
+			return ""
+		}
+		return v.Format("2006-01-02 15:04")
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// sheetText keeps text such as a product name from being read as a formula
+// when the file is opened in a spreadsheet
+func sheetText(text string) string {
+	if text != "" && strings.ContainsRune("=+-@\t\r", rune(text[0])) {
+		return "'" + text
+	}
+	return text
+}
+
+// Unquote undoes sheetText, for an exported file that comes back as an import
+func Unquote(text string) string {
+	if len(text) > 1 && text[0] == '\'' && strings.ContainsRune("=+-@\t\r", rune(text[1])) {
+		return text[1:]
+	}
+	return text
+}
+
+// csvWriter flushes to the client every few rows
+type csvWriter struct {
+	w    *csv.Writer
+	rows int
+}
+
+func newCSV(out io.Writer, columns []Column) (*csvWriter, error) {
+	// A BOM makes Excel open the file as UTF-8
+	if _, err := out.Write([]byte("\xef\xbb\xbf")); err != nil {
+		return nil, err
+	}
+	w := &csvWriter{w: csv.NewWriter(out)}
+	headers := make([]string, len(columns))
+	for i, col := range columns {
+		headers[i] = col.Header
+	}
+	return w, w.w.Write(headers)
+}
+
+func (w *csvWriter) Row(values ...interface{}) error {
+	record := make([]string, len(values))
+	for i, value := range values {
+		if text, ok := value.(string); ok {
+			record[i] = sheetText(text)
+		} else {
+			record[i] = Text(value)
+		}
+	}
+	if err := w.w.Write(record); err != nil {
+		return err
+	}
+	w.rows++
+	if w.rows%500 == 0 {
+		w.w.Flush()
+	}
+	return w.w.Error()
+}
+
+func (w *csvWriter) Close() error {
+	w.w.Flush()
+	return w.w.Error()
+}
+
+// xlsxWriter streams rows into a single sheet
+type xlsxWriter struct {
+	out    io.Writer
+	file   *excelize.File
+	stream *excelize.StreamWriter
+	row    int
+}
+
+func newXLSX(out io.Writer, columns []Column) (*xlsxWriter, error) {
+	f := excelize.NewFile()
+	stream, err := f.NewStreamWriter("Sheet1")
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	for i, col := range columns {
+		width := 16 * col.Width
+		if col.Width == 0 {
+			width = 16
+		}
+		if err := stream.SetColWidth(i+1, i+1, width); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+	bold, _ := f.NewStyle(&excelize.Style{Font: &excelize.Font{Bold: true}})
+	headers := make([]interface{}, len(columns))
+	for i, col := range columns {
+		headers[i] = col.Header
+	}
+	if err := stream.SetRow("A1", headers, excelize.RowOpts{StyleID: bold}); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &xlsxWriter{out: out, file: f, stream: stream, row: 1}, nil
+}
+
+func (w *xlsxWriter) Row(values ...interface{}) error {
+	w.row++
+	cells := make([]interface{}, len(values))
+	for i, value := range values {
+		switch v := value.(type) {
+		case string:
+			cells[i] = sheetText(v)
+		case time.Time, *time.Time:
+			// Text, so the sheet doesn't need a date style
+			cells[i] = Text(v)
+		default:
+			cells[i] = v
+		}
+	}
+	cell, _ := excelize.CoordinatesToCellName(1, w.row)
+	return w.stream.SetRow(cell, cells)
+}
+
+func (w *xlsxWriter) Close() error {
+	defer w.file.Close()
+	if err := w.stream.Flush(); err != nil {
+		return err
+	}
+	return w.file.Write(w.out)
+}
+
+// PDF layout, in points, on A4 landscape
+const (
+	pdfMargin     = 10 * pdf.MM
+	pdfTitleSize  = 13
+	pdfFontSize   = 8
+	pdfLineHeight = 12
+	pdfCellPad    = 3
+)
+
+// pdfWriter lays rows out as a table, repeating the header on every page
+type pdfWriter struct {
+	out     io.Writer
+	title   string
+	columns []Column
+	widths  []float64
+	doc     *pdf.Document
+	page    *pdf.Page
+	pages   int
+	y       float64
+	rows    int
+	skipped int
+}
+
+func newPDF(out io.Writer, title string, columns []Column) *pdfWriter {
+	w := &pdfWriter{out: out, title: title, columns: columns, doc: pdf.New(pdf.A4Height, pdf.A4Width)}
+
+	var total float64
+	for _, col := range columns {
+		total += columnWeight(col)
+	}
+	usable := pdf.A4Height - 2*pdfMargin
+	for _, col := range columns {
+		w.widths = append(w.widths, usable*columnWeight(col)/total)
+	}
+	w.newPage()
+	return w
+}
+
+func columnWeight(col Column) float64 {
+	if col.Width == 0 {
+		return 1
+	}
+	return col.Width
+}
+
+// newPage starts a page with the title, page number and column headers
+func (w *pdfWriter) newPage() {
+	w.page = w.doc.AddPage()
+	w.pages++
+	top := pdf.A4Width - pdfMargin
+	w.page.Text(pdfMargin, top-pdfTitleSize, pdf.HelveticaBold, pdfTitleSize, w.title)
+	pageLabel := fmt.Sprintf("Hal. %d", w.pages)
+	w.page.Text(pdf.A4Height-pdfMargin-pdf.TextWidth(pdf.Helvetica, pdfFontSize, pageLabel), top-pdfTitleSize,
+		pdf.Helvetica, pdfFontSize, pageLabel)
+
+	w.y = top - pdfTitleSize - 2*pdfLineHeight
+	headers := make([]string, len(w.columns))
+	for i, col := range w.columns {
+		headers[i] = col.Header
+	}
+	w.line(pdf.HelveticaBold, headers)
+	w.page.Rect(pdfMargin, w.y+pdfLineHeight-3, pdf.A4Height-2*pdfMargin, 0.5)
+}
+
+// line draws one row of cells at w.y and moves down. Numbers are right-aligned.
+func (w *pdfWriter) line(font string, cells []string) {
+	x := pdfMargin
+	for i, text := range cells {
+		if i >= len(w.widths) {
+			break
+		}
+		width := w.widths[i] - 2*pdfCellPad
+		text = pdf.Fit(font, pdfFontSize, width, text)
+		left := x + pdfCellPad
+		if isNumber(text) {
+			left = x + w.widths[i] - pdfCellPad - pdf.TextWidth(font, pdfFontSize, text)
+		}
+		w.page.Text(left, w.y, font, pdfFontSize, text)
+		x += w.widths[i]
+	}
+	w.y -= pdfLineHeight
+}
+
+func (w *pdfWriter) Row(values ...interface{}) error {
+	if w.rows >= MaxPDFRows {
+		w.skipped++
+		return nil
+	}
+	if w.y < pdfMargin {
+		w.newPage()
+	}
+	cells := make([]string, len(values))
+	for i, value := range values {
+		cells[i] = pdfText(value)
+	}
+	w.line(pdf.Helvetica, cells)
+	w.rows++
+	return nil
+}
+
+func (w *pdfWriter) Close() error {
+	if w.skipped > 0 {
+		if w.y < pdfMargin {
+			w.newPage()
+		}
+		w.page.Text(pdfMargin, w.y, pdf.HelveticaBold, pdfFontSize,
+			fmt.Sprintf("%d baris lainnya tidak ditampilkan. Ekspor ke CSV atau XLSX untuk laporan lengkap.", w.skipped))
+	}
+	_, err := w.doc.WriteTo(w.out)
+	return err
+}
+
+// pdfText formats numbers the Indonesian way, e.g. 1.250.000,5
+func pdfText(value interface{}) string {
+	var f float64
+	switch v := value.(type) {
+	case float64:
+		f = v
+	case float32:
+		f = float64(v)
+	case int:
+		f = float64(v)
+	case int64:
+		f = float64(v)
+	default:
+		return Text(value)
+	}
+
+	s := strconv.FormatFloat(f, 'f', 2, 64)
+	s = strings.TrimSuffix(strings.TrimRight(s, "0"), ".")
+	intPart, frac, _ := strings.Cut(s, ".")
+	sign := ""
+	if strings.HasPrefix(intPart, "-") {
+		sign, intPart = "-", intPart[1:]
+	}
+	var b strings.Builder
+	for i, d := range intPart {
+		if i > 0 && (len(intPart)-i)%3 == 0 {
+			b.WriteByte('.')
+		}
+		b.WriteRune(d)
+	}
+	if frac != "" {
+		return sign + b.String() + "," + frac
+	}
+	return sign + b.String()
+}
+
+// isNumber reports whether a cell holds a formatted number
+func isNumber(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range strings.TrimPrefix(s, "-") {
+		if (r < '0' || r > '9') && r != '.' && r != ',' {
+			return false
+		}
+	}
+	return true
+}
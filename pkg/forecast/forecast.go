@@ -0,0 +1,92 @@
+// Package forecast projects daily demand from a history of daily
+// quantities, allowing for each weekday selling differently
+package forecast
+
+import "time"
+
+// Forecasting methods
+const (
+	// SeasonalNaive repeats the last week of history
+	SeasonalNaive = "seasonal_naive"
+	// Smoothing smooths the history once each weekday's share is taken out
+	// (simple exponential smoothing with multiplicative weekday indices)
+	Smoothing = "smoothing"
+)
+
+// Alpha weights the latest day in Smoothing; lower values follow the trend
+// more slowly but ride out odd days
+const Alpha = 0.3
+
+// ValidMethod reports whether method is a known method
+func ValidMethod(method string) bool {
+	return method == SeasonalNaive || method == Smoothing
+}
+
+// WeekdayIndices returns how much each weekday sells relative to the
+// average day, 1 = average. Weekdays missing from the history count as
+// average.
+func WeekdayIndices(history []float64, first time.Weekday) [7]float64 {
+	var sums [7]float64
+	var counts [7]int
+	var total float64
+	for i, qty := range history {
+		day := (int(first) + i) % 7
+		sums[day] += qty
+		counts[day]++
+		total += qty
+	}
+
+	var indices [7]float64
+	for day := range indices {
+		indices[day] = 1
+	}
+	if total == 0 {
+		return indices
+	}
+	mean := total / float64(len(history))
+	for day := range indices {
+		if counts[day] > 0 {
+			indices[day] = sums[day] / float64(counts[day]) / mean
+		}
+	}
+	return indices
+}
+
+// Daily forecasts the days after history. history holds one quantity per
+// day, the first on weekday first; the result has days values, the first
+// for the day after the history ends.
+func Daily(method string, history []float64, first time.Weekday, days int) []float64 {
+	result := make([]float64, days)
+	if len(history) == 0 {
+		return result
+	}
+	// Weekday of result[0]
+	start := (int(first) + len(history)) % 7
+
+	if method == SeasonalNaive && len(history) >= 7 {
+		lastWeek := history[len(history)-7:]
+		for i := range result {
+			result[i] = lastWeek[i%7]
+		}
+		return result
+	}
+
+	// The level starts at the average day and follows the history from there
+	indices := WeekdayIndices(history, first)
+	var level float64
+	for _, qty := range history {
+		level += qty
+	}
+	level /= float64(len(history))
+	for i, qty := range history {
+		index := indices[(int(first)+i)%7]
+		if index == 0 {
+			continue // A weekday that never sells says nothing about the level
+		}
+		level = Alpha*(qty/index) + (1-Alpha)*level
+	}
+	for i := range result {
+		result[i] = level * indices[(start+i)%7]
+	}
+	return result
+}
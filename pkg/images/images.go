@@ -0,0 +1,222 @@
+// Package images checks uploaded images by their content, scales them down
+// and stores them with a thumbnail.
+package images
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	_ "image/gif" // Registers GIF with image.Decode
+	"image/jpeg"
+	"image/png"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/yuditriaji/warungin-backend/pkg/storage"
+)
+
+// Upload limits
+const (
+	MaxUploadSize = 5 << 20  // Bytes
+	MaxPixels     = 40000000 // Width × height, guards against decompression bombs
+	jpegQuality   = 85
+)
+
+var (
+	ErrTooLarge    = fmt.Errorf("image is too large, maximum %d MB", MaxUploadSize>>20)
+	ErrUnsupported = errors.New("only JPEG, PNG and GIF images are supported")
+)
+
+// Preset sets the largest width or height an image and its thumbnail keep
+type Preset struct {
+	Max   int
+	Thumb int
+}
+
+var (
+	Product = Preset{Max: 1200, Thumb: 300}
+	Logo    = Preset{Max: 512, Thumb: 128}
+	QRIS    = Preset{Max: 1200, Thumb: 300} // Kept large so the code still scans
+)
+
+// Image is an upload re-encoded at its preset sizes. Re-encoding also drops
+// metadata such as the camera's GPS position.
+type Image struct {
+	Data        []byte
+	Thumb       []byte
+	ContentType string
+	Ext         string
+}
+
+// Stored is where an image ended up
+type Stored struct {
+	Key          string // Of the full-size image; see ThumbKey
+	URL          string
+	ThumbnailURL string
+}
+
+// Read reads an uploaded file, up to MaxUploadSize
+func Read(header *multipart.FileHeader) ([]byte, error) {
+	if header.Size > MaxUploadSize {
+		return nil, ErrTooLarge
+	}
+	file, err := header.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, MaxUploadSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > MaxUploadSize {
+		return nil, ErrTooLarge
+	}
+	return data, nil
+}
+
+// Process checks data really is a supported image, whatever the client
+// claimed, and scales it to the preset. JPEGs stay JPEG; PNG and GIF become
+// PNG so transparency and sharp edges survive.
+func Process(data []byte, preset Preset) (*Image, error) {
+	switch http.DetectContentType(data) {
+	case "image/jpeg", "image/png", "image/gif":
+	default:
+		return nil, ErrUnsupported
+	}
+
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrUnsupported
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 || cfg.Width*cfg.Height > MaxPixels {
+		return nil, fmt.Errorf("image dimensions %dx%d are too large", cfg.Width, cfg.Height)
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("image could not be read: %w", err)
+	}
+
+	img := &Image{ContentType: "image/png", Ext: ".png"}
+	if format == "jpeg" {
+		img.ContentType, img.Ext = "image/jpeg", ".jpg"
+	}
+	if img.Data, err = encode(Fit(src, preset.Max), format); err != nil {
+		return nil, err
+	}
+	if img.Thumb, err = encode(Fit(src, preset.Thumb), format); err != nil {
+		return nil, err
+	}
+	return img, nil
+}
+
+func encode(img image.Image, format string) ([]byte, error) {
+	var buf bytes.Buffer
+	var err error
+	if format == "jpeg" {
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: jpegQuality})
+	} else {
+		err = png.Encode(&buf, img)
+	}
+	return buf.Bytes(), err
+}
+
+// Fit scales img down to fit within max × max, keeping its aspect ratio.
+// Smaller images are returned unchanged.
+func Fit(img image.Image, max int) image.Image {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w <= max && h <= max {
+		return img
+	}
+	if w >= h {
+		w, h = max, h*max/w
+	} else {
+		w, h = w*max/h, max
+	}
+	if w < 1 {
+		w = 1
+	}
+	if h < 1 {
+		h = 1
+	}
+	return scale(img, w, h)
+}
+
+// scale resizes by averaging the source pixels that fall under each
+// destination pixel, which gives clean results when shrinking
+func scale(img image.Image, w, h int) *image.RGBA {
+	b := img.Bounds()
+	src := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(src, src.Bounds(), img, b.Min, draw.Src)
+	sw, sh := b.Dx(), b.Dy()
+
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		y0, y1 := y*sh/h, (y+1)*sh/h
+		if y1 <= y0 {
+			y1 = y0 + 1
+		}
+		for x := 0; x < w; x++ {
+			x0, x1 := x*sw/w, (x+1)*sw/w
+			if x1 <= x0 {
+				x1 = x0 + 1
+			}
+			var r, g, bl, a, n uint32
+			for sy := y0; sy < y1; sy++ {
+				row := src.Pix[sy*src.Stride:]
+				for sx := x0; sx < x1; sx++ {
+					p := row[sx*4 : sx*4+4]
+					r += uint32(p[0])
+					g += uint32(p[1])
+					bl += uint32(p[2])
+					a += uint32(p[3])
+					n++
+				}
+			}
+			d := dst.Pix[y*dst.Stride+x*4:]
+			d[0], d[1], d[2], d[3] = uint8(r/n), uint8(g/n), uint8(bl/n), uint8(a/n)
+		}
+	}
+	return dst
+}
+
+// ThumbKey returns the thumbnail key stored alongside an image key
+func ThumbKey(key string) string {
+	ext := path.Ext(key)
+	return strings.TrimSuffix(key, ext) + "_thumb" + ext
+}
+
+// Save stores an image and its thumbnail under prefix with a fresh name, so
+// URLs change whenever the image does
+func Save(ctx context.Context, store storage.Storage, prefix string, img *Image) (Stored, error) {
+	key := path.Join(prefix, uuid.New().String()+img.Ext)
+	if err := store.Put(ctx, key, img.Data, img.ContentType); err != nil {
+		return Stored{}, err
+	}
+	if err := store.Put(ctx, ThumbKey(key), img.Thumb, img.ContentType); err != nil {
+		store.Delete(ctx, key)
+		return Stored{}, err
+	}
+	return Stored{Key: key, URL: store.URL(key), ThumbnailURL: store.URL(ThumbKey(key))}, nil
+}
+
+// Remove deletes an image saved by Save and its thumbnail
+func Remove(ctx context.Context, store storage.Storage, key string) error {
+	if key == "" {
+		return nil
+	}
+	if err := store.Delete(ctx, ThumbKey(key)); err != nil {
+		return err
+	}
+	return store.Delete(ctx, key)
+}
@@ -73,3 +73,9 @@ func AuthRequired() gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// IsManager reports whether a role may manage the business and see its
+// figures: owners and managers
+func IsManager(role string) bool {
+	return role == "owner" || role == "manager"
+}
@@ -42,10 +42,11 @@ func (l *LimitChecker) CheckProductLimit() gin.HandlerFunc {
 			return
 		}
 
-		// Count current products
+		// Count current products. Variants don't count: a product with
+		// variants counts once (see product.MaxVariantsPerProduct)
 		var productCount int64
 		l.db.Model(&database.Product{}).
-			Where("tenant_id = ? AND is_active = ?", tenantID, true).
+			Where("tenant_id = ? AND is_active = ? AND parent_id IS NULL", tenantID, true).
 			Count(&productCount)
 
 		if int(productCount) >= subscription.MaxProducts {
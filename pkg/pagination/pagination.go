@@ -0,0 +1,58 @@
+// Package pagination reads ?page and ?per_page and describes the page
+// returned.
+package pagination
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	DefaultPerPage = 50
+	MaxPerPage     = 200
+)
+
+// Params is the page a client asked for
+type Params struct {
+	Page    int
+	PerPage int
+}
+
+// Meta is returned next to a page of results
+type Meta struct {
+	Page       int   `json:"page"`
+	PerPage    int   `json:"per_page"`
+	Total      int64 `json:"total"`
+	TotalPages int   `json:"total_pages"`
+}
+
+// FromQuery reads ?page (from 1) and ?per_page. ok is false when the client
+// asked for neither, for lists that give older clients a larger first page.
+func FromQuery(c *gin.Context) (p Params, ok bool) {
+	pageStr, perPageStr := c.Query("page"), c.Query("per_page")
+	p.Page, _ = strconv.Atoi(pageStr)
+	p.PerPage, _ = strconv.Atoi(perPageStr)
+	if p.Page < 1 {
+		p.Page = 1
+	}
+	if p.PerPage < 1 {
+		p.PerPage = DefaultPerPage
+	}
+	if p.PerPage > MaxPerPage {
+		p.PerPage = MaxPerPage
+	}
+	return p, pageStr != "" || perPageStr != ""
+}
+
+// Apply limits a query to the page
+func (p Params) Apply(db *gorm.DB) *gorm.DB {
+	return db.Offset((p.Page - 1) * p.PerPage).Limit(p.PerPage)
+}
+
+// Meta describes the page given the total number of results
+func (p Params) Meta(total int64) Meta {
+	pages := int((total + int64(p.PerPage) - 1) / int64(p.PerPage))
+	return Meta{Page: p.Page, PerPage: p.PerPage, Total: total, TotalPages: pages}
+}
@@ -0,0 +1,160 @@
+// Package pdf writes simple PDF documents: filled rectangles and text in the
+// standard Type 1 fonts, which every viewer has built in, so nothing needs to
+// be embedded.
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Page sizes in points
+const (
+	A4Width  = 595.28
+	A4Height = 841.89
+)
+
+// MM is one millimetre in points
+const MM = 72 / 25.4
+
+// Fonts available to Text
+const (
+	Helvetica     = "F1"
+	HelveticaBold = "F2"
+	Courier       = "F3"
+)
+
+var fontNames = []struct{ key, name string }{
+	{Helvetica, "Helvetica"},
+	{HelveticaBold, "Helvetica-Bold"},
+	{Courier, "Courier"},
+}
+
+// Document is a PDF being built in memory
+type Document struct {
+	width, height float64
+	pages         []*Page
+}
+
+// Page holds the drawing operators of one page. Coordinates are in points
+// from the bottom-left corner.
+type Page struct {
+	content bytes.Buffer
+}
+
+// New starts a document whose pages are width × height points
+func New(width, height float64) *Document {
+	return &Document{width: width, height: height}
+}
+
+// AddPage appends a blank page
+func (d *Document) AddPage() *Page {
+	p := &Page{}
+	d.pages = append(d.pages, p)
+	return p
+}
+
+// Rect draws a filled black rectangle
+func (p *Page) Rect(x, y, w, h float64) {
+	fmt.Fprintf(&p.content, "%.3f %.3f %.3f %.3f re f\n", x, y, w, h)
+}
+
+// Text draws a line of text with its baseline at y
+func (p *Page) Text(x, y float64, font string, size float64, s string) {
+	fmt.Fprintf(&p.content, "BT /%s %.2f Tf %.3f %.3f Td (%s) Tj ET\n", font, size, x, y, escape(s))
+}
+
+// TextWidth estimates the width of s in points. Courier is exact; the
+// Helvetica figures are averages, good enough for fitting labels.
+func TextWidth(font string, size float64, s string) float64 {
+	per := 0.52
+	switch font {
+	case Courier:
+		per = 0.6
+	case HelveticaBold:
+		per = 0.58
+	}
+	return float64(len([]rune(s))) * per * size
+}
+
+// Fit shortens s with an ellipsis so it is at most width points wide
+func Fit(font string, size, width float64, s string) string {
+	if TextWidth(font, size, s) <= width {
+		return s
+	}
+	runes := []rune(s)
+	for len(runes) > 0 && TextWidth(font, size, string(runes)+"...") > width {
+		runes = runes[:len(runes)-1]
+	}
+	return strings.TrimSpace(string(runes)) + "..."
+}
+
+// escape encodes s as a PDF string in WinAnsi; characters outside Latin-1
+// become '?'
+func escape(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r < 32:
+			b.WriteByte(' ')
+		case r < 128:
+			b.WriteRune(r)
+		case r < 256:
+			fmt.Fprintf(&b, "\\%03o", r)
+		default:
+			b.WriteByte('?')
+		}
+	}
+	return b.String()
+}
+
+// WriteTo writes the finished document
+func (d *Document) WriteTo(w io.Writer) (int64, error) {
+	var buf bytes.Buffer
+	var offsets []int
+
+	object := func(body string) {
+		offsets = append(offsets, buf.Len())
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	buf.WriteString("%PDF-1.4\n")
+
+	// 1: catalog, 2: page tree, 3..: fonts, then a page and its content per page
+	firstPage := 3 + len(fontNames)
+	kids := make([]string, len(d.pages))
+	for i := range d.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", firstPage+i*2)
+	}
+
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(d.pages)))
+
+	fonts := make([]string, len(fontNames))
+	for i, f := range fontNames {
+		object(fmt.Sprintf("<< /Type /Font /Subtype /Type1 /BaseFont /%s /Encoding /WinAnsiEncoding >>", f.name))
+		fonts[i] = fmt.Sprintf("/%s %d 0 R", f.key, 3+i)
+	}
+
+	for i, p := range d.pages {
+		pageID := firstPage + i*2
+		object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.2f %.2f] /Resources << /Font << %s >> >> /Contents %d 0 R >>",
+			d.width, d.height, strings.Join(fonts, " "), pageID+1))
+		object(fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", p.content.Len(), p.content.String()))
+	}
+
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+
+	n, err := w.Write(buf.Bytes())
+	return int64(n), err
+}
@@ -0,0 +1,131 @@
+// Package pricing resolves what a product sells for once price lists are
+// applied.
+package pricing
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/yuditriaji/warungin-backend/pkg/database"
+	"github.com/yuditriaji/warungin-backend/pkg/schedule"
+	"gorm.io/gorm"
+)
+
+// Context describes a sale for matching price lists
+type Context struct {
+	OutletID      *uuid.UUID
+	CustomerGroup string
+	At            time.Time // In the tenant's time zone
+}
+
+// Prices holds the price lists that apply in one context, best first
+type Prices struct {
+	lists []database.PriceList
+	items map[uuid.UUID]map[uuid.UUID]float64 // List -> product -> price
+	base  map[uuid.UUID]float64               // Own price of each listed product
+}
+
+// Matches reports whether a price list applies in a context
+func Matches(list database.PriceList, ctx Context) bool {
+	if !list.IsActive {
+		return false
+	}
+	if list.OutletID != nil && (ctx.OutletID == nil || *ctx.OutletID != *list.OutletID) {
+		return false
+	}
+	if list.CustomerGroup != "" && !strings.EqualFold(list.CustomerGroup, ctx.CustomerGroup) {
+		return false
+	}
+
+	window := schedule.Window{Days: list.Days, Start: list.StartTime, End: list.EndTime}
+	return window.Contains(ctx.At)
+}
+
+// specificity counts a list's conditions; narrower lists win priority ties
+func specificity(list database.PriceList) int {
+	n := 0
+	if list.OutletID != nil {
+		n++
+	}
+	if list.CustomerGroup != "" {
+		n++
+	}
+	if list.Days != "" {
+		n++
+	}
+	if list.StartTime != "" {
+		n++
+	}
+	return n
+}
+
+// Load finds the tenant's price lists that apply in a context
+func Load(db *gorm.DB, tenantID string, ctx Context) *Prices {
+	var all []database.PriceList
+	db.Where("tenant_id = ? AND is_active = ?", tenantID, true).Order("created_at ASC").Find(&all)
+
+	p := &Prices{items: make(map[uuid.UUID]map[uuid.UUID]float64), base: make(map[uuid.UUID]float64)}
+	var ids []uuid.UUID
+	for _, list := range all {
+		if Matches(list, ctx) {
+			p.lists = append(p.lists, list)
+			ids = append(ids, list.ID)
+			p.items[list.ID] = make(map[uuid.UUID]float64)
+		}
+	}
+	if len(ids) == 0 {
+		return p
+	}
+
+	// Highest priority first, then the most specific
+	sort.SliceStable(p.lists, func(i, j int) bool {
+		if p.lists[i].Priority != p.lists[j].Priority {
+			return p.lists[i].Priority > p.lists[j].Priority
+		}
+		return specificity(p.lists[i]) > specificity(p.lists[j])
+	})
+
+	var items []database.PriceListItem
+	db.Where("price_list_id IN ?", ids).Find(&items)
+	productIDs := make([]uuid.UUID, 0, len(items))
+	for _, item := range items {
+		p.items[item.PriceListID][item.ProductID] = item.Price
+		productIDs = append(productIDs, item.ProductID)
+	}
+
+	// Variants priced through their parent's entry keep their difference
+	// from the parent's own price
+	if len(productIDs) > 0 {
+		var products []database.Product
+		db.Select("id, price").Where("id IN ?", productIDs).Find(&products)
+		for _, product := range products {
+			p.base[product.ID] = product.Price
+		}
+	}
+	return p
+}
+
+// Resolve returns a product's effective price and the list that set it, or
+// the product's own price and nil. A variant without its own entry moves with
+// its parent's entry: a variant 5.000 above its parent stays 5.000 above the
+// parent's list price.
+func (p *Prices) Resolve(product database.Product) (float64, *database.PriceList) {
+	for i := range p.lists {
+		items := p.items[p.lists[i].ID]
+		if price, ok := items[product.ID]; ok {
+			return price, &p.lists[i]
+		}
+		if product.ParentID != nil {
+			if price, ok := items[*product.ParentID]; ok {
+				price += product.Price - p.base[*product.ParentID]
+				if price < 0 {
+					price = 0
+				}
+				return price, &p.lists[i]
+			}
+		}
+	}
+	return product.Price, nil
+}
@@ -0,0 +1,144 @@
+package recipe
+
+import (
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/yuditriaji/warungin-backend/pkg/database"
+	"gorm.io/gorm"
+)
+
+// MaxDepth limits how many levels of prepared materials are followed
+const MaxDepth = 10
+
+var ErrCycle = errors.New("recipe would create a cycle")
+
+// Calculator rolls material costs up through prepared-material recipes.
+// Results are cached, so create one per request.
+type Calculator struct {
+	db        *gorm.DB
+	unitCosts map[uuid.UUID]float64
+}
+
+// NewCalculator creates a new cost calculator
+func NewCalculator(db *gorm.DB) *Calculator {
+	return &Calculator{
+		db:        db,
+		unitCosts: make(map[uuid.UUID]float64),
+	}
+}
+
+// conversion returns the conversion rate, defaulting to 1
+func conversion(rate float64) float64 {
+	if rate <= 0 {
+		return 1
+	}
+	return rate
+}
+
+// MaterialUnitCost returns the cost of one unit of a material.
+// Plain materials use their UnitPrice. Prepared materials in stock use it too,
+// since production keeps it at what the batches cost under every costing
+// method; without stock they are costed from their recipe.
+func (c *Calculator) MaterialUnitCost(material database.RawMaterial) float64 {
+	return c.materialUnitCost(material, 0)
+}
+
+// RecipeUnitCost returns what one unit of a prepared material costs to make
+// now: its components divided by yield
+func (c *Calculator) RecipeUnitCost(material database.RawMaterial) float64 {
+	return c.recipeUnitCost(material, 0)
+}
+
+func (c *Calculator) materialUnitCost(material database.RawMaterial, depth int) float64 {
+	if cost, ok := c.unitCosts[material.ID]; ok {
+		return cost
+	}
+
+	cost := material.UnitPrice
+	if material.IsPrepared && depth < MaxDepth && (material.StockQty <= 0 || material.UnitPrice <= 0) {
+		cost = c.recipeUnitCost(material, depth)
+	}
+
+	c.unitCosts[material.ID] = cost
+	return cost
+}
+
+func (c *Calculator) recipeUnitCost(material database.RawMaterial, depth int) float64 {
+	var components []database.MaterialComponent
+	c.db.Where("material_id = ?", material.ID).Preload("Component").Find(&components)
+	if len(components) == 0 {
+		return material.UnitPrice
+	}
+
+	var batchCost float64
+	for _, comp := range components {
+		batchCost += comp.QuantityUsed * conversion(comp.ConversionRate) * c.materialUnitCost(comp.Component, depth+1)
+	}
+	yield := material.YieldQty
+	if yield <= 0 {
+		yield = 1
+	}
+	return batchCost / yield
+}
+
+// LinkCost returns the cost of one product-material link (per product unit)
+func (c *Calculator) LinkCost(pm database.ProductMaterial) float64 {
+	return pm.QuantityUsed * conversion(pm.ConversionRate) * c.MaterialUnitCost(pm.Material)
+}
+
+// ProductMaterialCost returns the total material cost of one product unit
+func (c *Calculator) ProductMaterialCost(productID uuid.UUID) float64 {
+	var productMaterials []database.ProductMaterial
+	c.db.Where("product_id = ?", productID).Preload("Material").Find(&productMaterials)
+
+	var totalCost float64
+	for _, pm := range productMaterials {
+		totalCost += c.LinkCost(pm)
+	}
+	return totalCost
+}
+
+// ProductUnitCost returns the current cost of one unit of a product.
+// Material-driven products are costed from their recipe; other products use
+// their manual cost, falling back to linked materials when no cost is set.
+func (c *Calculator) ProductUnitCost(product database.Product) float64 {
+	if product.UseMaterialStock {
+		return c.ProductMaterialCost(product.ID)
+	}
+	if product.Cost > 0 {
+		return product.Cost
+	}
+	return c.ProductMaterialCost(product.ID)
+}
+
+// CheckCycle returns ErrCycle if adding componentID to materialID's recipe
+// would make materialID (directly or indirectly) an input of itself
+func CheckCycle(db *gorm.DB, materialID, componentID uuid.UUID) error {
+	if materialID == componentID {
+		return ErrCycle
+	}
+
+	visited := map[uuid.UUID]bool{}
+	queue := []uuid.UUID{componentID}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		if visited[current] {
+			continue
+		}
+		visited[current] = true
+
+		var inputs []uuid.UUID
+		db.Model(&database.MaterialComponent{}).
+			Where("material_id = ?", current).
+			Pluck("component_id", &inputs)
+		for _, input := range inputs {
+			if input == materialID {
+				return ErrCycle
+			}
+			queue = append(queue, input)
+		}
+	}
+	return nil
+}
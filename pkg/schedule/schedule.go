@@ -0,0 +1,140 @@
+// Package schedule matches times against recurring weekly windows such as
+// "Monday to Friday, 06:00-10:00".
+package schedule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Window is a weekly time window. Empty fields match everything.
+type Window struct {
+	Days  string // ISO weekdays, e.g. "1,2,3,4,5" for Monday to Friday
+	Start string // HH:MM
+	End   string // HH:MM, exclusive; earlier than Start spans midnight
+}
+
+// ParseDays parses a comma-separated list of ISO weekdays (1 = Monday, 7 = Sunday)
+func ParseDays(days string) ([]int, error) {
+	var parsed []int
+	for _, part := range strings.Split(days, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		d, err := strconv.Atoi(part)
+		if err != nil || d < 1 || d > 7 {
+			return nil, fmt.Errorf("days must be weekdays from 1 (Monday) to 7 (Sunday)")
+		}
+		parsed = append(parsed, d)
+	}
+	return parsed, nil
+}
+
+// ParseClock parses HH:MM into minutes since midnight
+func ParseClock(clock string) (int, error) {
+	t, err := time.Parse("15:04", clock)
+	if err != nil {
+		return 0, fmt.Errorf("time must be HH:MM, got %q", clock)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// ISOWeekday returns 1 for Monday through 7 for Sunday
+func ISOWeekday(t time.Time) int {
+	if t.Weekday() == time.Sunday {
+		return 7
+	}
+	return int(t.Weekday())
+}
+
+// IsZero reports whether the window has no conditions
+func (w Window) IsZero() bool {
+	return w.Days == "" && w.Start == "" && w.End == ""
+}
+
+// Validate checks the window's fields
+func (w Window) Validate() error {
+	if _, err := ParseDays(w.Days); err != nil {
+		return err
+	}
+	if (w.Start == "") != (w.End == "") {
+		return fmt.Errorf("start and end times must be set together")
+	}
+	if w.Start == "" {
+		return nil
+	}
+	start, err := ParseClock(w.Start)
+	if err != nil {
+		return err
+	}
+	end, err := ParseClock(w.End)
+	if err != nil {
+		return err
+	}
+	if start == end {
+		return fmt.Errorf("start and end times cannot be the same")
+	}
+	return nil
+}
+
+// Contains reports whether t, in local time, falls inside the window.
+// Days are the days a window opens, so the early hours of a window spanning
+// midnight belong to the day before. Invalid windows contain nothing.
+func (w Window) Contains(t time.Time) bool {
+	day := ISOWeekday(t)
+	if w.Start != "" && w.End != "" {
+		start, err1 := ParseClock(w.Start)
+		end, err2 := ParseClock(w.End)
+		if err1 != nil || err2 != nil {
+			return false
+		}
+		now := t.Hour()*60 + t.Minute()
+		if start <= end {
+			if now < start || now >= end {
+				return false
+			}
+		} else if now < end {
+			// After midnight in a window such as 22:00-02:00
+			if day--; day == 0 {
+				day = 7
+			}
+		} else if now < start {
+			return false
+		}
+	}
+
+	if w.Days == "" {
+		return true
+	}
+	days, err := ParseDays(w.Days)
+	if err != nil {
+		return false
+	}
+	for _, d := range days {
+		if d == day {
+			return true
+		}
+	}
+	return false
+}
+
+var dayNames = []string{"", "Senin", "Selasa", "Rabu", "Kamis", "Jumat", "Sabtu", "Minggu"}
+
+// String describes the window for cashiers, e.g. "Senin, Selasa 06:00-10:00"
+func (w Window) String() string {
+	var parts []string
+	if days, err := ParseDays(w.Days); err == nil && len(days) > 0 {
+		names := make([]string, len(days))
+		for i, d := range days {
+			names[i] = dayNames[d]
+		}
+		parts = append(parts, strings.Join(names, ", "))
+	}
+	if w.Start != "" {
+		parts = append(parts, w.Start+"-"+w.End)
+	}
+	return strings.Join(parts, " ")
+}
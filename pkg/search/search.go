@@ -0,0 +1,57 @@
+// Package search builds text search conditions for list endpoints.
+package search
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+
+	"gorm.io/gorm"
+)
+
+// SimilarityThreshold is the pg_trgm word similarity a name needs to match
+// a misspelt term, e.g. "kopi susu" for "kopi ssu"
+const SimilarityThreshold = 0.4
+
+var (
+	trigramMu        sync.Mutex
+	trigramChecked   bool
+	trigramAvailable bool
+)
+
+// Trigram reports whether the pg_trgm extension is installed. Without it
+// searches fall back to substring matching. The answer is cached once the
+// check succeeds.
+func Trigram(db *gorm.DB) bool {
+	trigramMu.Lock()
+	defer trigramMu.Unlock()
+	if !trigramChecked {
+		var installed bool
+		if err := db.Raw("SELECT EXISTS (SELECT 1 FROM pg_extension WHERE extname = 'pg_trgm')").Scan(&installed).Error; err == nil {
+			trigramChecked, trigramAvailable = true, installed
+		}
+	}
+	return trigramAvailable
+}
+
+// UseThreshold makes the `<%` operator, which the trigram indexes can serve,
+// match at SimilarityThreshold for the rest of transaction tx
+func UseThreshold(tx *gorm.DB) error {
+	return tx.Exec("SELECT set_config('pg_trgm.word_similarity_threshold', ?, true)",
+		strconv.FormatFloat(SimilarityThreshold, 'f', -1, 64)).Error
+}
+
+// escape quotes LIKE wildcards so they match literally
+func escape(term string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(term)
+}
+
+// Contains returns a LIKE pattern matching term anywhere
+func Contains(term string) string {
+	return "%" + escape(term) + "%"
+}
+
+// Prefix returns a LIKE pattern matching values that start with term
+func Prefix(term string) string {
+	return escape(term) + "%"
+}
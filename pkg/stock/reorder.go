@@ -0,0 +1,170 @@
+package stock
+
+import (
+	"math"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/yuditriaji/warungin-backend/pkg/database"
+	"gorm.io/gorm"
+)
+
+const (
+	DefaultMinStockLevel   = 10 // For products that don't set a threshold (also the column default); 0 turns low stock alerts off
+	DefaultLeadTimeDays    = 1
+	DefaultSalesWindowDays = 30
+)
+
+// ReorderInfo describes when and how much a product should be restocked
+type ReorderInfo struct {
+	ReorderPoint     int     `json:"reorder_point"`   // Stock below this level is considered low
+	AvgDailySales    float64 `json:"avg_daily_sales"` // Only filled for auto reorder point products
+	IsAutoCalculated bool    `json:"is_auto_calculated"`
+}
+
+// ReorderPoints returns reorder info for each product, keyed by product ID.
+// For products with AutoReorderPoint enabled, the reorder point is
+// avg daily sales (over SalesWindowDays) × LeadTimeDays, never lower than MinStockLevel.
+func ReorderPoints(db *gorm.DB, tenantID string, products []database.Product) map[uuid.UUID]ReorderInfo {
+	result := make(map[uuid.UUID]ReorderInfo, len(products))
+
+	// Group auto products by sales window so each window needs a single query
+	autoByWindow := make(map[int][]uuid.UUID)
+	for _, p := range products {
+		if p.AutoReorderPoint {
+			window := p.SalesWindowDays
+			if window <= 0 {
+				window = DefaultSalesWindowDays
+			}
+			autoByWindow[window] = append(autoByWindow[window], p.ID)
+		}
+	}
+
+	avgDaily := make(map[uuid.UUID]float64)
+	for window, ids := range autoByWindow {
+		since := time.Now().AddDate(0, 0, -window)
+
+		var rows []struct {
+			ProductID uuid.UUID
+			TotalQty  float64
+		}
+		// Variant sales are recorded under the parent with a variant_id, and
+		// bundle sales deduct their components, so both are counted against
+		// the item that holds the stock
+		db.Raw(`
+			SELECT item_id AS product_id, COALESCE(SUM(quantity), 0) AS total_qty FROM (
+				SELECT COALESCE(transaction_items.variant_id, transaction_items.product_id) AS item_id, transaction_items.quantity
+				FROM transaction_items
+				JOIN transactions ON transactions.id = transaction_items.transaction_id
+				WHERE transactions.tenant_id = ? AND transactions.status = 'completed' AND transactions.created_at >= ?
+				UNION ALL
+				SELECT COALESCE(transaction_item_components.variant_id, transaction_item_components.product_id), transaction_item_components.quantity
+				FROM transaction_item_components
+				JOIN transaction_items ON transaction_items.id = transaction_item_components.transaction_item_id
+				JOIN transactions ON transactions.id = transaction_items.transaction_id
+				WHERE transactions.tenant_id = ? AND transactions.status = 'completed' AND transactions.created_at >= ?
+			) AS sold
+			WHERE item_id IN ?
+			GROUP BY item_id`, tenantID, since, tenantID, since, ids).
+			Scan(&rows)
+
+		for _, row := range rows {
+			avgDaily[row.ProductID] = row.TotalQty / float64(window)
+		}
+	}
+
+	for _, p := range products {
+		minLevel := p.MinStockLevel
+		if minLevel < 0 {
+			minLevel = 0
+		}
+
+		info := ReorderInfo{ReorderPoint: minLevel}
+		if p.AutoReorderPoint {
+			leadTime := p.LeadTimeDays
+			if leadTime <= 0 {
+				leadTime = DefaultLeadTimeDays
+			}
+			info.IsAutoCalculated = true
+			info.AvgDailySales = avgDaily[p.ID]
+			computed := int(math.Ceil(info.AvgDailySales * float64(leadTime)))
+			if computed > info.ReorderPoint {
+				info.ReorderPoint = computed
+			}
+		}
+		result[p.ID] = info
+	}
+
+	return result
+}
+
+// Status returns ok, low or out for the given stock level
+func Status(stockQty, reorderPoint int) string {
+	if stockQty <= 0 {
+		return "out"
+	}
+	if stockQty < reorderPoint {
+		return "low"
+	}
+	return "ok"
+}
+
+// SuggestedOrderQty returns how much to order for a product at the given stock level.
+// Uses the product's ReorderQty when set, otherwise enough to reach the reorder point.
+func SuggestedOrderQty(p database.Product, stockQty int, info ReorderInfo) int {
+	if stockQty >= info.ReorderPoint && stockQty > 0 {
+		return 0
+	}
+	if p.ReorderQty > 0 {
+		return p.ReorderQty
+	}
+	if stockQty < 0 {
+		stockQty = 0
+	}
+	return info.ReorderPoint - stockQty
+}
+
+// MaterialStock returns how many units of a product its materials can make
+func MaterialStock(db *gorm.DB, productID uuid.UUID) int {
+	var productMaterials []database.ProductMaterial
+	db.Where("product_id = ?", productID).Preload("Material").Find(&productMaterials)
+
+	available := math.MaxFloat64
+	for _, pm := range productMaterials {
+		if pm.QuantityUsed <= 0 {
+			continue
+		}
+		// Recipe quantity × conversion rate = material used
+		convRate := pm.ConversionRate
+		if convRate <= 0 {
+			convRate = 1
+		}
+		if canMake := pm.Material.StockQty / (pm.QuantityUsed * convRate); canMake < available {
+			available = canMake
+		}
+	}
+	if available == math.MaxFloat64 {
+		return 0
+	}
+	return int(math.Floor(available))
+}
+
+// Levels counts products that are low on or out of stock against their
+// reorder points. Products using material stock count what their materials
+// can make. The dashboard and inventory summary both count with this.
+func Levels(db *gorm.DB, tenantID string, products []database.Product) (low, out int) {
+	reorder := ReorderPoints(db, tenantID, products)
+	for _, p := range products {
+		stockQty := p.StockQty
+		if p.UseMaterialStock {
+			stockQty = MaterialStock(db, p.ID)
+		}
+		switch Status(stockQty, reorder[p.ID].ReorderPoint) {
+		case "low":
+			low++
+		case "out":
+			out++
+		}
+	}
+	return low, out
+}
@@ -0,0 +1,69 @@
+// Package storage keeps uploaded files such as product images. Files live
+// under keys like "tenants/<tenant_id>/products/<product_id>/<name>.jpg"
+// either on local disk or in an S3-compatible bucket.
+package storage
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+)
+
+// Storage stores and serves files by key
+type Storage interface {
+	// Put stores data under key, replacing any existing file
+	Put(ctx context.Context, key string, data []byte, contentType string) error
+	// Delete removes the file under key; a missing file is not an error
+	Delete(ctx context.Context, key string) error
+	// URL returns the public URL of the file under key
+	URL(key string) string
+}
+
+// TenantKey builds a key under the tenant's own prefix
+func TenantKey(tenantID string, parts ...string) string {
+	return path.Join(append([]string{"tenants", tenantID}, parts...)...)
+}
+
+// validKey rejects keys that could escape the storage root
+func validKey(key string) error {
+	if key == "" || strings.HasPrefix(key, "/") || path.Clean(key) != key || strings.HasPrefix(key, "..") {
+		return fmt.Errorf("invalid storage key %q", key)
+	}
+	return nil
+}
+
+// FromEnv builds the storage backend selected by STORAGE_DRIVER:
+//
+//	local (default): STORAGE_LOCAL_DIR (default "uploads") and
+//	  STORAGE_PUBLIC_URL (default "/uploads")
+//	s3: S3_ENDPOINT, S3_REGION, S3_BUCKET, S3_ACCESS_KEY_ID,
+//	  S3_SECRET_ACCESS_KEY, optional S3_PUBLIC_URL and S3_PATH_STYLE=true
+func FromEnv() (Storage, error) {
+	switch driver := os.Getenv("STORAGE_DRIVER"); driver {
+	case "", "local":
+		dir := os.Getenv("STORAGE_LOCAL_DIR")
+		if dir == "" {
+			dir = "uploads"
+		}
+		publicURL := os.Getenv("STORAGE_PUBLIC_URL")
+		if publicURL == "" {
+			publicURL = LocalMountPath
+		}
+		return NewLocal(dir, publicURL), nil
+	case "s3":
+		cfg := S3Config{
+			Endpoint:        os.Getenv("S3_ENDPOINT"),
+			Region:          os.Getenv("S3_REGION"),
+			Bucket:          os.Getenv("S3_BUCKET"),
+			AccessKeyID:     os.Getenv("S3_ACCESS_KEY_ID"),
+			SecretAccessKey: os.Getenv("S3_SECRET_ACCESS_KEY"),
+			PublicURL:       os.Getenv("S3_PUBLIC_URL"),
+			PathStyle:       os.Getenv("S3_PATH_STYLE") == "true",
+		}
+		return NewS3(cfg)
+	default:
+		return nil, fmt.Errorf("unknown STORAGE_DRIVER %q", driver)
+	}
+}
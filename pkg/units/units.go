@@ -0,0 +1,159 @@
+package units
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/yuditriaji/warungin-backend/pkg/database"
+	"gorm.io/gorm"
+)
+
+// Dimension groups units that can be converted into each other
+type Dimension string
+
+const (
+	Mass   Dimension = "mass"   // Base unit: g
+	Volume Dimension = "volume" // Base unit: ml
+	Count  Dimension = "count"  // Base unit: pcs
+)
+
+var ErrIncompatible = errors.New("incompatible units")
+
+// Unit is a unit of measure with its factor to the dimension's base unit
+type Unit struct {
+	Code      string    `json:"code"`
+	Name      string    `json:"name"`
+	Dimension Dimension `json:"dimension"`
+	Factor    float64   `json:"factor"` // How many base units one of this unit is
+	Custom    bool      `json:"custom"`
+}
+
+// Standard is the built-in unit catalog
+var Standard = []Unit{
+	{Code: "mg", Name: "Miligram", Dimension: Mass, Factor: 0.001},
+	{Code: "g", Name: "Gram", Dimension: Mass, Factor: 1},
+	{Code: "ons", Name: "Ons", Dimension: Mass, Factor: 100},
+	{Code: "kg", Name: "Kilogram", Dimension: Mass, Factor: 1000},
+	{Code: "ml", Name: "Mililiter", Dimension: Volume, Factor: 1},
+	{Code: "sdt", Name: "Sendok teh", Dimension: Volume, Factor: 5},
+	{Code: "sdm", Name: "Sendok makan", Dimension: Volume, Factor: 15},
+	{Code: "l", Name: "Liter", Dimension: Volume, Factor: 1000},
+	{Code: "pcs", Name: "Pcs", Dimension: Count, Factor: 1},
+	{Code: "lusin", Name: "Lusin", Dimension: Count, Factor: 12},
+	{Code: "kodi", Name: "Kodi", Dimension: Count, Factor: 20},
+}
+
+// aliases maps common spellings to standard unit codes
+var aliases = map[string]string{
+	"miligram":   "mg",
+	"gr":         "g",
+	"gram":       "g",
+	"kilo":       "kg",
+	"kilogram":   "kg",
+	"cc":         "ml",
+	"mililiter":  "ml",
+	"milliliter": "ml",
+	"liter":      "l",
+	"litre":      "l",
+	"ltr":        "l",
+	"pc":         "pcs",
+	"buah":       "pcs",
+	"biji":       "pcs",
+	"butir":      "pcs",
+	"unit":       "pcs",
+	"dozen":      "lusin",
+}
+
+// Normalize lowercases and trims a unit code, resolving known aliases
+func Normalize(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	if alias, ok := aliases[code]; ok {
+		return alias
+	}
+	return code
+}
+
+// Lookup finds a standard unit by code or alias
+func Lookup(code string) (Unit, bool) {
+	code = Normalize(code)
+	for _, u := range Standard {
+		if u.Code == code {
+			return u, true
+		}
+	}
+	return Unit{}, false
+}
+
+// Rate returns the multiplier that converts a quantity in `from` into `to`
+func Rate(from, to Unit) (float64, error) {
+	if from.Dimension != to.Dimension {
+		return 0, fmt.Errorf("%w: %s (%s) cannot be converted to %s (%s)",
+			ErrIncompatible, from.Code, from.Dimension, to.Code, to.Dimension)
+	}
+	return from.Factor / to.Factor, nil
+}
+
+// Catalog is the set of units available to a tenant: standard plus custom pack units
+type Catalog struct {
+	units map[string]Unit
+}
+
+// LoadCatalog builds the unit catalog for a tenant
+func LoadCatalog(db *gorm.DB, tenantID string) *Catalog {
+	catalog := &Catalog{units: make(map[string]Unit)}
+	for _, u := range Standard {
+		catalog.units[u.Code] = u
+	}
+
+	var custom []database.CustomUnit
+	db.Where("tenant_id = ?", tenantID).Find(&custom)
+	for _, cu := range custom {
+		catalog.units[Normalize(cu.Code)] = Unit{
+			Code:      cu.Code,
+			Name:      cu.Name,
+			Dimension: Dimension(cu.Dimension),
+			Factor:    cu.Factor,
+			Custom:    true,
+		}
+	}
+	return catalog
+}
+
+// Lookup finds a unit in the catalog by code or alias
+func (c *Catalog) Lookup(code string) (Unit, bool) {
+	u, ok := c.units[Normalize(code)]
+	return u, ok
+}
+
+// All returns every unit in the catalog
+func (c *Catalog) All() []Unit {
+	var custom []Unit
+	for _, u := range c.units {
+		if u.Custom {
+			custom = append(custom, u)
+		}
+	}
+	sort.Slice(custom, func(i, j int) bool { return custom[i].Code < custom[j].Code })
+
+	all := make([]Unit, 0, len(c.units))
+	all = append(all, Standard...)
+	return append(all, custom...)
+}
+
+// ConversionRate returns the rate that converts `from` into `to`.
+// known is false when either unit isn't in the catalog, so callers can fall
+// back to a manually entered rate; err is set when the units are incompatible.
+func (c *Catalog) ConversionRate(from, to string) (rate float64, known bool, err error) {
+	fromUnit, ok := c.Lookup(from)
+	if !ok {
+		return 0, false, nil
+	}
+	toUnit, ok := c.Lookup(to)
+	if !ok {
+		return 0, false, nil
+	}
+	rate, err = Rate(fromUnit, toUnit)
+	return rate, true, err
+}